@@ -49,6 +49,10 @@ type ConfigStatus struct {
 	// Config holds the references to the generated configuration.
 	Config ConfigStatusConfig `json:"config,omitempty"`
 	// +kubebuilder:validation:Optional
+	// Revision holds the commit SHAs of the repositories used to generate
+	// the configuration.
+	Revision ConfigStatusRevision `json:"revision,omitempty"`
+	// +kubebuilder:validation:Optional
 	// Version of the giantswarm/config repository used to generate the
 	// configuration.
 	Version string `json:"version,omitempty"`
@@ -88,6 +92,24 @@ type ConfigStatusConfigSecretRef struct {
 	Namespace string `json:"namespace"`
 }
 
+// ConfigStatusRevision holds the commit SHAs of the repositories used to
+// generate the configuration.
+// +k8s:openapi-gen=true
+type ConfigStatusRevision struct {
+	// +kubebuilder:validation:Optional
+	// Config is the commit SHA of the config repository.
+	Config string `json:"config,omitempty"`
+	// +kubebuilder:validation:Optional
+	// SharedConfig is the comma-separated list of commit SHAs of the shared
+	// configs repositories in overlay order. It is only set for split
+	// config setups.
+	SharedConfig string `json:"sharedConfig,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Stale is true when the configuration was generated from the last
+	// known good commits because the repositories were unreachable.
+	Stale bool `json:"stale,omitempty"`
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 type ConfigList struct {
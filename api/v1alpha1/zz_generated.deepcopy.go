@@ -120,6 +120,7 @@ func (in *ConfigStatus) DeepCopyInto(out *ConfigStatus) {
 	*out = *in
 	out.App = in.App
 	out.Config = in.Config
+	out.Revision = in.Revision
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigStatus.
@@ -193,3 +194,18 @@ func (in *ConfigStatusConfigSecretRef) DeepCopy() *ConfigStatusConfigSecretRef {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigStatusRevision) DeepCopyInto(out *ConfigStatusRevision) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigStatusRevision.
+func (in *ConfigStatusRevision) DeepCopy() *ConfigStatusRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigStatusRevision)
+	in.DeepCopyInto(out)
+	return out
+}
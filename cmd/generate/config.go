@@ -0,0 +1,68 @@
+package generate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/config-controller/api/v1alpha1"
+)
+
+// readConfig reads the Config CR from the file set with --from-config-file
+// or fetches the one referenced by --from-config from the cluster.
+func (r *runner) readConfig(ctx context.Context) (*v1alpha1.Config, error) {
+	if r.flag.FromConfigFile != "" {
+		bs, err := os.ReadFile(filepath.Clean(r.flag.FromConfigFile))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		config := &v1alpha1.Config{}
+		err = yaml.Unmarshal(bs, config)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return config, nil
+	}
+
+	// The format is checked when validating the flags.
+	namespace, name, _ := strings.Cut(r.flag.FromConfig, "/")
+
+	var k8sClient client.Client
+	{
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = r.flag.Kubeconfig
+
+		restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		scheme := runtime.NewScheme()
+		err = v1alpha1.AddToScheme(scheme)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		k8sClient, err = client.New(restConfig, client.Options{Scheme: scheme})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	config := &v1alpha1.Config{}
+	err := k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, config)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,151 @@
+package generate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+
+	"github.com/giantswarm/config-controller/internal/vault"
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+	pkggenerator "github.com/giantswarm/config-controller/pkg/generator"
+)
+
+// newDecryptTraverser returns the traverser decrypting secret files and the
+// resolver for the secret template functions. The resolver is nil for
+// decryption modes without Vault access unless a plugin is configured.
+func (r *runner) newDecryptTraverser(ctx context.Context) (pkggenerator.DecryptTraverser, pkggenerator.SecretResolver, error) {
+	if r.flag.Decrypt == decryptSOPS {
+		t, err := r.newSOPSTraverser()
+		if err != nil {
+			return nil, nil, microerror.Mask(err)
+		}
+
+		var resolver pkggenerator.SecretResolver
+		if r.flag.DecryptPlugin != "" {
+			resolver, err = r.newExecDecrypter(nil)
+			if err != nil {
+				return nil, nil, microerror.Mask(err)
+			}
+		}
+
+		return t, resolver, nil
+	}
+
+	decrypter, err := r.newDecrypter(ctx)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	if r.flag.DecryptPlugin != "" {
+		decrypter, err = r.newExecDecrypter(decrypter)
+		if err != nil {
+			return nil, nil, microerror.Mask(err)
+		}
+	}
+
+	t, err := decrypt.NewYAMLTraverser(decrypt.YAMLTraverserConfig{Decrypter: decrypter})
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	resolver, _ := decrypter.(pkggenerator.SecretResolver)
+
+	return t, resolver, nil
+}
+
+func (r *runner) newExecDecrypter(next decrypt.Decrypter) (*decrypt.ExecDecrypter, error) {
+	c := decrypt.ExecDecrypterConfig{
+		Command:   r.flag.DecryptPlugin,
+		Decrypter: next,
+		Prefix:    r.flag.DecryptPluginPrefix,
+	}
+
+	d, err := decrypt.NewExecDecrypter(c)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return d, nil
+}
+
+func (r *runner) newSOPSTraverser() (*decrypt.SOPSTraverser, error) {
+	var err error
+
+	var c decrypt.SOPSTraverserConfig
+	if r.flag.SOPSAgeKeyFile != "" {
+		c.AgeKeys, err = os.ReadFile(filepath.Clean(r.flag.SOPSAgeKeyFile))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+	if r.flag.SOPSPGPKeyFile != "" {
+		c.PGPKeys, err = os.ReadFile(filepath.Clean(r.flag.SOPSPGPKeyFile))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	t, err := decrypt.NewSOPSTraverser(c)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return t, nil
+}
+
+func (r *runner) newDecrypter(ctx context.Context) (decrypt.Decrypter, error) {
+	switch r.flag.Decrypt {
+	case decryptNone:
+		return decrypt.NewNoneDecrypter(), nil
+	case decryptRedact:
+		return decrypt.NewRedactDecrypter(r.redactor), nil
+	case decryptKeyFile:
+		keyFile, err := os.ReadFile(filepath.Clean(r.flag.DecryptKeyFile))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		d, err := decrypt.NewKeyFileDecrypter(decrypt.KeyFileDecrypterConfig{KeyFile: keyFile})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return d, nil
+	}
+
+	var err error
+
+	var vaultClient *vaultapi.Client
+	if r.flag.VaultAddress != "" {
+		c := vault.ClientConfig{
+			Address: r.flag.VaultAddress,
+			Token:   r.flag.VaultToken,
+			CAPath:  r.flag.VaultCAPath,
+		}
+
+		vaultClient, err = vault.NewClient(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	} else {
+		vaultClient, err = vault.NewClientUsingOpsctl(ctx, r.flag.GitHubToken, r.flag.SSHUser, r.flag.Installation)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	d, err := decrypt.NewVaultDecrypter(decrypt.VaultDecrypterConfig{VaultClient: vaultClient, Key: r.flag.VaultTransitKey})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	kv, err := decrypt.NewVaultKVResolver(decrypt.VaultKVResolverConfig{Decrypter: d, VaultClient: vaultClient, AllowedPathPrefixes: r.flag.VaultKVAllowedPathPrefixes})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return kv, nil
+}
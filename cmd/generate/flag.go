@@ -3,82 +3,261 @@ package generate
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/giantswarm/microerror"
 	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+	pkggenerator "github.com/giantswarm/config-controller/pkg/generator"
 )
 
 const (
 	flagApp                            = "app"
 	flagSharedConfigRepoName           = "shared-config-repo-name"
+	flagSharedConfigRepoOwner          = "shared-config-repo-owner"
+	flagSharedConfigRepoProtected      = "shared-config-repo-refuse-protected-overrides"
 	flagSharedConfigRepoRef            = "shared-config-repo-ref"
 	flagSharedConfigRepoSSHPemPath     = "shared-config-repo-ssh-pem-path"
 	flagSharedConfigRepoSSHPemPassword = "shared-config-repo-ssh-pem-password" // #nosec G101
+	flagSharedConfigRepoURL            = "shared-config-repo-url"
+	flagSharedConfigReposFile          = "shared-config-repos-file"
 	flagConfigRepoSSHPemPath           = "config-repo-ssh-pem-path"
 	flagConfigRepoSSHPemPassword       = "config-repo-ssh-pem-password" // #nosec G101
+	flagDecrypt                        = "decrypt"
+	flagDecryptKeyFile                 = "decrypt-key-file"
+	flagDecryptPlugin                  = "decrypt-plugin"
+	flagDecryptPluginPrefix            = "decrypt-plugin-prefix"
+	flagFromConfig                     = "from-config"
+	flagFromConfigFile                 = "from-config-file"
+	flagGitCacheDir                    = "git-cache-dir"
+	flagGitHost                        = "git-host"
+	flagGitHubAPIURL                   = "github-api-url"
+	flagGitHubAppID                    = "github-app-id"
+	flagGitHubAppInstallationID        = "github-app-installation-id"
+	flagGitHubAppPrivateKeyPath        = "github-app-private-key-path"
 	flagGithubToken                    = "github-token"
 	flagInstallation                   = "installation"
+	flagKubeconfig                     = "kubeconfig"
 	flagName                           = "name"
 	flagNamespace                      = "namespace"
+	flagPlaintextSecretCheck           = "plaintext-secret-check"
 	flagRaw                            = "raw"
+	flagRedact                         = "redact"
+	flagRedactKey                      = "redact-key"
 	flagRepositoryName                 = "repository-name"
+	flagRepositoryOwner                = "repository-owner"
 	flagRepositoryRef                  = "repository-ref"
+	flagRepositoryToken                = "repository-token" // #nosec G101
+	flagRepositoryURL                  = "repository-url"
+	flagRepositoryUsername             = "repository-username"
+	flagSOPSAgeKeyFile                 = "sops-age-key-file"
+	flagSOPSPGPKeyFile                 = "sops-pgp-key-file"
+	flagSSHKnownHosts                  = "ssh-known-hosts"
 	flagSSHUser                        = "ssh-user"
+	flagTrustedGPGKeys                 = "trusted-gpg-keys"
+	flagTrustedSSHKeys                 = "trusted-ssh-keys"
+	flagVaultAddress                   = "vault-address"
+	flagVaultCAPath                    = "vault-ca-path"
+	flagVaultKVAllowedPathPrefix       = "vault-kv-allowed-path-prefix"
+	flagVaultToken                     = "vault-token" // #nosec G101
+	flagVaultTransitKey                = "vault-transit-key"
 	flagVerbose                        = "verbose"
 
-	envConfigControllerGithubToken = "CONFIG_CONTROLLER_GITHUB_TOKEN" //nolint:gosec
+	envConfigControllerGithubToken = "CONFIG_CONTROLLER_GITHUB_TOKEN"     //nolint:gosec
+	envRedactKey                   = "CONFIG_CONTROLLER_REDACT_KEY"       //nolint:gosec
+	envRepositoryToken             = "CONFIG_CONTROLLER_REPOSITORY_TOKEN" //nolint:gosec
+	envSOPSAgeKeyFile              = "SOPS_AGE_KEY_FILE"
+	envVaultCAPath                 = "VAULT_CAPATH"
+	envVaultToken                  = "VAULT_TOKEN" //nolint:gosec
+)
+
+const (
+	decryptKeyFile = "keyfile"
+	decryptNone    = "none"
+	decryptRedact  = "redact"
+	decryptSOPS    = "sops"
+	decryptVault   = "vault"
 )
 
 type flag struct {
 	App                            string
 	SharedConfigRepoName           string
+	SharedConfigRepoOwner          string
+	SharedConfigRepoProtected      bool
 	SharedConfigRepoRef            string
 	SharedConfigRepoSSHPemPath     string
 	SharedConfigRepoSSHPemPassword string
+	SharedConfigRepoURL            string
+	SharedConfigReposFile          string
 	ConfigRepoSSHPemPath           string
 	ConfigRepoSSHPemPassword       string
+	Decrypt                        string
+	DecryptKeyFile                 string
+	DecryptPlugin                  string
+	DecryptPluginPrefix            string
+	FromConfig                     string
+	FromConfigFile                 string
+	GitCacheDir                    string
+	GitHost                        string
+	GitHubAPIURL                   string
+	GitHubAppID                    int64
+	GitHubAppInstallationID        int64
+	GitHubAppPrivateKeyPath        string
 	GitHubToken                    string
 	RepositoryName                 string
+	RepositoryOwner                string
 	RepositoryRef                  string
+	RepositoryToken                string
+	RepositoryURL                  string
+	RepositoryUsername             string
 	Installation                   string
+	Kubeconfig                     string
 	Name                           string
 	Namespace                      string
+	PlaintextSecretCheck           string
 	Raw                            bool
+	Redact                         bool
+	RedactKey                      string
+	SOPSAgeKeyFile                 string
+	SOPSPGPKeyFile                 string
+	SSHKnownHosts                  string
 	SSHUser                        string
+	TrustedGPGKeys                 string
+	TrustedSSHKeys                 string
+	VaultAddress                   string
+	VaultCAPath                    string
+	VaultKVAllowedPathPrefixes     []string
+	VaultToken                     string
+	VaultTransitKey                string
 	Verbose                        bool
 }
 
 func (f *flag) Init(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&f.App, flagApp, "", `Name of an application to generate the config for (e.g. "kvm-operator").`)
 	cmd.Flags().StringVar(&f.SharedConfigRepoName, flagSharedConfigRepoName, "shared-configs", `Name of the shared configuration repository, defaults to "shared-configs".`)
+	cmd.Flags().StringVar(&f.SharedConfigRepoOwner, flagSharedConfigRepoOwner, "", fmt.Sprintf(`Owner of the shared configuration repository. Defaults to --%s.`, flagRepositoryOwner))
+	cmd.Flags().BoolVar(&f.SharedConfigRepoProtected, flagSharedConfigRepoProtected, false, `Fail when the configuration repository overrides files listed in the ".protected" file of the shared configuration repository.`)
 	cmd.Flags().StringVar(&f.SharedConfigRepoRef, flagSharedConfigRepoRef, "main", `Branch of the shared configuration repository, defaults to "main".`)
 	cmd.Flags().StringVar(&f.SharedConfigRepoSSHPemPath, flagSharedConfigRepoSSHPemPath, "", `Path to the SSH private key file to use for downloading the shared configuration repository.`)
 	cmd.Flags().StringVar(&f.SharedConfigRepoSSHPemPassword, flagSharedConfigRepoSSHPemPassword, "", `Passphrase to the shared configuration repository SSH private key.`)
+	cmd.Flags().StringVar(&f.SharedConfigRepoURL, flagSharedConfigRepoURL, "", `URL of the shared configuration repository. Supports https://, ssh://, file:// URLs and local paths. When empty the URL is built from the host, owner and name.`)
+	cmd.Flags().StringVar(&f.SharedConfigReposFile, flagSharedConfigReposFile, "", `Path to a YAML list of shared configuration repositories overlaid in order onto the configuration repository. Each entry has name, owner, ref, url, key, password and refuseProtectedOverrides fields. Takes precedence over the --shared-config-repo-* flags.`)
 	cmd.Flags().StringVar(&f.ConfigRepoSSHPemPath, flagConfigRepoSSHPemPath, "", `Path to the SSH private key file to use for downloading the configuration repository.`)
 	cmd.Flags().StringVar(&f.ConfigRepoSSHPemPassword, flagConfigRepoSSHPemPassword, "", `Passphrase to the config repo SSH private key.`)
+	cmd.Flags().StringVar(&f.Decrypt, flagDecrypt, decryptVault, fmt.Sprintf(`Secret decryption mode. One of %q, %q, %q, %q or %q. Modes other than %q do not need Vault access.`, decryptVault, decryptNone, decryptRedact, decryptKeyFile, decryptSOPS, decryptVault))
+	cmd.Flags().StringVar(&f.DecryptKeyFile, flagDecryptKeyFile, "", fmt.Sprintf(`Path to the exported Vault transit key used with --%s=%s.`, flagDecrypt, decryptKeyFile))
+	cmd.Flags().StringVar(&f.DecryptPlugin, flagDecryptPlugin, "", fmt.Sprintf(`Path to the exec plugin decrypting values and resolving references starting with --%s. It is used with all decryption modes.`, flagDecryptPluginPrefix))
+	cmd.Flags().StringVar(&f.DecryptPluginPrefix, flagDecryptPluginPrefix, "", fmt.Sprintf(`Prefix of the values handled by --%s, e.g. "op://".`, flagDecryptPlugin))
+	cmd.Flags().StringVar(&f.FromConfig, flagFromConfig, "", fmt.Sprintf(`Config CR in the cluster to reproduce the controller output for, in "<namespace>/<name>" format. Mutually exclusive with --%s and --%s. When set, --%s and --%s are derived from the CR.`, flagApp, flagFromConfigFile, flagName, flagNamespace))
+	cmd.Flags().StringVar(&f.FromConfigFile, flagFromConfigFile, "", fmt.Sprintf(`Path to the YAML file of the Config CR to reproduce the controller output for. Mutually exclusive with --%s and --%s. When set, --%s and --%s are derived from the CR.`, flagApp, flagFromConfig, flagName, flagNamespace))
+	cmd.Flags().StringVar(&f.GitCacheDir, flagGitCacheDir, "", `Directory of the on-disk git repository cache. Repeated runs fetch only new commits. When empty repositories are cloned in memory.`)
+	cmd.Flags().StringVar(&f.GitHost, flagGitHost, "github.com", `Git host serving the repositories without configured URL, e.g. "gitlab.com".`)
+	cmd.Flags().StringVar(&f.GitHubAPIURL, flagGitHubAPIURL, "https://api.github.com", fmt.Sprintf(`URL of the GitHub API used to mint tokens for --%s.`, flagGitHubAppID))
+	cmd.Flags().Int64Var(&f.GitHubAppID, flagGitHubAppID, 0, fmt.Sprintf(`ID of the GitHub App used to access repositories over HTTPS. Takes precedence over --%s.`, flagGithubToken))
+	cmd.Flags().Int64Var(&f.GitHubAppInstallationID, flagGitHubAppInstallationID, 0, fmt.Sprintf(`ID of the installation of the GitHub App set with --%s.`, flagGitHubAppID))
+	cmd.Flags().StringVar(&f.GitHubAppPrivateKeyPath, flagGitHubAppPrivateKeyPath, "", fmt.Sprintf(`Path to the PEM encoded private key of the GitHub App set with --%s.`, flagGitHubAppID))
 	cmd.Flags().StringVar(&f.GitHubToken, flagGithubToken, "", fmt.Sprintf(`GitHub token to use for "opsctl create vaultconfig" calls. Defaults to the value of %s env var.`, envConfigControllerGithubToken))
 	cmd.Flags().StringVar(&f.RepositoryName, flagRepositoryName, "config", `Repository name where configs are stored under the giantswarm organization, defaults to "config".`)
+	cmd.Flags().StringVar(&f.RepositoryOwner, flagRepositoryOwner, "giantswarm", `Owner of the configuration repository, e.g. the GitHub organization or GitLab group.`)
 	cmd.Flags().StringVar(&f.RepositoryRef, flagRepositoryRef, "main", `Repository branch to use, defaults to "main"`)
+	cmd.Flags().StringVar(&f.RepositoryToken, flagRepositoryToken, "", fmt.Sprintf(`Token used to pull the configuration repository over HTTPS, e.g. when --%s is on another host than --%s. The --%s token and GitHub App tokens are only sent to their own hosts. Defaults to the value of %s env var.`, flagRepositoryURL, flagGitHost, flagGithubToken, envRepositoryToken))
+	cmd.Flags().StringVar(&f.RepositoryURL, flagRepositoryURL, "", `URL of the configuration repository. Supports https://, ssh://, file:// URLs and local paths, e.g. a local clone. When empty the URL is built from the host, owner and name.`)
+	cmd.Flags().StringVar(&f.RepositoryUsername, flagRepositoryUsername, "", fmt.Sprintf(`Username sent with --%s. Defaults to "x-access-token".`, flagRepositoryToken))
 	cmd.Flags().StringVar(&f.Installation, flagInstallation, "", `Installation codename (e.g. "gauss").`)
+	cmd.Flags().StringVar(&f.Kubeconfig, flagKubeconfig, "", `Path to the kubeconfig file used to get the Config CR set with --from-config. Defaults to the standard kubeconfig loading rules.`)
 	cmd.Flags().StringVar(&f.Name, flagName, "giantswarm", `Name of the generated ConfigMap/Secret.`)
 	cmd.Flags().StringVar(&f.Namespace, flagNamespace, "giantswarm", `Namespace of the generated ConfigMap/Secret.`)
+	cmd.Flags().StringVar(&f.PlaintextSecretCheck, flagPlaintextSecretCheck, pkggenerator.PlaintextSecretCheckWarn, fmt.Sprintf(`Check for values looking like secrets in the generated ConfigMap. One of %q, %q or %q.`, pkggenerator.PlaintextSecretCheckOff, pkggenerator.PlaintextSecretCheckWarn, pkggenerator.PlaintextSecretCheckFail))
 	cmd.Flags().BoolVar(&f.Raw, flagRaw, false, `Forces generator to output YAML instead of ConfigMap & Secret.`)
+	cmd.Flags().BoolVar(&f.Redact, flagRedact, false, fmt.Sprintf(`Replaces generated secret values with placeholders of the same type. Equal values get equal placeholders. Placeholders are stable between runs with the same --%s, so redacted outputs can be diffed. Use it to share the output without leaking secrets.`, flagRedactKey))
+	cmd.Flags().StringVar(&f.RedactKey, flagRedactKey, "", fmt.Sprintf(`Secret key of the placeholders used with --%s and --%s=%s. Keep it secret, placeholders can be used to confirm guesses of the values with the key. When empty a random key is used and placeholders differ between runs. Defaults to the value of %s env var.`, flagRedact, flagDecrypt, decryptRedact, envRedactKey))
+	cmd.Flags().StringVar(&f.SOPSAgeKeyFile, flagSOPSAgeKeyFile, "", fmt.Sprintf(`Path to the age identity file used with --%s=%s. Defaults to the value of %s env var.`, flagDecrypt, decryptSOPS, envSOPSAgeKeyFile))
+	cmd.Flags().StringVar(&f.SOPSPGPKeyFile, flagSOPSPGPKeyFile, "", fmt.Sprintf(`Path to the armored PGP private key file used with --%s=%s.`, flagDecrypt, decryptSOPS))
+	cmd.Flags().StringVar(&f.SSHKnownHosts, flagSSHKnownHosts, "", `Path to the known_hosts file SSH host keys of git servers are strictly verified against. Defaults to $SSH_KNOWN_HOSTS or ~/.ssh/known_hosts.`)
 	cmd.Flags().StringVar(&f.SSHUser, flagSSHUser, "", `User to be passed to opsctl.`)
+	cmd.Flags().StringVar(&f.TrustedGPGKeys, flagTrustedGPGKeys, "", `Path to the armored GPG key ring trusted to sign commits. When set, only signed commits of the config and shared configs repositories are used.`)
+	cmd.Flags().StringVar(&f.TrustedSSHKeys, flagTrustedSSHKeys, "", `Path to the file with SSH public keys in authorized_keys format trusted to sign commits. When set, only signed commits of the config and shared configs repositories are used.`)
+	cmd.Flags().StringVar(&f.VaultAddress, flagVaultAddress, "", `Vault address. When set, opsctl is not used to create the Vault client.`)
+	cmd.Flags().StringVar(&f.VaultCAPath, flagVaultCAPath, "", fmt.Sprintf(`Path to the directory with Vault CA certificates. Defaults to the value of %s env var.`, envVaultCAPath))
+	cmd.Flags().StringSliceVar(&f.VaultKVAllowedPathPrefixes, flagVaultKVAllowedPathPrefix, nil, `Vault path "vault:<path>#<field>" references may read from, e.g. "kv/data/apps". Can be repeated. References to other paths are rejected like in the controller.`)
+	cmd.Flags().StringVar(&f.VaultToken, flagVaultToken, "", fmt.Sprintf(`Vault token. Defaults to the value of %s env var.`, envVaultToken))
+	cmd.Flags().StringVar(&f.VaultTransitKey, flagVaultTransitKey, decrypt.DefaultKey, `Name of the Vault transit key used to decrypt secrets. Apps can override it in their metadata.yaml.`)
 	cmd.Flags().BoolVar(&f.Verbose, flagVerbose, false, `Enables generator to output consecutive generation stages.`)
 }
 
 func (f *flag) Validate() error {
-	if f.App == "" {
-		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagApp)
+	{
+		var set int
+		for _, v := range []string{f.App, f.FromConfig, f.FromConfigFile} {
+			if v != "" {
+				set++
+			}
+		}
+		if set == 0 {
+			return microerror.Maskf(invalidFlagError, "--%s, --%s or --%s must not be empty", flagApp, flagFromConfig, flagFromConfigFile)
+		}
+		if set > 1 {
+			return microerror.Maskf(invalidFlagError, "--%s, --%s and --%s are mutually exclusive", flagApp, flagFromConfig, flagFromConfigFile)
+		}
+	}
+	if f.FromConfig != "" {
+		namespace, name, ok := strings.Cut(f.FromConfig, "/")
+		if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+			return microerror.Maskf(invalidFlagError, "--%s must be in \"<namespace>/<name>\" format, use --%s for files", flagFromConfig, flagFromConfigFile)
+		}
 	}
 	if f.GitHubToken == "" {
 		f.GitHubToken = os.Getenv(envConfigControllerGithubToken)
 	}
-	if f.GitHubToken == "" && f.ConfigRepoSSHPemPath == "" {
+	if f.RepositoryToken == "" {
+		f.RepositoryToken = os.Getenv(envRepositoryToken)
+	}
+	if f.RedactKey == "" {
+		f.RedactKey = os.Getenv(envRedactKey)
+	}
+	if f.GitHubAppID != 0 {
+		if f.GitHubAppInstallationID == 0 {
+			return microerror.Maskf(invalidFlagError, "--%s must not be empty when --%s is set", flagGitHubAppInstallationID, flagGitHubAppID)
+		}
+		if f.GitHubAppPrivateKeyPath == "" {
+			return microerror.Maskf(invalidFlagError, "--%s must not be empty when --%s is set", flagGitHubAppPrivateKeyPath, flagGitHubAppID)
+		}
+	}
+	if f.GitHubToken == "" && f.GitHubAppID == 0 && f.ConfigRepoSSHPemPath == "" && f.RepositoryURL == "" {
 		return microerror.Maskf(
 			invalidFlagError,
-			"--%s or $%s must not be empty when SSH credentials are not provided for the config repository either.",
-			flagGithubToken, envConfigControllerGithubToken)
+			"--%s, $%s or --%s must not be empty when SSH credentials are not provided for the config repository and --%s is not set either.",
+			flagGithubToken, envConfigControllerGithubToken, flagGitHubAppID, flagRepositoryURL)
+	}
+	switch f.Decrypt {
+	case decryptVault:
+		if f.VaultCAPath == "" {
+			f.VaultCAPath = os.Getenv(envVaultCAPath)
+		}
+		if f.VaultToken == "" {
+			f.VaultToken = os.Getenv(envVaultToken)
+		}
+		if f.VaultAddress != "" && f.VaultToken == "" {
+			return microerror.Maskf(invalidFlagError, "--%s or $%s must not be empty when --%s is set", flagVaultToken, envVaultToken, flagVaultAddress)
+		}
+	case decryptKeyFile:
+		if f.DecryptKeyFile == "" {
+			return microerror.Maskf(invalidFlagError, "--%s must not be empty when --%s=%s", flagDecryptKeyFile, flagDecrypt, decryptKeyFile)
+		}
+	case decryptSOPS:
+		if f.SOPSAgeKeyFile == "" {
+			f.SOPSAgeKeyFile = os.Getenv(envSOPSAgeKeyFile)
+		}
+		if f.SOPSAgeKeyFile == "" && f.SOPSPGPKeyFile == "" {
+			return microerror.Maskf(invalidFlagError, "--%s, $%s or --%s must not be empty when --%s=%s", flagSOPSAgeKeyFile, envSOPSAgeKeyFile, flagSOPSPGPKeyFile, flagDecrypt, decryptSOPS)
+		}
+	case decryptNone, decryptRedact:
+	default:
+		return microerror.Maskf(invalidFlagError, "--%s must be one of %q, %q, %q, %q or %q", flagDecrypt, decryptVault, decryptNone, decryptRedact, decryptKeyFile, decryptSOPS)
+	}
+	if f.DecryptPlugin != "" && f.DecryptPluginPrefix == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty when --%s is set", flagDecryptPluginPrefix, flagDecryptPlugin)
 	}
 	if f.Installation == "" {
 		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagInstallation)
@@ -89,6 +268,11 @@ func (f *flag) Validate() error {
 	if f.Namespace == "" {
 		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagNamespace)
 	}
+	switch f.PlaintextSecretCheck {
+	case pkggenerator.PlaintextSecretCheckOff, pkggenerator.PlaintextSecretCheckWarn, pkggenerator.PlaintextSecretCheckFail:
+	default:
+		return microerror.Maskf(invalidFlagError, "--%s must be one of %q, %q or %q", flagPlaintextSecretCheck, pkggenerator.PlaintextSecretCheckOff, pkggenerator.PlaintextSecretCheckWarn, pkggenerator.PlaintextSecretCheckFail)
+	}
 
 	return nil
 }
@@ -12,12 +12,15 @@ import (
 	"github.com/ghodss/yaml"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
-	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/spf13/cobra"
 
 	"github.com/giantswarm/config-controller/internal/generator"
+	"github.com/giantswarm/config-controller/internal/githubapp"
 	"github.com/giantswarm/config-controller/internal/meta"
 	"github.com/giantswarm/config-controller/internal/ssh"
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+	pkggenerator "github.com/giantswarm/config-controller/pkg/generator"
+	"github.com/giantswarm/config-controller/pkg/k8sresource"
 )
 
 type runner struct {
@@ -25,6 +28,10 @@ type runner struct {
 	logger micrologger.Logger
 	stdout io.Writer
 	stderr io.Writer
+
+	// redactor redacts decrypted values and, with --decrypt=redact,
+	// ciphertexts of a run.
+	redactor *decrypt.Redactor
 }
 
 func (r *runner) Run(cmd *cobra.Command, args []string) error {
@@ -46,9 +53,21 @@ func (r *runner) Run(cmd *cobra.Command, args []string) error {
 func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
 	var err error
 
-	var vaultClient *vaultapi.Client
 	{
-		vaultClient, err = createVaultClientUsingOpsctl(ctx, r.flag.GitHubToken, r.flag.SSHUser, r.flag.Installation)
+		c := decrypt.RedactorConfig{
+			Key: []byte(r.flag.RedactKey),
+		}
+
+		r.redactor, err = decrypt.NewRedactor(c)
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var decryptTraverser pkggenerator.DecryptTraverser
+	var secretResolver pkggenerator.SecretResolver
+	{
+		decryptTraverser, secretResolver, err = r.newDecryptTraverser(ctx)
 		if err != nil {
 			return microerror.Mask(err)
 		}
@@ -70,26 +89,97 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 		}
 	}
 
+	var gitHubApp githubapp.Credential
+	if r.flag.GitHubAppID != 0 {
+		privateKey, err := os.ReadFile(r.flag.GitHubAppPrivateKeyPath)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		gitHubApp = githubapp.Credential{
+			APIURL:         r.flag.GitHubAPIURL,
+			AppID:          r.flag.GitHubAppID,
+			InstallationID: r.flag.GitHubAppInstallationID,
+			PrivateKey:     string(privateKey),
+		}
+	}
+
+	var sshKnownHosts []byte
+	if r.flag.SSHKnownHosts != "" {
+		sshKnownHosts, err = os.ReadFile(r.flag.SSHKnownHosts)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var trustedGPGKeys []byte
+	if r.flag.TrustedGPGKeys != "" {
+		trustedGPGKeys, err = os.ReadFile(r.flag.TrustedGPGKeys)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var trustedSSHKeys []byte
+	if r.flag.TrustedSSHKeys != "" {
+		trustedSSHKeys, err = os.ReadFile(r.flag.TrustedSSHKeys)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	sharedConfigRepositories := []shared.ConfigRepository{
+		{
+			Name:     r.flag.SharedConfigRepoName,
+			Owner:    r.flag.SharedConfigRepoOwner,
+			Ref:      r.flag.SharedConfigRepoRef,
+			URL:      r.flag.SharedConfigRepoURL,
+			Key:      sharedConfigRepositorySSHKey,
+			Password: r.flag.SharedConfigRepoSSHPemPassword,
+
+			RefuseProtectedOverrides: r.flag.SharedConfigRepoProtected,
+		},
+	}
+	if r.flag.SharedConfigReposFile != "" {
+		data, err := os.ReadFile(r.flag.SharedConfigReposFile)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		sharedConfigRepositories, err = shared.ParseConfigRepositories(data)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
 	var gen *generator.Service
 	{
 		c := generator.Config{
-			VaultClient: vaultClient,
+			DecryptTraverser:        decryptTraverser,
+			PlaintextSecretReporter: &stderrReporter{stderr: r.stderr},
+			SecretResolver:          secretResolver,
 
-			SharedConfigRepository: shared.ConfigRepository{
-				Name:     r.flag.SharedConfigRepoName,
-				Ref:      r.flag.SharedConfigRepoRef,
-				Key:      sharedConfigRepositorySSHKey,
-				Password: r.flag.SharedConfigRepoSSHPemPassword,
-			},
+			SharedConfigRepositories: sharedConfigRepositories,
 			ConfigRepoSSHCredential: ssh.Credential{
 				Key:      configRepoSshKey,
 				Password: r.flag.ConfigRepoSSHPemPassword,
 			},
-			GitHubToken:    r.flag.GitHubToken,
-			RepositoryName: r.flag.RepositoryName,
-			RepositoryRef:  r.flag.RepositoryRef,
-			Installation:   r.flag.Installation,
-			Verbose:        r.flag.Verbose,
+			GitCacheDir:          r.flag.GitCacheDir,
+			GitHost:              r.flag.GitHost,
+			GitHubApp:            gitHubApp,
+			GitHubToken:          r.flag.GitHubToken,
+			RepositoryOwner:      r.flag.RepositoryOwner,
+			RepositoryName:       r.flag.RepositoryName,
+			RepositoryRef:        r.flag.RepositoryRef,
+			RepositoryURL:        r.flag.RepositoryURL,
+			RepositoryUsername:   r.flag.RepositoryUsername,
+			RepositoryToken:      r.flag.RepositoryToken,
+			SSHKnownHosts:        string(sshKnownHosts),
+			TrustedGPGKeys:       string(trustedGPGKeys),
+			TrustedSSHKeys:       string(trustedSSHKeys),
+			Installation:         r.flag.Installation,
+			PlaintextSecretCheck: r.flag.PlaintextSecretCheck,
+			Verbose:              r.flag.Verbose,
 		}
 
 		gen, err = generator.New(c)
@@ -98,26 +188,67 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 		}
 	}
 
-	in := generator.GenerateInput{
-		App: r.flag.App,
+	var in generator.GenerateInput
+	if r.flag.FromConfig != "" || r.flag.FromConfigFile != "" {
+		config, err := r.readConfig(ctx)
+		if err != nil {
+			return microerror.Mask(err)
+		}
 
-		Name:      r.flag.Name,
-		Namespace: r.flag.Namespace,
+		// The controller only reconciles Config CRs with the version label
+		// matching its own, so the label tells whether the CR was
+		// reconciled by the unique app.
+		uniqueApp := config.GetLabels()[meta.Label.Version.Key()] == meta.Label.Version.Val(true)
 
-		ExtraAnnotations: map[string]string{
-			meta.Annotation.XAppInfo.Key():        meta.Annotation.XAppInfo.Val("<unknown>", r.flag.App, "<unknown>"),
-			meta.Annotation.XCreator.Key():        meta.Annotation.Default(),
-			meta.Annotation.XInstallation.Key():   r.flag.Installation,
-			meta.Annotation.XProjectVersion.Key(): meta.Annotation.XProjectVersion.Val(false),
-		},
-		ExtraLabels: nil,
+		in, err = generator.NewGenerateInputFromConfig(config, r.flag.Installation, uniqueApp)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else {
+		in = generator.GenerateInput{
+			App: r.flag.App,
+
+			Name:      r.flag.Name,
+			Namespace: r.flag.Namespace,
+
+			ExtraAnnotations: map[string]string{
+				meta.Annotation.XAppInfo.Key():        meta.Annotation.XAppInfo.Val("<unknown>", r.flag.App, "<unknown>"),
+				meta.Annotation.XCreator.Key():        meta.Annotation.Default(),
+				meta.Annotation.XInstallation.Key():   r.flag.Installation,
+				meta.Annotation.XProjectVersion.Key(): meta.Annotation.XProjectVersion.Val(false),
+			},
+			ExtraLabels: nil,
+		}
 	}
 
-	configmap, secret, err := gen.Generate(ctx, in)
+	configmap, secret, _, err := gen.Generate(ctx, in)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
+	// Set the object hash the same way the controller does. The order
+	// matters as the ConfigMap and the Secret share the annotations map.
+	if r.flag.FromConfig != "" || r.flag.FromConfigFile != "" {
+		err = k8sresource.SetHash(meta.Annotation.XObjectHash.Key(), configmap)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = k8sresource.SetHash(meta.Annotation.XObjectHash.Key(), secret)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	if r.flag.Redact {
+		redacted, err := pkggenerator.Redact(secret.Data["secret-values.yaml"], r.redactor)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		secret.Data["secret-values.yaml"] = redacted
+	}
+
 	if r.flag.Raw {
 		fmt.Println("---")
 		fmt.Println(configmap.Data["configmap-values.yaml"])
@@ -152,3 +283,14 @@ func (r *runner) readSSHPem(path string) (string, error) {
 
 	return string(keyByte), nil
 }
+
+// stderrReporter prints ConfigMap values looking like secrets as warnings.
+type stderrReporter struct {
+	stderr io.Writer
+}
+
+func (r *stderrReporter) ReportPlaintextSecrets(ctx context.Context, app string, secrets []pkggenerator.PlaintextSecret) {
+	for _, s := range secrets {
+		fmt.Fprintf(r.stderr, "Warning: configmap-values for app %#q contain a value looking like a secret at %s\n", app, s)
+	}
+}
@@ -0,0 +1,82 @@
+package secret
+
+import (
+	"io"
+	"os"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/cmd/secret/rewrap"
+	"github.com/giantswarm/config-controller/cmd/secret/set"
+)
+
+const (
+	name        = "secret"
+	description = "Manage encrypted secrets in a local configuration repository checkout."
+)
+
+type Config struct {
+	Logger micrologger.Logger
+	Stderr io.Writer
+	Stdin  io.Reader
+	Stdout io.Writer
+}
+
+func New(config Config) (*cobra.Command, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Stderr == nil {
+		config.Stderr = os.Stderr
+	}
+	if config.Stdin == nil {
+		config.Stdin = os.Stdin
+	}
+	if config.Stdout == nil {
+		config.Stdout = os.Stdout
+	}
+
+	var err error
+
+	var rewrapCmd *cobra.Command
+	{
+		c := rewrap.Config{
+			Logger: config.Logger,
+			Stderr: config.Stderr,
+			Stdout: config.Stdout,
+		}
+
+		rewrapCmd, err = rewrap.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var setCmd *cobra.Command
+	{
+		c := set.Config{
+			Logger: config.Logger,
+			Stderr: config.Stderr,
+			Stdin:  config.Stdin,
+			Stdout: config.Stdout,
+		}
+
+		setCmd, err = set.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	c := &cobra.Command{
+		Use:   name,
+		Short: description,
+		Long:  description,
+	}
+
+	c.AddCommand(rewrapCmd)
+	c.AddCommand(setCmd)
+
+	return c, nil
+}
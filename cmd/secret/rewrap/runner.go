@@ -0,0 +1,243 @@
+package rewrap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/internal/vault"
+	"github.com/giantswarm/config-controller/internal/yamledit"
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+	"github.com/giantswarm/config-controller/pkg/generator"
+)
+
+type runner struct {
+	flag   *flag
+	logger micrologger.Logger
+	stderr io.Writer
+	stdout io.Writer
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	var err error
+
+	root := filepath.Join(r.flag.RepositoryPath, "installations")
+	if r.flag.Installation != "" {
+		root = filepath.Join(root, r.flag.Installation)
+	}
+
+	var paths []string
+	{
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if d.Name() == "secret.yaml" || d.Name() == "secret-values.yaml.patch" {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var vaultClient *vaultapi.Client
+	if r.flag.VaultAddress != "" {
+		c := vault.ClientConfig{
+			Address: r.flag.VaultAddress,
+			Token:   r.flag.VaultToken,
+			CAPath:  r.flag.VaultCAPath,
+		}
+
+		vaultClient, err = vault.NewClient(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else {
+		vaultClient, err = vault.NewClientUsingOpsctl(ctx, "", r.flag.SSHUser, r.flag.Installation)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	rewrappers := map[string]*decrypt.VaultRewrapper{}
+
+	var failed []string
+	for _, path := range paths {
+		key, err := r.transitKey(ctx, path)
+		if err != nil {
+			fmt.Fprintf(r.stderr, "Failed to rewrap %s: %s\n", path, microerror.Pretty(err, false))
+			failed = append(failed, path)
+			continue
+		}
+
+		rewrapper, ok := rewrappers[key]
+		if !ok {
+			c := decrypt.VaultRewrapperConfig{
+				VaultClient: vaultClient,
+				Key:         key,
+			}
+
+			rewrapper, err = decrypt.NewVaultRewrapper(c)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			rewrappers[key] = rewrapper
+		}
+
+		n, err := r.rewrapFile(ctx, rewrapper, path)
+		if err != nil {
+			fmt.Fprintf(r.stderr, "Failed to rewrap %s: %s\n", path, microerror.Pretty(err, false))
+			failed = append(failed, path)
+			continue
+		}
+
+		if n == 0 {
+			continue
+		}
+
+		if r.flag.DryRun {
+			fmt.Fprintf(r.stdout, "Would rewrap %d value(s) in %s\n", n, path)
+		} else {
+			fmt.Fprintf(r.stdout, "Rewrapped %d value(s) in %s\n", n, path)
+		}
+	}
+
+	if len(failed) > 0 {
+		return microerror.Maskf(executionFailedError, "failed to rewrap %d file(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// transitKey returns the name of the Vault transit key the file is encrypted
+// with. App secret-values patches use the key configured in the App
+// metadata like in the generator. All other files use the key given with the
+// flag.
+func (r *runner) transitKey(ctx context.Context, path string) (string, error) {
+	rel, err := filepath.Rel(r.flag.RepositoryPath, path)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	// installations/<installation>/apps/<app>/secret-values.yaml.patch
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 5 || parts[0] != "installations" || parts[2] != "apps" || parts[4] != "secret-values.yaml.patch" {
+		return r.flag.VaultTransitKey, nil
+	}
+
+	fs := dirFilesystem(r.flag.RepositoryPath)
+	key, err := generator.AppEncryptionKey(ctx, fs, parts[1], parts[3])
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	if key == "" {
+		return r.flag.VaultTransitKey, nil
+	}
+
+	return key, nil
+}
+
+// rewrapFile rewraps all ciphertext values in the file and returns the number
+// of rewrapped values. Only the rewrapped YAML values are rewritten, so
+// comments and formatting are kept.
+func (r *runner) rewrapFile(ctx context.Context, rewrapper *decrypt.VaultRewrapper, path string) (int, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	data, n, err := yamledit.ReplaceScalars(data, func(value string) (string, error) {
+		if !strings.HasPrefix(value, decrypt.CiphertextPrefix) {
+			return value, nil
+		}
+
+		rewrapped, err := rewrapper.Rewrap(ctx, []byte(value))
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return string(rewrapped), nil
+	})
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	if !r.flag.DryRun {
+		err = os.WriteFile(path, data, stat.Mode())
+		if err != nil {
+			return 0, microerror.Mask(err)
+		}
+	}
+
+	return n, nil
+}
+
+// dirFilesystem implements generator.Filesystem for the local repository
+// checkout.
+type dirFilesystem string
+
+func (d dirFilesystem) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(string(d), filepath.FromSlash(path)))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return data, nil
+}
+
+func (d dirFilesystem) ReadDir(path string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(string(d), filepath.FromSlash(path)))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,102 @@
+package rewrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunner_Run(t *testing.T) {
+	// The fake Vault rewraps "vault:v1:<data>" to "vault:v2:<key>:<data>",
+	// so the expected files show which key each value was rewrapped with.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]interface{}
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/transit/keys/"):
+			data = map[string]interface{}{"latest_version": 2}
+		case r.Method == http.MethodPut || r.Method == http.MethodPost:
+			var body struct {
+				Ciphertext string `json:"ciphertext"`
+			}
+			err := json.NewDecoder(r.Body).Decode(&body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			key := strings.TrimPrefix(r.URL.Path, "/v1/transit/rewrap/")
+			data = map[string]interface{}{"ciphertext": "vault:v2:" + key + ":" + strings.TrimPrefix(body.Ciphertext, "vault:v1:")}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	files := map[string]string{
+		"default/apps/foo/metadata.yaml":                          "encryptionKey: foo\n",
+		"installations/puma/secret.yaml":                          "# vault:v1:a\na: vault:v1:a\n",
+		"installations/puma/apps/foo/secret-values.yaml.patch":    "b: vault:v1:b # vault:v1:b\n",
+		"installations/puma/apps/bar/secret-values.yaml.patch":    "c:\n  - vault:v1:c\n  - vault:v2:c\n",
+		"installations/puma/apps/bar/configmap-values.yaml.patch": "d: vault:v1:d\n",
+	}
+	expectedFiles := map[string]string{
+		"installations/puma/secret.yaml":                          "# vault:v1:a\na: vault:v2:config:a\n",
+		"installations/puma/apps/foo/secret-values.yaml.patch":    "b: vault:v2:foo:b # vault:v1:b\n",
+		"installations/puma/apps/bar/secret-values.yaml.patch":    "c:\n  - vault:v2:config:c\n  - vault:v2:c\n",
+		"installations/puma/apps/bar/configmap-values.yaml.patch": "d: vault:v1:d\n",
+	}
+
+	dir := t.TempDir()
+	for p, data := range files {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		err := os.MkdirAll(filepath.Dir(p), 0750)
+		if err != nil {
+			t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+		}
+		err = os.WriteFile(p, []byte(data), 0600)
+		if err != nil {
+			t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+		}
+	}
+
+	var stdout bytes.Buffer
+	r := &runner{
+		flag: &flag{
+			RepositoryPath:  dir,
+			VaultAddress:    server.URL,
+			VaultToken:      "token",
+			VaultTransitKey: "config",
+		},
+		logger: microloggertest.New(),
+		stderr: &bytes.Buffer{},
+		stdout: &stdout,
+	}
+
+	err := r.Run(nil, nil)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	for p, expected := range expectedFiles {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil {
+			t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+		}
+		if !cmp.Equal(expected, string(data)) {
+			t.Fatalf("%s\n\n%s\n", p, cmp.Diff(expected, string(data)))
+		}
+	}
+}
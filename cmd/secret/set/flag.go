@@ -0,0 +1,76 @@
+package set
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+)
+
+const (
+	flagInstallation    = "installation"
+	flagPath            = "path"
+	flagRepositoryPath  = "repository-path"
+	flagSSHUser         = "ssh-user"
+	flagVaultAddress    = "vault-address"
+	flagVaultCAPath     = "vault-ca-path"
+	flagVaultToken      = "vault-token" // #nosec G101
+	flagVaultTransitKey = "vault-transit-key"
+
+	envVaultCAPath = "VAULT_CAPATH"
+	envVaultToken  = "VAULT_TOKEN" //nolint:gosec
+)
+
+type flag struct {
+	Installation    string
+	Path            string
+	RepositoryPath  string
+	SSHUser         string
+	VaultAddress    string
+	VaultCAPath     string
+	VaultToken      string
+	VaultTransitKey string
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.Installation, flagInstallation, "", `Installation codename (e.g. "gauss").`)
+	cmd.Flags().StringVar(&f.Path, flagPath, "", `Dot separated path of the value in secret.yaml (e.g. "aws.secretAccessKey").`)
+	cmd.Flags().StringVar(&f.RepositoryPath, flagRepositoryPath, ".", `Path to the local configuration repository checkout.`)
+	cmd.Flags().StringVar(&f.SSHUser, flagSSHUser, "", `User to be passed to opsctl.`)
+	cmd.Flags().StringVar(&f.VaultAddress, flagVaultAddress, "", `Vault address. When set, opsctl is not used to create the Vault client.`)
+	cmd.Flags().StringVar(&f.VaultCAPath, flagVaultCAPath, "", fmt.Sprintf(`Path to the directory with Vault CA certificates. Defaults to the value of %s env var.`, envVaultCAPath))
+	cmd.Flags().StringVar(&f.VaultToken, flagVaultToken, "", fmt.Sprintf(`Vault token. Defaults to the value of %s env var.`, envVaultToken))
+	cmd.Flags().StringVar(&f.VaultTransitKey, flagVaultTransitKey, decrypt.DefaultKey, `Name of the Vault transit key. Use the key configured in the App metadata.yaml for App secrets.`)
+}
+
+func (f *flag) Validate() error {
+	if f.Installation == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagInstallation)
+	}
+	if f.Path == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagPath)
+	}
+	for _, p := range strings.Split(f.Path, ".") {
+		if p == "" {
+			return microerror.Maskf(invalidFlagError, "--%s must not contain empty path elements", flagPath)
+		}
+	}
+	if f.RepositoryPath == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagRepositoryPath)
+	}
+	if f.VaultCAPath == "" {
+		f.VaultCAPath = os.Getenv(envVaultCAPath)
+	}
+	if f.VaultToken == "" {
+		f.VaultToken = os.Getenv(envVaultToken)
+	}
+	if f.VaultAddress != "" && f.VaultToken == "" {
+		return microerror.Maskf(invalidFlagError, "--%s or $%s must not be empty when --%s is set", flagVaultToken, envVaultToken, flagVaultAddress)
+	}
+
+	return nil
+}
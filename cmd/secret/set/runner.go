@@ -0,0 +1,132 @@
+package set
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/internal/vault"
+	"github.com/giantswarm/config-controller/internal/yamledit"
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+)
+
+type runner struct {
+	flag   *flag
+	logger micrologger.Logger
+	stderr io.Writer
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	var err error
+
+	installationPath := filepath.Join(r.flag.RepositoryPath, "installations", r.flag.Installation)
+	secretPath := filepath.Join(installationPath, "secret.yaml")
+
+	// Fail early on installation typos before asking for the secret.
+	_, err = os.Stat(installationPath)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var plaintext []byte
+	{
+		plaintext, err = io.ReadAll(r.stdin)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		// Drop the newline added by "echo" and terminals.
+		plaintext = []byte(strings.TrimSuffix(string(plaintext), "\n"))
+	}
+
+	var vaultClient *vaultapi.Client
+	if r.flag.VaultAddress != "" {
+		c := vault.ClientConfig{
+			Address: r.flag.VaultAddress,
+			Token:   r.flag.VaultToken,
+			CAPath:  r.flag.VaultCAPath,
+		}
+
+		vaultClient, err = vault.NewClient(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else {
+		vaultClient, err = vault.NewClientUsingOpsctl(ctx, "", r.flag.SSHUser, r.flag.Installation)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var encrypter *decrypt.VaultEncrypter
+	{
+		c := decrypt.VaultEncrypterConfig{
+			VaultClient: vaultClient,
+			Key:         r.flag.VaultTransitKey,
+		}
+
+		encrypter, err = decrypt.NewVaultEncrypter(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	ciphertext, err := encrypter.Encrypt(ctx, plaintext)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// A missing secret.yaml is created.
+	var data []byte
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(secretPath); err == nil {
+		mode = stat.Mode()
+
+		data, err = os.ReadFile(filepath.Clean(secretPath))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else if !os.IsNotExist(err) {
+		return microerror.Mask(err)
+	}
+
+	data, err = yamledit.Set(data, strings.Split(r.flag.Path, "."), string(ciphertext))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = os.WriteFile(secretPath, data, mode)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Fprintf(r.stdout, "Set %#q in %s\n", r.flag.Path, secretPath)
+
+	return nil
+}
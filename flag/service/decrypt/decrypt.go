@@ -0,0 +1,22 @@
+package decrypt
+
+type Decrypt struct {
+	Backend   string
+	K8sSecret K8sSecret
+	Plugin    Plugin
+	SOPS      SOPS
+}
+
+type K8sSecret struct {
+	AllowedNamespaces string
+}
+
+type Plugin struct {
+	Command string
+	Prefix  string
+}
+
+type SOPS struct {
+	AgeKeyFile string
+	PGPKeyFile string
+}
@@ -0,0 +1,5 @@
+package generator
+
+type Generator struct {
+	PlaintextSecretCheck string
+}
@@ -0,0 +1,8 @@
+package app
+
+type App struct {
+	APIURL         string
+	ID             string
+	InstallationID string
+	PrivateKey     string
+}
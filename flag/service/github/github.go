@@ -1,18 +1,39 @@
 package github
 
-import "github.com/giantswarm/config-controller/flag/service/github/ssh"
+import (
+	"github.com/giantswarm/config-controller/flag/service/github/app"
+	"github.com/giantswarm/config-controller/flag/service/github/signature"
+	"github.com/giantswarm/config-controller/flag/service/github/ssh"
+)
 
 type GitHub struct {
+	App                    app.App
+	CacheDir               string
+	Host                   string
+	MaxStaleness           string
+	PollInterval           string
 	RepositoryName         string
+	RepositoryOwner        string
 	RepositoryRef          string
+	RepositoryURL          string
+	RepositoryUsername     string
+	RepositoryToken        string
+	Signature              signature.Signature
 	SSH                    ssh.SSH
 	Token                  string
 	SharedConfigRepository SharedConfigRepository
+	// SharedConfigRepositoriesFile is the path of a YAML list of shared
+	// config repositories. It takes precedence over SharedConfigRepository.
+	SharedConfigRepositoriesFile string
 }
 
 type SharedConfigRepository struct {
 	Name     string
+	Owner    string
 	Ref      string
+	URL      string
 	Key      string
 	Password string
+
+	RefuseProtectedOverrides string
 }
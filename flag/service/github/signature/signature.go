@@ -0,0 +1,6 @@
+package signature
+
+type Signature struct {
+	TrustedGPGKeysFile string
+	TrustedSSHKeysFile string
+}
@@ -1,7 +1,8 @@
 package ssh
 
 type SSH struct {
-	Key      string
-	Password string
-	Username string
+	Key            string
+	KnownHostsFile string
+	Password       string
+	Username       string
 }
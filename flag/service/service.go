@@ -4,16 +4,22 @@ import (
 	"github.com/giantswarm/operatorkit/v7/pkg/flag/service/kubernetes"
 
 	"github.com/giantswarm/config-controller/flag/service/app"
+	"github.com/giantswarm/config-controller/flag/service/decrypt"
+	"github.com/giantswarm/config-controller/flag/service/generator"
 	"github.com/giantswarm/config-controller/flag/service/github"
 	"github.com/giantswarm/config-controller/flag/service/installation"
 	"github.com/giantswarm/config-controller/flag/service/vault"
+	"github.com/giantswarm/config-controller/flag/service/webhook"
 )
 
 // Service is an intermediate data structure for command line configuration flags.
 type Service struct {
 	App          app.App
+	Decrypt      decrypt.Decrypt
+	Generator    generator.Generator
 	GitHub       github.GitHub
 	Installation installation.Installation
 	Kubernetes   kubernetes.Kubernetes
 	Vault        vault.Vault
+	Webhook      webhook.Webhook
 }
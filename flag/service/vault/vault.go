@@ -1,6 +1,31 @@
 package vault
 
 type Vault struct {
-	Address string
-	Token   string
+	Address    string
+	Auth       Auth
+	KV         KV
+	Token      string
+	TransitKey string
+}
+
+type KV struct {
+	AllowedPathPrefixes string
+}
+
+type Auth struct {
+	AppRole    AppRole
+	Kubernetes Kubernetes
+	Method     string
+}
+
+type AppRole struct {
+	MountPath    string
+	RoleID       string
+	SecretIDFile string
+}
+
+type Kubernetes struct {
+	MountPath string
+	Role      string
+	TokenPath string
 }
@@ -0,0 +1,14 @@
+package webhook
+
+type Webhook struct {
+	GitHub GitHub
+	GitLab GitLab
+}
+
+type GitHub struct {
+	Secret string
+}
+
+type GitLab struct {
+	Token string
+}
@@ -0,0 +1,65 @@
+// Package affected maps paths changed in the config repositories to the
+// apps whose configuration they affect.
+package affected
+
+import (
+	"sort"
+	"strings"
+)
+
+// Apps returns the sorted names of the apps whose configuration for the
+// installation is affected by changes of the paths. All is true when the
+// changes affect the configuration of every app, e.g. changes of
+// default/config.yaml, include/* or installations/<installation>/secret.yaml.
+// Changes of other installations and files outside of the configuration
+// directories affect no app.
+func Apps(installation string, paths []string) (apps []string, all bool) {
+	byName := map[string]bool{}
+	for _, p := range paths {
+		parts := strings.Split(strings.TrimPrefix(p, "/"), "/")
+
+		switch parts[0] {
+		case "default":
+			// default/apps/<app>/...
+			if len(parts) > 3 && parts[1] == "apps" {
+				byName[parts[2]] = true
+			} else {
+				return nil, true
+			}
+		case "installations":
+			// installations/<installation>/apps/<app>/...
+			if len(parts) < 3 || parts[1] != installation {
+				continue
+			}
+			if len(parts) > 4 && parts[2] == "apps" {
+				byName[parts[3]] = true
+			} else {
+				return nil, true
+			}
+		case "include", "include-self", ".protected":
+			return nil, true
+		}
+	}
+
+	for name := range byName {
+		apps = append(apps, name)
+	}
+	sort.Strings(apps)
+
+	return apps, false
+}
+
+// SharedApps is like Apps for paths changed in a shared configs repository.
+// Only its default and include directories are merged into the config
+// repository.
+func SharedApps(installation string, paths []string) (apps []string, all bool) {
+	var shared []string
+	for _, p := range paths {
+		p = strings.TrimPrefix(p, "/")
+		if strings.HasPrefix(p, "default/") || strings.HasPrefix(p, "include/") || p == ".protected" {
+			shared = append(shared, p)
+		}
+	}
+
+	return Apps(installation, shared)
+}
@@ -0,0 +1,78 @@
+package affected
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApps(t *testing.T) {
+	testCases := []struct {
+		name         string
+		paths        []string
+		expectedApps []string
+		expectedAll  bool
+	}{
+		{
+			name: "case 0: app defaults and installation overrides",
+			paths: []string{
+				"default/apps/foo/configmap-values.yaml.template",
+				"installations/puma/apps/bar/secret-values.yaml.patch",
+				"default/apps/foo/metadata.yaml",
+			},
+			expectedApps: []string{"bar", "foo"},
+		},
+		{
+			name: "case 1: other installations and files are ignored",
+			paths: []string{
+				"installations/lion/config.yaml.patch",
+				"installations/lion/apps/foo/configmap-values.yaml.patch",
+				"README.md",
+			},
+		},
+		{
+			name: "case 2: default config affects all apps",
+			paths: []string{
+				"default/apps/foo/configmap-values.yaml.template",
+				"default/config.yaml",
+			},
+			expectedAll: true,
+		},
+		{
+			name:        "case 3: installation secret affects all apps",
+			paths:       []string{"installations/puma/secret.yaml"},
+			expectedAll: true,
+		},
+		{
+			name:        "case 4: includes affect all apps",
+			paths:       []string{"include/common.yaml.template"},
+			expectedAll: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			apps, all := Apps("puma", tc.paths)
+
+			if !reflect.DeepEqual(apps, tc.expectedApps) {
+				t.Fatalf("apps = %v, want %v", apps, tc.expectedApps)
+			}
+			if all != tc.expectedAll {
+				t.Fatalf("all = %t, want %t", all, tc.expectedAll)
+			}
+		})
+	}
+}
+
+func TestSharedApps(t *testing.T) {
+	apps, all := SharedApps("puma", []string{
+		"default/apps/foo/configmap-values.yaml.template",
+		"installations/puma/config.yaml.patch",
+	})
+
+	if !reflect.DeepEqual(apps, []string{"foo"}) {
+		t.Fatalf("apps = %v, want %v", apps, []string{"foo"})
+	}
+	if all {
+		t.Fatalf("all = true, want false")
+	}
+}
@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"crypto/sha1" // nolint:gosec
+	"encoding/json"
+	"fmt"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/config-controller/api/v1alpha1"
+	"github.com/giantswarm/config-controller/internal/meta"
+)
+
+// NewGenerateInputFromConfig creates GenerateInput for the given Config CR.
+// It is shared by the controller and the CLI so both generate exactly the
+// same ConfigMap and Secret for the same Config CR.
+func NewGenerateInputFromConfig(config *v1alpha1.Config, installation string, uniqueApp bool) (GenerateInput, error) {
+	name, err := StableObjectName(config)
+	if err != nil {
+		return GenerateInput{}, microerror.Mask(err)
+	}
+
+	// The controller only reconciles Config CRs with the version label
+	// matching its own project version annotation, so the label holds the
+	// version of the controller that reconciled the CR, also when the CLI
+	// reproduces its output.
+	version := config.GetLabels()[meta.Label.Version.Key()]
+	if version == "" {
+		version = meta.Annotation.XProjectVersion.Val(uniqueApp)
+	}
+
+	in := GenerateInput{
+		App: config.Spec.App.Name,
+
+		Name:      name,
+		Namespace: config.Namespace,
+
+		ExtraAnnotations: map[string]string{
+			meta.Annotation.XAppInfo.Key():        meta.Annotation.ValFromConfig(config),
+			meta.Annotation.XInstallation.Key():   installation,
+			meta.Annotation.XProjectVersion.Key(): version,
+		},
+		ExtraLabels: map[string]string{
+			meta.Label.ManagedBy.Key(): meta.Label.Default(),
+		},
+	}
+
+	return in, nil
+}
+
+// StableObjectName returns the name of the ConfigMap and Secret generated
+// for the given Config CR. The name changes only when .spec.app changes.
+func StableObjectName(config *v1alpha1.Config) (string, error) {
+	h, err := hash(config.Spec.App)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return setSuffixMax63(config.Name, h), nil
+}
+
+func hash(v interface{}) (string, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	sum := sha1.Sum(bs) // nolint:gosec
+	return fmt.Sprintf("%x", sum)[:10], nil
+}
+
+func setSuffixMax63(s string, suffix string) string {
+	maxLen := 63
+
+	if len(s)+len(suffix)+1 <= maxLen {
+		return s + "-" + suffix
+	}
+
+	return s[:maxLen-len(suffix)-1] + "-" + suffix
+}
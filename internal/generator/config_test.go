@@ -0,0 +1,57 @@
+package generator
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/config-controller/api/v1alpha1"
+	"github.com/giantswarm/config-controller/internal/meta"
+)
+
+func TestNewGenerateInputFromConfig(t *testing.T) {
+	testCases := []struct {
+		name            string
+		labels          map[string]string
+		uniqueApp       bool
+		expectedVersion string
+	}{
+		{
+			name:            "case 0: version of the reconciling controller",
+			labels:          map[string]string{meta.Label.Version.Key(): "0.9.0"},
+			expectedVersion: "0.9.0",
+		},
+		{
+			name:            "case 1: unique app",
+			labels:          map[string]string{meta.Label.Version.Key(): "0.0.0"},
+			expectedVersion: "0.0.0",
+		},
+		{
+			name:            "case 2: missing label",
+			uniqueApp:       true,
+			expectedVersion: "0.0.0",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &v1alpha1.Config{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "giantswarm",
+					Labels:    tc.labels,
+				},
+			}
+
+			in, err := NewGenerateInputFromConfig(config, "puma", tc.uniqueApp)
+			if err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+
+			version := in.ExtraAnnotations[meta.Annotation.XProjectVersion.Key()]
+			if version != tc.expectedVersion {
+				t.Fatalf("version = %q, want %q", version, tc.expectedVersion)
+			}
+		})
+	}
+}
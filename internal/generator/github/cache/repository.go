@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	gocache "github.com/patrickmn/go-cache"
 
@@ -32,6 +33,13 @@ func (r *Repository) Set(ctx context.Context, key string, value github.Store) {
 	r.underlying.SetDefault(key, value)
 }
 
-func (r *Repository) Key(owner, name, reference, sharedName, sharedRef string) string {
-	return fmt.Sprintf("%s/%s@%s+%s/%s@%s", owner, name, reference, owner, sharedName, sharedRef)
+// Flush removes all cached stores.
+func (r *Repository) Flush() {
+	r.underlying.Flush()
+}
+
+// Key returns the cache key of the configuration assembled from the commits
+// of the config repository and the shared configs repositories.
+func (r *Repository) Key(owner, name string, revision github.Revision) string {
+	return fmt.Sprintf("%s/%s@%s+%s", owner, name, revision.Config, strings.Join(revision.Shared, ","))
 }
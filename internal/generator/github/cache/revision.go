@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	gocache "github.com/patrickmn/go-cache"
+
+	"github.com/giantswarm/config-controller/pkg/github"
+)
+
+// Revision caches the commit SHAs references resolve to, so reconciliations
+// don't list the remote repositories every time.
+type Revision struct {
+	underlying *gocache.Cache
+}
+
+func NewRevision() *Revision {
+	return &Revision{
+		underlying: gocache.New(expiration, expiration/2),
+	}
+}
+
+func (r *Revision) Get(ctx context.Context, key string) (github.Revision, bool) {
+	val, ok := r.underlying.Get(key)
+	if ok {
+		return val.(github.Revision), ok
+	}
+
+	return github.Revision{}, false
+}
+
+func (r *Revision) Set(ctx context.Context, key string, value github.Revision) {
+	r.underlying.SetDefault(key, value)
+}
+
+// Delete removes the cached revision.
+func (r *Revision) Delete(key string) {
+	r.underlying.Delete(key)
+}
+
+// Flush removes all cached revisions.
+func (r *Revision) Flush() {
+	r.underlying.Flush()
+}
+
+func (r *Revision) Key(owner, name, branch string) string {
+	return fmt.Sprintf("%s/%s@%s", owner, name, branch)
+}
@@ -2,60 +2,170 @@ package github
 
 import (
 	"context"
+	"time"
 
 	"github.com/giantswarm/config-controller/internal/shared"
 
 	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
 
 	"github.com/giantswarm/config-controller/internal/generator/github/cache"
+	"github.com/giantswarm/config-controller/internal/githubapp"
 	"github.com/giantswarm/config-controller/internal/ssh"
 	"github.com/giantswarm/config-controller/pkg/github"
 )
 
 type Config struct {
-	SharedConfigRepository  shared.ConfigRepository
-	ConfigRepoSSHCredential ssh.Credential
-	Token                   string
+	Logger micrologger.Logger
+
+	App                      githubapp.Credential
+	CacheDir                 string
+	Host                     string
+	Installation             string
+	KnownHosts               string
+	MaxStaleness             time.Duration
+	RepositoryURL            string
+	RepositoryUsername       string
+	RepositoryToken          string
+	SharedConfigRepositories []shared.ConfigRepository
+	ConfigRepoSSHCredential  ssh.Credential
+	Token                    string
+	TrustedGPGKeys           string
+	TrustedSSHKeys           string
 }
 
 type GitHub struct {
-	SharedConfigRepository shared.ConfigRepository
-	client                 *github.GitHub
-	repoCache              *cache.Repository
-	tagCache               *cache.Tag
+	SharedConfigRepositories []shared.ConfigRepository
+	client                   *github.GitHub
+	repoCache                *cache.Repository
+	revisionCache            *cache.Revision
+	tagCache                 *cache.Tag
 }
 
 func New(c Config) (*GitHub, error) {
 	client, err := github.New(github.Config{
-		SharedConfigRepository: c.SharedConfigRepository,
-		SSHCredential:          c.ConfigRepoSSHCredential,
-		Token:                  c.Token,
+		Logger: c.Logger,
+
+		App:                      c.App,
+		CacheDir:                 c.CacheDir,
+		Host:                     c.Host,
+		Installation:             c.Installation,
+		KnownHosts:               c.KnownHosts,
+		MaxStaleness:             c.MaxStaleness,
+		RepositoryURL:            c.RepositoryURL,
+		RepositoryUsername:       c.RepositoryUsername,
+		RepositoryToken:          c.RepositoryToken,
+		SharedConfigRepositories: c.SharedConfigRepositories,
+		SSHCredential:            c.ConfigRepoSSHCredential,
+		Token:                    c.Token,
+		TrustedGPGKeys:           c.TrustedGPGKeys,
+		TrustedSSHKeys:           c.TrustedSSHKeys,
 	})
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
 	gh := &GitHub{
-		SharedConfigRepository: c.SharedConfigRepository,
-		client:                 client,
-		repoCache:              cache.NewRepository(),
-		tagCache:               cache.NewTag(),
+		SharedConfigRepositories: c.SharedConfigRepositories,
+		client:                   client,
+		repoCache:                cache.NewRepository(),
+		revisionCache:            cache.NewRevision(),
+		tagCache:                 cache.NewTag(),
 	}
 	return gh, nil
 }
 
+// InvalidateCache drops the cached revisions, so the next assembly resolves
+// the references again. Stores are cached by commit SHAs and stay valid.
+func (gh *GitHub) InvalidateCache() {
+	gh.revisionCache.Flush()
+}
+
+// ResolveRevision resolves the references to commit SHAs. The revision is
+// cached and used by the following assemblies, so e.g. a poll resolves the
+// references once for all reconciliations.
+func (gh *GitHub) ResolveRevision(ctx context.Context, owner, name, branch string) (Revision, error) {
+	revision, err := gh.client.ResolveRevision(ctx, owner, name, branch)
+	if err != nil {
+		return Revision{}, microerror.Mask(err)
+	}
+
+	gh.revisionCache.Set(ctx, gh.revisionCache.Key(owner, name, branch), revision)
+	return revision, nil
+}
+
+// PinnedSharedConfigRepositories returns the shared configs repositories with the
+// refs pinned at the current commit of the config repository.
+func (gh *GitHub) PinnedSharedConfigRepositories(ctx context.Context, owner, name, branch string) ([]shared.ConfigRepository, error) {
+	repositories, err := gh.client.PinnedSharedConfigRepositories(ctx, owner, name, branch)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return repositories, nil
+}
+
+// ChangedPaths returns the paths changed between the revisions.
+func (gh *GitHub) ChangedPaths(ctx context.Context, owner, name, branch string, from, to Revision) (Changes, error) {
+	changes, err := gh.client.ChangedPaths(ctx, owner, name, branch, from, to)
+	if err != nil {
+		return Changes{}, microerror.Mask(err)
+	}
+
+	return changes, nil
+}
+
+// AssembleConfigRepository resolves the references to commit SHAs and
+// returns the configuration assembled from them. Resolved revisions are
+// cached for a minute, so the remote repositories are listed at most once a
+// minute unless the cache is invalidated. Stores are cached by the commit
+// SHAs, so the repositories are only checked out again when a reference
+// moves. When the repositories are unreachable, the last known
+// good configuration is returned within the maximum staleness. Its Stale
+// method returns true.
 func (gh *GitHub) AssembleConfigRepository(ctx context.Context, owner, name, branch string) (github.Store, error) {
-	key := gh.repoCache.Key(owner, name, branch, gh.SharedConfigRepository.Name, gh.SharedConfigRepository.Ref)
+	store, err := gh.assemble(ctx, owner, name, branch)
+	if err != nil {
+		store, err = gh.client.LastKnownGood(ctx, owner, name, branch, err)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return store, nil
+}
+
+func (gh *GitHub) assemble(ctx context.Context, owner, name, branch string) (github.Store, error) {
+	var err error
+
+	revisionKey := gh.revisionCache.Key(owner, name, branch)
+	revision, cached := gh.revisionCache.Get(ctx, revisionKey)
+	if !cached {
+		revision, err = gh.ResolveRevision(ctx, owner, name, branch)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	key := gh.repoCache.Key(owner, name, revision)
 	store, cached := gh.repoCache.Get(ctx, key)
 	if cached {
 		return store, nil
 	}
 
-	store, err := gh.client.AssembleConfigRepository(ctx, owner, name, branch)
+	store, err = gh.client.AssembleConfigRepository(ctx, owner, name, branch, revision)
 	if err != nil {
+		// The cached revision may be out of date, e.g. when a reference
+		// moved. The next assembly resolves the references again.
+		gh.revisionCache.Delete(revisionKey)
 		return nil, microerror.Mask(err)
 	}
 
+	// The references may have moved on in the meantime when the
+	// repositories were cloned.
+	revision = store.Revision()
+	key = gh.repoCache.Key(owner, name, revision)
+
 	gh.repoCache.Set(ctx, key, store)
 	return store, nil
 }
@@ -4,6 +4,10 @@ import (
 	"github.com/giantswarm/config-controller/pkg/github"
 )
 
+type Revision = github.Revision
+
+type Changes = github.Changes
+
 type Store interface {
 	github.Store
 }
@@ -2,6 +2,9 @@ package generator
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/giantswarm/config-controller/internal/shared"
 
@@ -10,8 +13,11 @@ import (
 	vaultapi "github.com/hashicorp/vault/api"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/config-controller/internal/generator/github"
+	"github.com/giantswarm/config-controller/internal/githubapp"
+	"github.com/giantswarm/config-controller/internal/meta"
 	"github.com/giantswarm/config-controller/internal/ssh"
 	"github.com/giantswarm/config-controller/pkg/decrypt"
 	"github.com/giantswarm/config-controller/pkg/generator"
@@ -19,36 +25,113 @@ import (
 )
 
 type Config struct {
-	Log         micrologger.Logger
-	VaultClient *vaultapi.Client
-
-	SharedConfigRepository  shared.ConfigRepository
-	ConfigRepoSSHCredential ssh.Credential
-	GitHubToken             string
-	RepositoryName          string
-	RepositoryRef           string
-	Installation            string
-	Verbose                 bool
+	// Log receives ConfigMap values looking like secrets when
+	// PlaintextSecretCheck is generator.PlaintextSecretCheckWarn and
+	// PlaintextSecretReporter is not set.
+	Log micrologger.Logger
+	// PlaintextSecretReporter receives ConfigMap values looking like
+	// secrets when PlaintextSecretCheck is
+	// generator.PlaintextSecretCheckWarn. It takes precedence over Log.
+	PlaintextSecretReporter generator.PlaintextSecretReporter
+	// DecryptTraverser is used to decrypt secret files. When it is not set
+	// decrypt.YAMLTraverser with decrypt.VaultDecrypter using VaultClient
+	// is used. Vault KV references and, when K8sClient is set, Secret
+	// references are resolved in that case too.
+	DecryptTraverser generator.DecryptTraverser
+	// K8sClient is used to resolve references to in-cluster Secrets. It is
+	// optional.
+	K8sClient client.Client
+	// K8sSecretAllowedNamespaces are the namespaces Secret references may
+	// read from in addition to the Config CR namespace. See
+	// decrypt.K8sSecretResolverConfig.
+	K8sSecretAllowedNamespaces []string
+	// PluginCommand is the path of an exec plugin decrypting values and
+	// resolving references starting with PluginPrefix. See
+	// decrypt.ExecDecrypter for the protocol. It is optional.
+	PluginCommand string
+	PluginPrefix  string
+	// SecretResolver backs the vaultKV, k8sSecret and pluginSecret template
+	// functions. When it is not set, references are resolved with
+	// VaultClient and K8sClient.
+	SecretResolver generator.SecretResolver
+	VaultClient    *vaultapi.Client
+
+	SharedConfigRepositories []shared.ConfigRepository
+	ConfigRepoSSHCredential  ssh.Credential
+	// GitCacheDir is the directory of the on-disk git repository cache.
+	// When it is empty repositories are cloned in memory.
+	GitCacheDir string
+	// GitHost is the git host serving the repositories without configured
+	// URL. It defaults to "github.com".
+	GitHost string
+	// GitMaxStaleness is how long the last known good configuration is
+	// used when the repositories are unreachable. Zero disables the
+	// fallback.
+	GitMaxStaleness time.Duration
+	// GitHubApp is the GitHub App installation minting short-lived tokens
+	// for HTTPS requests. It takes precedence over GitHubToken.
+	GitHubApp   githubapp.Credential
+	GitHubToken string
+	// RepositoryOwner is the owner of the config repository. It defaults
+	// to "giantswarm".
+	RepositoryOwner string
+	RepositoryName  string
+	RepositoryRef   string
+	// RepositoryURL is the URL of the config repository. It may be a
+	// file:// URL or a local path too. When it is empty the URL is built
+	// from GitHost, RepositoryOwner and RepositoryName.
+	RepositoryURL string
+	// RepositoryUsername and RepositoryToken authenticate HTTPS requests
+	// to the config repository, e.g. on another host than GitHost.
+	RepositoryUsername string
+	RepositoryToken    string
+	Installation       string
+	// SSHKnownHosts is the content of a known_hosts file SSH host keys of
+	// the git servers are strictly verified against. When it is empty
+	// $SSH_KNOWN_HOSTS or ~/.ssh/known_hosts is used.
+	SSHKnownHosts string
+	// TrustedGPGKeys is an armored GPG key ring and TrustedSSHKeys holds
+	// SSH public keys in authorized_keys format. When any of them is set,
+	// only commits of the config and shared configs repositories signed by
+	// one of the keys are used.
+	TrustedGPGKeys string
+	TrustedSSHKeys string
+	// PlaintextSecretCheck is the mode of the check for secrets in the
+	// generated ConfigMap. See generator.Config.
+	PlaintextSecretCheck string
+	Verbose              bool
+	// VaultTransitKey is the name of the Vault transit key used by the
+	// default decrypter. It defaults to decrypt.DefaultKey.
+	VaultTransitKey string
+	// VaultKVAllowedPathPrefixes are the Vault paths Vault KV references
+	// may read from. See decrypt.VaultKVResolverConfig.
+	VaultKVAllowedPathPrefixes []string
 }
 
 type Service struct {
-	log              micrologger.Logger
-	decryptTraverser generator.DecryptTraverser
-	gitHub           *github.GitHub
-
-	repositoryName string
-	repositoryRef  string
-	installation   string
-	verbose        bool
+	decryptTraverser        generator.DecryptTraverser
+	gitHub                  *github.GitHub
+	plaintextSecretReporter generator.PlaintextSecretReporter
+	secretResolver          generator.SecretResolver
+
+	repositoryOwner      string
+	repositoryName       string
+	repositoryRef        string
+	installation         string
+	plaintextSecretCheck string
+	verbose              bool
 }
 
 func New(config Config) (*Service, error) {
-	if config.VaultClient == nil {
-		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	if config.DecryptTraverser == nil && config.VaultClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.DecryptTraverser or %T.VaultClient must not be empty", config, config)
 	}
 
-	if config.GitHubToken == "" && config.ConfigRepoSSHCredential.IsEmpty() {
-		return nil, microerror.Maskf(invalidConfigError, "%T.GitHubToken or %T.ConfigRepoSSHCredential must not be empty", config, config)
+	if config.GitHubToken == "" && config.GitHubApp.IsEmpty() && config.ConfigRepoSSHCredential.IsEmpty() && config.RepositoryURL == "" && config.RepositoryToken == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.GitHubToken, %T.GitHubApp, %T.ConfigRepoSSHCredential, %T.RepositoryURL or %T.RepositoryToken must not be empty", config, config, config, config, config)
+	}
+	if config.RepositoryOwner == "" {
+		config.RepositoryOwner = "giantswarm"
 	}
 	if config.RepositoryName == "" {
 		// If repository name is not specified, fall back to original behaviour of using `giantswarm/config`
@@ -64,37 +147,48 @@ func New(config Config) (*Service, error) {
 
 	var err error
 
-	var decrypter *decrypt.VaultDecrypter
-	{
-		c := decrypt.VaultDecrypterConfig{
-			VaultClient: config.VaultClient,
-		}
-
-		decrypter, err = decrypt.NewVaultDecrypter(c)
+	secretResolver := config.SecretResolver
+	decryptTraverser := config.DecryptTraverser
+	if secretResolver == nil || decryptTraverser == nil {
+		decrypter, err := newDecrypter(config)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
-	}
 
-	var decryptTraverser *decrypt.YAMLTraverser
-	{
-		c := decrypt.YAMLTraverserConfig{
-			Decrypter: decrypter,
+		if r, ok := decrypter.(generator.SecretResolver); ok && secretResolver == nil {
+			secretResolver = r
 		}
-
-		decryptTraverser, err = decrypt.NewYAMLTraverser(c)
-		if err != nil {
-			return nil, microerror.Mask(err)
+		if decrypter != nil && decryptTraverser == nil {
+			c := decrypt.YAMLTraverserConfig{
+				Decrypter: decrypter,
+			}
+
+			decryptTraverser, err = decrypt.NewYAMLTraverser(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
 		}
-
 	}
 
 	var gitHub *github.GitHub
 	{
 		c := github.Config{
-			SharedConfigRepository:  config.SharedConfigRepository,
-			ConfigRepoSSHCredential: config.ConfigRepoSSHCredential,
-			Token:                   config.GitHubToken,
+			Logger: config.Log,
+
+			App:                      config.GitHubApp,
+			CacheDir:                 config.GitCacheDir,
+			Host:                     config.GitHost,
+			MaxStaleness:             config.GitMaxStaleness,
+			Installation:             config.Installation,
+			KnownHosts:               config.SSHKnownHosts,
+			RepositoryURL:            config.RepositoryURL,
+			RepositoryUsername:       config.RepositoryUsername,
+			RepositoryToken:          config.RepositoryToken,
+			SharedConfigRepositories: config.SharedConfigRepositories,
+			ConfigRepoSSHCredential:  config.ConfigRepoSSHCredential,
+			Token:                    config.GitHubToken,
+			TrustedGPGKeys:           config.TrustedGPGKeys,
+			TrustedSSHKeys:           config.TrustedSSHKeys,
 		}
 
 		gitHub, err = github.New(c)
@@ -103,15 +197,23 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
-	s := &Service{
-		log:              config.Log,
-		decryptTraverser: decryptTraverser,
-		gitHub:           gitHub,
+	plaintextSecretReporter := config.PlaintextSecretReporter
+	if plaintextSecretReporter == nil && config.Log != nil {
+		plaintextSecretReporter = &logReporter{logger: config.Log}
+	}
 
-		repositoryName: config.RepositoryName,
-		repositoryRef:  config.RepositoryRef,
-		installation:   config.Installation,
-		verbose:        config.Verbose,
+	s := &Service{
+		decryptTraverser:        decryptTraverser,
+		gitHub:                  gitHub,
+		plaintextSecretReporter: plaintextSecretReporter,
+		secretResolver:          secretResolver,
+
+		repositoryOwner:      config.RepositoryOwner,
+		repositoryName:       config.RepositoryName,
+		repositoryRef:        config.RepositoryRef,
+		installation:         config.Installation,
+		plaintextSecretCheck: config.PlaintextSecretCheck,
+		verbose:              config.Verbose,
 	}
 
 	return s, nil
@@ -135,37 +237,92 @@ type GenerateInput struct {
 	ExtraLabels map[string]string
 }
 
-func (s *Service) Generate(ctx context.Context, in GenerateInput) (configmap *corev1.ConfigMap, secret *corev1.Secret, err error) {
-	const (
-		owner = "giantswarm"
-	)
+// InvalidateCache drops the cached revisions of the configuration
+// repositories, so the next generation resolves the references again, e.g.
+// after a push webhook reported new commits.
+func (s *Service) InvalidateCache() {
+	s.gitHub.InvalidateCache()
+}
+
+// ResolveRevision resolves the references of the config repository and the
+// shared configs repositories to commit SHAs.
+func (s *Service) ResolveRevision(ctx context.Context) (github.Revision, error) {
+	revision, err := s.gitHub.ResolveRevision(ctx, s.repositoryOwner, s.repositoryName, s.repositoryRef)
+	if err != nil {
+		return github.Revision{}, microerror.Mask(err)
+	}
+
+	return revision, nil
+}
+
+// PinnedSharedConfigRepositories returns the shared configs repositories with the
+// refs pinned for the installation at the current commit of the config
+// repository.
+func (s *Service) PinnedSharedConfigRepositories(ctx context.Context) ([]shared.ConfigRepository, error) {
+	repositories, err := s.gitHub.PinnedSharedConfigRepositories(ctx, s.repositoryOwner, s.repositoryName, s.repositoryRef)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return repositories, nil
+}
+
+// ChangedPaths returns the paths changed in the config repository and the
+// shared configs repositories between the revisions.
+func (s *Service) ChangedPaths(ctx context.Context, from, to github.Revision) (github.Changes, error) {
+	changes, err := s.gitHub.ChangedPaths(ctx, s.repositoryOwner, s.repositoryName, s.repositoryRef, from, to)
+	if err != nil {
+		return github.Changes{}, microerror.Mask(err)
+	}
+
+	return changes, nil
+}
+
+// Generate generates the ConfigMap and Secret of the app. Stale is true
+// when they were generated from the last known good configuration because
+// the repositories are unreachable. It is not recorded in the generated
+// objects, so switching between stale and fresh configuration doesn't
+// update them when their data is equal.
+func (s *Service) Generate(ctx context.Context, in GenerateInput) (configmap *corev1.ConfigMap, secret *corev1.Secret, stale bool, err error) {
+	// Secret references are limited to the namespace of the Config CR,
+	// which is the namespace of the generated ConfigMap and Secret.
+	ctx = decrypt.NewContextWithNamespace(ctx, in.Namespace)
 
 	var store github.Store
 
-	store, err = s.gitHub.AssembleConfigRepository(ctx, owner, s.repositoryName, s.repositoryRef)
+	store, err = s.gitHub.AssembleConfigRepository(ctx, s.repositoryOwner, s.repositoryName, s.repositoryRef)
 	if err != nil {
-		return nil, nil, microerror.Mask(err)
+		return nil, nil, false, microerror.Mask(err)
 	}
 
 	var gen *generator.Generator
 	{
 		c := generator.Config{
-			Fs:               store,
-			DecryptTraverser: s.decryptTraverser,
-
-			Installation: s.installation,
-			Verbose:      s.verbose,
+			Fs:                      store,
+			DecryptTraverser:        s.decryptTraverser,
+			PlaintextSecretReporter: s.plaintextSecretReporter,
+			SecretResolver:          s.secretResolver,
+
+			Installation:         s.installation,
+			PlaintextSecretCheck: s.plaintextSecretCheck,
+			Verbose:              s.verbose,
 		}
 
 		gen, err = generator.New(c)
 		if err != nil {
-			return nil, nil, microerror.Mask(err)
+			return nil, nil, false, microerror.Mask(err)
 		}
 	}
 
 	annotations := xstrings.CopyMap(in.ExtraAnnotations)
 
-	meta := metav1.ObjectMeta{
+	revision := store.Revision()
+	annotations[meta.Annotation.XConfigCommit.Key()] = revision.Config
+	if len(revision.Shared) > 0 {
+		annotations[meta.Annotation.XSharedConfigCommit.Key()] = strings.Join(revision.Shared, ",")
+	}
+
+	objectMeta := metav1.ObjectMeta{
 		Name:      in.Name,
 		Namespace: in.Namespace,
 
@@ -173,10 +330,85 @@ func (s *Service) Generate(ctx context.Context, in GenerateInput) (configmap *co
 		Labels:      in.ExtraLabels,
 	}
 
-	configMap, secret, err := gen.GenerateConfig(ctx, in.App, meta)
+	configMap, secret, err := gen.GenerateConfig(ctx, in.App, objectMeta)
 	if err != nil {
-		return nil, nil, microerror.Mask(err)
+		return nil, nil, false, microerror.Mask(err)
+	}
+
+	return configMap, secret, store.Stale(), nil
+}
+
+// newDecrypter chains the decrypters and resolvers backed by the configured
+// clients and plugin. It returns nil when none of VaultClient, K8sClient and
+// PluginCommand is set.
+func newDecrypter(config Config) (decrypt.Decrypter, error) {
+	var err error
+
+	var decrypter decrypt.Decrypter
+	if config.VaultClient != nil {
+		var vaultDecrypter *decrypt.VaultDecrypter
+		{
+			c := decrypt.VaultDecrypterConfig{
+				VaultClient: config.VaultClient,
+				Key:         config.VaultTransitKey,
+			}
+
+			vaultDecrypter, err = decrypt.NewVaultDecrypter(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		c := decrypt.VaultKVResolverConfig{
+			Decrypter:   vaultDecrypter,
+			VaultClient: config.VaultClient,
+
+			AllowedPathPrefixes: config.VaultKVAllowedPathPrefixes,
+		}
+
+		decrypter, err = decrypt.NewVaultKVResolver(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	if config.K8sClient != nil {
+		c := decrypt.K8sSecretResolverConfig{
+			Decrypter: decrypter,
+			K8sClient: config.K8sClient,
+
+			AllowedNamespaces: config.K8sSecretAllowedNamespaces,
+		}
+
+		decrypter, err = decrypt.NewK8sSecretResolver(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	if config.PluginCommand != "" {
+		c := decrypt.ExecDecrypterConfig{
+			Command:   config.PluginCommand,
+			Decrypter: decrypter,
+			Prefix:    config.PluginPrefix,
+		}
+
+		decrypter, err = decrypt.NewExecDecrypter(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
 	}
 
-	return configMap, secret, nil
+	return decrypter, nil
+}
+
+// logReporter logs ConfigMap values looking like secrets as warnings.
+type logReporter struct {
+	logger micrologger.Logger
+}
+
+func (r *logReporter) ReportPlaintextSecrets(ctx context.Context, app string, secrets []generator.PlaintextSecret) {
+	for _, s := range secrets {
+		r.logger.LogCtx(ctx, "level", "warning", "message", fmt.Sprintf("configmap-values for app %#q contain a value looking like a secret", app), "path", s.Path, "reason", s.Reason)
+	}
 }
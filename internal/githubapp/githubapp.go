@@ -0,0 +1,17 @@
+package githubapp
+
+// Credential identifies a GitHub App installation. Short-lived installation
+// tokens minted with it are used to access repositories over HTTPS.
+type Credential struct {
+	// APIURL is the URL of the GitHub API. It defaults to
+	// "https://api.github.com".
+	APIURL         string
+	AppID          int64
+	InstallationID int64
+	// PrivateKey is the PEM encoded private key of the app.
+	PrivateKey string
+}
+
+func (c Credential) IsEmpty() bool {
+	return c.AppID == 0 && c.InstallationID == 0 && c.PrivateKey == ""
+}
@@ -13,13 +13,16 @@ import (
 )
 
 var (
-	configVersionAnnotation   = annotation.ConfigVersion
-	xAppInfoAnnotation        = project.Name() + ".x-giantswarm.io/app-info"
-	xCreatorAnnotation        = project.Name() + ".x-giantswarm.io/creator"
-	xInstallationAnnotation   = project.Name() + ".x-giantswarm.io/installation"
-	xObjectHashAnnotation     = project.Name() + ".x-giantswarm.io/object-hash"
-	xPreviousConfigAnnotation = project.Name() + ".x-giantswarm.io/previous-config"
-	xProjectVersionAnnotation = project.Name() + ".x-giantswarm.io/project-version"
+	configVersionAnnotation       = annotation.ConfigVersion
+	xAppInfoAnnotation            = project.Name() + ".x-giantswarm.io/app-info"
+	xConfigCommitAnnotation       = project.Name() + ".x-giantswarm.io/config-commit"
+	xCreatorAnnotation            = project.Name() + ".x-giantswarm.io/creator"
+	xInstallationAnnotation       = project.Name() + ".x-giantswarm.io/installation"
+	xObjectHashAnnotation         = project.Name() + ".x-giantswarm.io/object-hash"
+	xPreviousConfigAnnotation     = project.Name() + ".x-giantswarm.io/previous-config"
+	xProjectVersionAnnotation     = project.Name() + ".x-giantswarm.io/project-version"
+	xRequeuedAtAnnotation         = project.Name() + ".x-giantswarm.io/requeued-at"
+	xSharedConfigCommitAnnotation = project.Name() + ".x-giantswarm.io/shared-config-commit"
 )
 
 type ConfigVersion struct{}
@@ -79,6 +82,10 @@ func (XAppInfo) ValFromConfig(c *v1alpha1.Config) string {
 	return XAppInfo{}.Val(c.Spec.App.Catalog, c.Spec.App.Name, c.Spec.App.Version)
 }
 
+type XConfigCommit struct{}
+
+func (XConfigCommit) Key() string { return xConfigCommitAnnotation }
+
 type XCreator struct{}
 
 func (XCreator) Key() string { return xCreatorAnnotation }
@@ -104,6 +111,14 @@ type XObjectHash struct{}
 
 func (XObjectHash) Key() string { return xObjectHashAnnotation }
 
+type XRequeuedAt struct{}
+
+func (XRequeuedAt) Key() string { return xRequeuedAtAnnotation }
+
+type XSharedConfigCommit struct{}
+
+func (XSharedConfigCommit) Key() string { return xSharedConfigCommitAnnotation }
+
 type XProjectVersion struct{}
 
 func (XProjectVersion) Key() string { return xProjectVersionAnnotation }
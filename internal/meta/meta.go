@@ -11,6 +11,9 @@ type AnnotationType struct {
 	// XAppInfo is set on generated ConfigMap and Secret to show what App
 	// they were generated for.
 	XAppInfo
+	// XConfigCommit is set on generated ConfigMap and Secret to show what
+	// commit of the config repository they were generated from.
+	XConfigCommit
 	// XCreator is used in the CLI mode. The value is OS username. It is
 	// set on generated ConfigMap and Secret.
 	XCreator
@@ -26,6 +29,15 @@ type AnnotationType struct {
 	// XProjectVersion is set on generated ConfigMap and Secret to show what
 	// version of config-controller was used to generate them.
 	XProjectVersion
+	// XRequeuedAt is set on Config CRs to trigger their reconciliation when
+	// the configuration they are generated from changed. The value is the
+	// time of the request.
+	XRequeuedAt
+	// XSharedConfigCommit is set on generated ConfigMap and Secret to show
+	// what commits of the shared configs repositories they were generated
+	// from. The commit SHAs are comma-separated in overlay order. It is
+	// only set for split config setups.
+	XSharedConfigCommit
 }
 
 type LabelType struct {
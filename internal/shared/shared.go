@@ -1,12 +1,64 @@
 package shared
 
+import (
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+)
+
 type ConfigRepository struct {
-	Name     string
-	Ref      string
-	Key      string
-	Password string
+	Name string `json:"name"`
+	// Owner of the repository. It defaults to the owner of the config
+	// repository.
+	Owner string `json:"owner,omitempty"`
+	Ref   string `json:"ref"`
+	// URL of the repository. When it is empty the URL is built from the
+	// git host, the owner and the name.
+	URL      string `json:"url,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Username and Token authenticate HTTPS requests to the repository,
+	// e.g. on another host than the git host. The token is only sent over
+	// HTTPS. Username defaults to "x-access-token".
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token,omitempty"`
+	// RefuseProtectedOverrides makes assembling the configuration fail when
+	// the config repository overrides files listed as protected in the
+	// shared configs repository.
+	RefuseProtectedOverrides bool `json:"refuseProtectedOverrides,omitempty"`
 }
 
 func (c *ConfigRepository) IsEmpty() bool {
 	return c.Key == "" && c.Password == ""
 }
+
+// ParseConfigRepositories parses a YAML list of shared config repositories
+// in overlay order, e.g.
+//
+//	# shared-config-repositories.yaml
+//	- name: company-configs
+//	  owner: acme
+//	  ref: main
+//	- name: business-unit-configs
+//	  ref: v1.2.0
+//	  key: <SSH private key>
+//	- name: partner-configs
+//	  url: https://git.example.com/partner/configs.git
+//	  token: <access token>
+func ParseConfigRepositories(data []byte) ([]ConfigRepository, error) {
+	var repositories []ConfigRepository
+	err := yaml.Unmarshal(data, &repositories)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "invalid shared config repositories: %s", err)
+	}
+
+	for i, r := range repositories {
+		if r.Name == "" {
+			return nil, microerror.Maskf(invalidConfigError, "name of shared config repository %d must not be empty", i)
+		}
+		if r.Ref == "" {
+			repositories[i].Ref = "main"
+		}
+	}
+
+	return repositories, nil
+}
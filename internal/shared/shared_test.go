@@ -0,0 +1,81 @@
+package shared
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+func TestParseConfigRepositories(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		input                string
+		expectedResult       []ConfigRepository
+		expectedErrorMessage string
+	}{
+		{
+			name: "case 0: repositories are kept in order and ref defaults to main",
+			input: `
+- name: company-configs
+  owner: acme
+  ref: v1.2.0
+- name: business-unit-configs
+  url: https://git.example.com/acme/business-unit-configs.git
+  refuseProtectedOverrides: true
+`,
+			expectedResult: []ConfigRepository{
+				{
+					Name:  "company-configs",
+					Owner: "acme",
+					Ref:   "v1.2.0",
+				},
+				{
+					Name: "business-unit-configs",
+					Ref:  "main",
+					URL:  "https://git.example.com/acme/business-unit-configs.git",
+
+					RefuseProtectedOverrides: true,
+				},
+			},
+		},
+		{
+			name: "case 1: name is required",
+			input: `
+- owner: acme
+`,
+			expectedErrorMessage: "name of shared config repository 0 must not be empty",
+		},
+		{
+			name:                 "case 2: invalid YAML",
+			input:                "name: company-configs",
+			expectedErrorMessage: "invalid shared config repositories",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ParseConfigRepositories([]byte(tc.input))
+
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if !reflect.DeepEqual(result, tc.expectedResult) {
+				t.Fatalf("result = %#v, want %#v", result, tc.expectedResult)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package vault
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const (
+	// AuthMethodAppRole logs in with the AppRole auth method.
+	AuthMethodAppRole = "approle"
+	// AuthMethodKubernetes logs in with the Kubernetes auth method using
+	// the pod service account token.
+	AuthMethodKubernetes = "kubernetes"
+	// AuthMethodToken uses the configured static token.
+	AuthMethodToken = "token"
+
+	// DefaultKubernetesTokenPath is the path of the projected service
+	// account token.
+	DefaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token" // nolint:gosec
+
+	defaultRetryInterval = 10 * time.Second
+)
+
+type AuthenticatorConfig struct {
+	Logger      micrologger.Logger
+	VaultClient *vaultapi.Client
+
+	// Method is one of AuthMethodAppRole or AuthMethodKubernetes.
+	Method string
+
+	AppRole    AppRoleAuth
+	Kubernetes KubernetesAuth
+
+	// RetryInterval is the time to wait before logging in again after a
+	// failed login. It defaults to 10 seconds.
+	RetryInterval time.Duration
+}
+
+type AppRoleAuth struct {
+	// MountPath defaults to "approle".
+	MountPath string
+	RoleID    string
+	// SecretIDFile is read on every login, so the secret ID can be
+	// rotated without restarts.
+	SecretIDFile string
+}
+
+type KubernetesAuth struct {
+	// MountPath defaults to "kubernetes".
+	MountPath string
+	Role      string
+	// TokenPath is read on every login, so rotated projected tokens are
+	// picked up. It defaults to DefaultKubernetesTokenPath.
+	TokenPath string
+}
+
+// Authenticator logs the Vault client in with the Kubernetes or AppRole auth
+// method and keeps its token valid. The token is renewed in the background
+// and the client logs in again when the token can't be renewed anymore.
+type Authenticator struct {
+	logger      micrologger.Logger
+	vaultClient *vaultapi.Client
+
+	method        string
+	appRole       AppRoleAuth
+	kubernetes    KubernetesAuth
+	retryInterval time.Duration
+}
+
+func NewAuthenticator(config AuthenticatorConfig) (*Authenticator, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.VaultClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	}
+
+	switch config.Method {
+	case AuthMethodAppRole:
+		if config.AppRole.RoleID == "" {
+			return nil, microerror.Maskf(invalidConfigError, "%T.AppRole.RoleID must not be empty", config)
+		}
+		if config.AppRole.SecretIDFile == "" {
+			return nil, microerror.Maskf(invalidConfigError, "%T.AppRole.SecretIDFile must not be empty", config)
+		}
+		if config.AppRole.MountPath == "" {
+			config.AppRole.MountPath = AuthMethodAppRole
+		}
+	case AuthMethodKubernetes:
+		if config.Kubernetes.Role == "" {
+			return nil, microerror.Maskf(invalidConfigError, "%T.Kubernetes.Role must not be empty", config)
+		}
+		if config.Kubernetes.MountPath == "" {
+			config.Kubernetes.MountPath = AuthMethodKubernetes
+		}
+		if config.Kubernetes.TokenPath == "" {
+			config.Kubernetes.TokenPath = DefaultKubernetesTokenPath
+		}
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "%T.Method must be one of %q or %q, got %q", config, AuthMethodAppRole, AuthMethodKubernetes, config.Method)
+	}
+
+	if config.RetryInterval == 0 {
+		config.RetryInterval = defaultRetryInterval
+	}
+
+	a := &Authenticator{
+		logger:      config.Logger,
+		vaultClient: config.VaultClient,
+
+		method:        config.Method,
+		appRole:       config.AppRole,
+		kubernetes:    config.Kubernetes,
+		retryInterval: config.RetryInterval,
+	}
+
+	return a, nil
+}
+
+// Login logs in and sets the token of the Vault client.
+func (a *Authenticator) Login(ctx context.Context) (*vaultapi.Secret, error) {
+	var loginPath string
+	var data map[string]interface{}
+	switch a.method {
+	case AuthMethodAppRole:
+		secretID, err := os.ReadFile(filepath.Clean(a.appRole.SecretIDFile))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		loginPath = path.Join("auth", a.appRole.MountPath, "login")
+		data = map[string]interface{}{
+			"role_id":   a.appRole.RoleID,
+			"secret_id": strings.TrimSpace(string(secretID)),
+		}
+	case AuthMethodKubernetes:
+		jwt, err := os.ReadFile(filepath.Clean(a.kubernetes.TokenPath))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		loginPath = path.Join("auth", a.kubernetes.MountPath, "login")
+		data = map[string]interface{}{
+			"role": a.kubernetes.Role,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}
+	}
+
+	// The login request is sent without the current, possibly expired,
+	// token. A clone is used so concurrent requests keep using the current
+	// token until the new one is set.
+	loginClient, err := a.vaultClient.Clone()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	loginClient.ClearToken()
+
+	secret, err := loginClient.Logical().WriteWithContext(ctx, loginPath, data)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, microerror.Maskf(executionFailedError, "Vault %#q login returned no token", a.method)
+	}
+
+	a.vaultClient.SetToken(secret.Auth.ClientToken)
+
+	return secret, nil
+}
+
+// Run renews the token of the given login secret until it can't be renewed
+// anymore and then logs in again. It blocks until the context is cancelled.
+func (a *Authenticator) Run(ctx context.Context, secret *vaultapi.Secret) {
+	for {
+		if secret != nil {
+			err := a.watch(ctx, secret)
+			if err != nil {
+				a.logger.Errorf(ctx, err, "failed to renew Vault token")
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		var err error
+		secret, err = a.Login(ctx)
+		if err != nil {
+			a.logger.Errorf(ctx, err, "failed to log in to Vault with %#q auth method", a.method)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(a.retryInterval):
+			}
+
+			continue
+		}
+
+		a.logger.Debugf(ctx, "logged in to Vault with %#q auth method", a.method)
+	}
+}
+
+// watch renews the token of the login secret and returns when the token
+// can't be renewed anymore or the context is cancelled.
+func (a *Authenticator) watch(ctx context.Context, secret *vaultapi.Secret) error {
+	watcher, err := a.vaultClient.NewLifetimeWatcher(&vaultapi.LifetimeWatcherInput{
+		Secret: secret,
+		// Log in again as soon as the token can't be renewed instead of
+		// waiting for it to expire.
+		RenewBehavior: vaultapi.RenewBehaviorErrorOnErrors,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	go watcher.Start()
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.DoneCh():
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return nil
+		case <-watcher.RenewCh():
+			a.logger.Debugf(ctx, "renewed Vault token")
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// fakeVault is a stand-in for a Vault dev server supporting the Kubernetes
+// and AppRole login endpoints and token renewal. Renewal of the first issued
+// token fails, so clients have to log in again.
+type fakeVault struct {
+	mutex  sync.Mutex
+	logins int
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]string
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/v1/auth/kubernetes/login":
+		if body["role"] != "config-controller" || body["jwt"] != "service-account-jwt" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	case "/v1/auth/approle/login":
+		if body["role_id"] != "role-id" || body["secret_id"] != "secret-id" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	case "/v1/auth/token/renew-self":
+		if r.Header.Get("X-Vault-Token") == "token-1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		writeAuth(w, r.Header.Get("X-Vault-Token"))
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	v.logins++
+	writeAuth(w, fmt.Sprintf("token-%d", v.logins))
+}
+
+func (v *fakeVault) Logins() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return v.logins
+}
+
+func writeAuth(w http.ResponseWriter, token string) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"auth": map[string]interface{}{
+			"client_token":   token,
+			"renewable":      true,
+			"lease_duration": 3600,
+		},
+	})
+}
+
+func TestAuthenticator(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "token"), "service-account-jwt\n")
+	writeFile(t, filepath.Join(dir, "secret-id"), "secret-id\n")
+
+	testCases := []struct {
+		name                 string
+		config               AuthenticatorConfig
+		expectedToken        string
+		expectedErrorMessage string
+	}{
+		{
+			name: "case 0: Kubernetes login",
+			config: AuthenticatorConfig{
+				Method: AuthMethodKubernetes,
+				Kubernetes: KubernetesAuth{
+					Role:      "config-controller",
+					TokenPath: filepath.Join(dir, "token"),
+				},
+			},
+			expectedToken: "token-1",
+		},
+		{
+			name: "case 1: AppRole login",
+			config: AuthenticatorConfig{
+				Method: AuthMethodAppRole,
+				AppRole: AppRoleAuth{
+					RoleID:       "role-id",
+					SecretIDFile: filepath.Join(dir, "secret-id"),
+				},
+			},
+			expectedToken: "token-1",
+		},
+		{
+			name: "case 2: Kubernetes login with unknown role",
+			config: AuthenticatorConfig{
+				Method: AuthMethodKubernetes,
+				Kubernetes: KubernetesAuth{
+					Role:      "unknown",
+					TokenPath: filepath.Join(dir, "token"),
+				},
+			},
+			expectedErrorMessage: "Code: 403",
+		},
+		{
+			name: "case 3: unsupported method",
+			config: AuthenticatorConfig{
+				Method: "userpass",
+			},
+			expectedErrorMessage: "Method must be one of",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(&fakeVault{})
+			defer server.Close()
+
+			vaultClient := newTestClient(t, server.URL)
+
+			tc.config.Logger = microloggertest.New()
+			tc.config.VaultClient = vaultClient
+
+			var err error
+			authenticator, err := NewAuthenticator(tc.config)
+			if err == nil {
+				_, err = authenticator.Login(context.Background())
+			}
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if vaultClient.Token() != tc.expectedToken {
+				t.Fatalf("token = %q, want %q", vaultClient.Token(), tc.expectedToken)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "token"), "service-account-jwt")
+
+	vault := &fakeVault{}
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	vaultClient := newTestClient(t, server.URL)
+
+	authenticator, err := NewAuthenticator(AuthenticatorConfig{
+		Logger:      microloggertest.New(),
+		VaultClient: vaultClient,
+
+		Method: AuthMethodKubernetes,
+		Kubernetes: KubernetesAuth{
+			Role:      "config-controller",
+			TokenPath: filepath.Join(dir, "token"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	secret, err := authenticator.Login(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		authenticator.Run(ctx, secret)
+		close(done)
+	}()
+
+	// Renewal of the first token fails, so the authenticator has to log
+	// in again.
+	deadline := time.Now().Add(10 * time.Second)
+	for vault.Logins() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("logins = %d, want %d", vault.Logins(), 2)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if vaultClient.Token() != "token-2" {
+		t.Fatalf("token = %q, want %q", vaultClient.Token(), "token-2")
+	}
+
+	cancel()
+	<-done
+}
+
+func newTestClient(t *testing.T, address string) *vaultapi.Client {
+	t.Helper()
+
+	c := vaultapi.DefaultConfig()
+	c.Address = address
+	c.MaxRetries = 0
+	vaultClient, err := vaultapi.NewClient(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	return vaultClient
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+}
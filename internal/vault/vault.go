@@ -1,4 +1,4 @@
-package generate
+package vault
 
 import (
 	"context"
@@ -11,13 +11,15 @@ import (
 	vaultapi "github.com/hashicorp/vault/api"
 )
 
-type vaultClientConfig struct {
+type ClientConfig struct {
 	Address string `json:"addr"`
 	Token   string `json:"token"`
 	CAPath  string `json:"caPath"`
 }
 
-func newVaultClient(config vaultClientConfig) (*vaultapi.Client, error) {
+// NewClient creates a Vault client for the given address, token and CA
+// certificates directory.
+func NewClient(config ClientConfig) (*vaultapi.Client, error) {
 	c := vaultapi.DefaultConfig()
 	c.Address = config.Address
 	c.MaxRetries = 4 // Total of 5 tries.
@@ -38,7 +40,9 @@ func newVaultClient(config vaultClientConfig) (*vaultapi.Client, error) {
 	return vaultClient, nil
 }
 
-func createVaultClientUsingOpsctl(ctx context.Context, gitHubToken, sshUser, installation string) (*vaultapi.Client, error) {
+// NewClientUsingOpsctl creates a Vault client for the installation using
+// configuration generated by "opsctl create vaultconfig".
+func NewClientUsingOpsctl(ctx context.Context, gitHubToken, sshUser, installation string) (*vaultapi.Client, error) {
 	cmdArgs := []string{"opsctl", "create", "vaultconfig", "-i", installation, "-o", "json"}
 
 	if sshUser != "" {
@@ -63,7 +67,7 @@ func createVaultClientUsingOpsctl(ctx context.Context, gitHubToken, sshUser, ins
 		)
 	}
 
-	var config vaultClientConfig
+	var config ClientConfig
 	err = json.Unmarshal(out, &config)
 	if err != nil {
 		return nil, microerror.Maskf(
@@ -73,11 +77,10 @@ func createVaultClientUsingOpsctl(ctx context.Context, gitHubToken, sshUser, ins
 		)
 	}
 
-	vaultClient, err := newVaultClient(config)
+	vaultClient, err := NewClient(config)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
 	return vaultClient, nil
-
 }
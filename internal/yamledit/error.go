@@ -0,0 +1,20 @@
+package yamledit
+
+import "github.com/giantswarm/microerror"
+
+// executionFailedError should never be matched against and therefore there is
+// no matcher implement. For further information see:
+//
+//	https://github.com/giantswarm/fmt/blob/master/go/errors.md#matching-errors
+var executionFailedError = &microerror.Error{
+	Kind: "executionFailedError",
+}
+
+var invalidPathError = &microerror.Error{
+	Kind: "invalidPathError",
+}
+
+// IsInvalidPath asserts invalidPathError.
+func IsInvalidPath(err error) bool {
+	return microerror.Cause(err) == invalidPathError
+}
@@ -0,0 +1,357 @@
+// Package yamledit edits YAML documents in place. Only the lines affected by
+// the change are modified, so comments, blank lines, indentation and quoting
+// of the rest of the document are kept.
+package yamledit
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"gopkg.in/yaml.v3"
+)
+
+const indent = "  "
+
+// Set sets the string value at the path in the YAML document. Missing
+// mappings along the path are created.
+func Set(data []byte, path []string, value string) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, microerror.Maskf(invalidPathError, "path must not be empty")
+	}
+
+	var doc yaml.Node
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	rendered, err := renderScalar(value)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	lines := splitLines(data)
+
+	// Empty document. Render the whole path.
+	if len(doc.Content) == 0 || isNull(doc.Content[0]) {
+		lines = append(trimTrailingBlank(lines), renderPath(path, rendered, "")...)
+		return joinLines(lines), nil
+	}
+
+	node := doc.Content[0]
+	for i, p := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil, microerror.Maskf(invalidPathError, "%#q is not a mapping", strings.Join(path[:i], "."))
+		}
+
+		keyNode, valueNode := lookup(node, p)
+		if keyNode == nil {
+			lines, err = insertIntoMapping(lines, node, path[i:], rendered)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			return joinLines(lines), nil
+		}
+
+		if i == len(path)-1 {
+			if valueNode.Kind != yaml.ScalarNode {
+				return nil, microerror.Maskf(invalidPathError, "%#q is not a scalar", strings.Join(path, "."))
+			}
+
+			lines, err = replaceScalar(lines, keyNode, valueNode, rendered)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			return joinLines(lines), nil
+		}
+
+		node = valueNode
+	}
+
+	return joinLines(lines), nil
+}
+
+// ReplaceScalars calls replace with every scalar value of the YAML document
+// and writes the values it changed back in place. Mapping keys are not
+// passed to replace. It returns the number of replaced values.
+func ReplaceScalars(data []byte, replace func(value string) (string, error)) ([]byte, int, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, 0, microerror.Mask(err)
+	}
+
+	type replacement struct {
+		keyNode   *yaml.Node
+		valueNode *yaml.Node
+		rendered  string
+	}
+
+	var replacements []replacement
+	var walk func(keyNode, node *yaml.Node) error
+	walk = func(keyNode, node *yaml.Node) error {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, n := range node.Content {
+				err := walk(nil, n)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				err := walk(node.Content[i], node.Content[i+1])
+				if err != nil {
+					return microerror.Mask(err)
+				}
+			}
+		case yaml.ScalarNode:
+			if isNull(node) {
+				return nil
+			}
+
+			value, err := replace(node.Value)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			if value == node.Value {
+				return nil
+			}
+
+			rendered, err := renderScalar(value)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			replacements = append(replacements, replacement{keyNode: keyNode, valueNode: node, rendered: rendered})
+		}
+
+		return nil
+	}
+
+	err = walk(nil, &doc)
+	if err != nil {
+		return nil, 0, microerror.Mask(err)
+	}
+
+	if len(replacements) == 0 {
+		return data, 0, nil
+	}
+
+	// Replace from the end of the document, so replacing block scalars
+	// doesn't move the values not replaced yet.
+	sort.SliceStable(replacements, func(i, j int) bool {
+		a, b := replacements[i].valueNode, replacements[j].valueNode
+		if a.Line != b.Line {
+			return a.Line > b.Line
+		}
+		return a.Column > b.Column
+	})
+
+	lines := splitLines(data)
+	for _, r := range replacements {
+		lines, err = replaceScalar(lines, r.keyNode, r.valueNode, r.rendered)
+		if err != nil {
+			return nil, 0, microerror.Mask(err)
+		}
+	}
+
+	return joinLines(lines), len(replacements), nil
+}
+
+func lookup(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+
+	return nil, nil
+}
+
+func isNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.ShortTag() == "!!null"
+}
+
+// insertIntoMapping inserts the rest of the path after the last entry of the
+// mapping.
+func insertIntoMapping(lines []string, mapping *yaml.Node, path []string, rendered string) ([]string, error) {
+	if mapping.Style&yaml.FlowStyle != 0 || len(mapping.Content) == 0 {
+		return nil, microerror.Maskf(executionFailedError, "flow style mapping at line %d is not supported", mapping.Line)
+	}
+
+	keyIndent := mapping.Content[0].Column - 1
+	end := endOfBlock(lines, mapping.Content[len(mapping.Content)-2].Line-1, keyIndent)
+
+	inserted := renderPath(path, rendered, strings.Repeat(" ", keyIndent))
+
+	out := make([]string, 0, len(lines)+len(inserted))
+	out = append(out, lines[:end]...)
+	out = append(out, inserted...)
+	out = append(out, lines[end:]...)
+
+	return out, nil
+}
+
+// replaceScalar replaces the scalar value. The key node is nil for sequence
+// items.
+func replaceScalar(lines []string, keyNode, valueNode *yaml.Node, rendered string) ([]string, error) {
+	row := valueNode.Line - 1
+	col := valueNode.Column - 1
+	line := lines[row]
+
+	switch valueNode.Style {
+	case yaml.LiteralStyle, yaml.FoldedStyle:
+		if keyNode == nil {
+			return nil, microerror.Maskf(executionFailedError, "block scalar at line %d outside of a mapping is not supported", valueNode.Line)
+		}
+
+		end := endOfBlock(lines, keyNode.Line-1, keyNode.Column-1)
+
+		out := make([]string, 0, len(lines))
+		out = append(out, lines[:row]...)
+		out = append(out, line[:col]+rendered)
+		out = append(out, lines[end:]...)
+
+		return out, nil
+	}
+
+	// Null values written as "key:" have no value text. The value node
+	// points right after the colon then.
+	if valueNode.Value == "" && valueNode.ShortTag() == "!!null" {
+		lines[row] = line[:col] + " " + rendered + line[col:]
+		return lines, nil
+	}
+
+	n, err := scalarLen(line[col:], valueNode)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if strings.Contains(line[col:], "\n") || col+n > len(line) {
+		return nil, microerror.Maskf(executionFailedError, "failed to find the value at line %d", valueNode.Line)
+	}
+
+	lines[row] = line[:col] + rendered + line[col+n:]
+
+	return lines, nil
+}
+
+// scalarLen returns the length of the single line scalar text at the
+// beginning of s.
+func scalarLen(s string, n *yaml.Node) (int, error) {
+	switch n.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, nil
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	default:
+		if strings.HasPrefix(s, n.Value) {
+			return len(n.Value), nil
+		}
+	}
+
+	return 0, microerror.Maskf(executionFailedError, "multi-line scalar at line %d is not supported", n.Line)
+}
+
+// endOfBlock returns the index of the first line after the block starting at
+// the start line. The block consists of the start line and all following
+// lines indented deeper than indent. Trailing blank lines are not part of
+// the block.
+func endOfBlock(lines []string, start, indent int) int {
+	end := start + 1
+	for i := start + 1; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if len(lines[i])-len(trimmed) <= indent {
+			break
+		}
+		end = i + 1
+	}
+
+	return end
+}
+
+func renderPath(path []string, rendered string, prefix string) []string {
+	var lines []string
+	for i, p := range path {
+		if i == len(path)-1 {
+			lines = append(lines, prefix+p+": "+rendered)
+		} else {
+			lines = append(lines, prefix+p+":")
+		}
+		prefix += indent
+	}
+
+	return lines
+}
+
+// renderScalar renders the value as a single line YAML string scalar.
+func renderScalar(value string) (string, error) {
+	bs, err := yaml.Marshal(value)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	s := strings.TrimSuffix(string(bs), "\n")
+	if !strings.Contains(s, "\n") {
+		return s, nil
+	}
+
+	// JSON strings are valid YAML double quoted scalars.
+	bs, err = json.Marshal(value)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return string(bs), nil
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func joinLines(lines []string) []byte {
+	var b bytes.Buffer
+	for _, l := range lines {
+		b.WriteString(l)
+		b.WriteString("\n")
+	}
+
+	return b.Bytes()
+}
+
+func trimTrailingBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
@@ -0,0 +1,230 @@
+package yamledit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSet(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		input                string
+		path                 string
+		value                string
+		expectedResult       string
+		expectedErrorMessage string
+	}{
+		{
+			name:           "case 0: empty document",
+			input:          "",
+			path:           "a.b",
+			value:          "vault:v1:abc",
+			expectedResult: "a:\n  b: vault:v1:abc\n",
+		},
+		{
+			name: "case 1: replace plain value and keep comments",
+			input: `# comment
+a:
+  b: old # trailing comment
+
+    # indented comment
+  c: "keep"
+`,
+			path:  "a.b",
+			value: "vault:v1:new",
+			expectedResult: `# comment
+a:
+  b: vault:v1:new # trailing comment
+
+    # indented comment
+  c: "keep"
+`,
+		},
+		{
+			name: "case 2: replace quoted value",
+			input: `a: "old \" value"
+b: 'it''s' # comment
+`,
+			path:  "b",
+			value: "new",
+			expectedResult: `a: "old \" value"
+b: new # comment
+`,
+		},
+		{
+			name: "case 3: insert into nested mapping",
+			input: `a:
+    b:
+        c: x
+        d: |
+          multi
+          line
+
+e: y
+`,
+			path:  "a.b.f.g",
+			value: "z",
+			expectedResult: `a:
+    b:
+        c: x
+        d: |
+          multi
+          line
+        f:
+          g: z
+
+e: y
+`,
+		},
+		{
+			name: "case 4: replace block scalar",
+			input: `a: |
+  multi
+  line
+b: x
+`,
+			path:           "a",
+			value:          "single",
+			expectedResult: "a: single\nb: x\n",
+		},
+		{
+			name:           "case 5: replace null value",
+			input:          "a:\nb: x\nc: # comment\n",
+			path:           "c",
+			value:          "z",
+			expectedResult: "a:\nb: x\nc: z # comment\n",
+		},
+		{
+			name:                 "case 6: path through scalar",
+			input:                "a: x\n",
+			path:                 "a.b",
+			value:                "y",
+			expectedErrorMessage: "`a` is not a mapping",
+		},
+		{
+			name:           "case 7: quote values when needed",
+			input:          "a: x\n",
+			path:           "a",
+			value:          "true",
+			expectedResult: "a: \"true\"\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := Set([]byte(tc.input), strings.Split(tc.path, "."), tc.value)
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if !cmp.Equal(tc.expectedResult, string(result)) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedResult, string(result)))
+			}
+		})
+	}
+}
+
+func TestReplaceScalars(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		input                string
+		expectedResult       string
+		expectedReplaced     int
+		expectedErrorMessage string
+	}{
+		{
+			name: "case 0: replace values only and keep comments",
+			input: `# vault:v1:old
+a:
+  b: vault:v1:old # vault:v1:old
+  vault:v1:old: keep
+  c: "vault:v1:old"
+`,
+			expectedResult: `# vault:v1:old
+a:
+  b: vault:v2:new # vault:v1:old
+  vault:v1:old: keep
+  c: vault:v2:new
+`,
+			expectedReplaced: 2,
+		},
+		{
+			name: "case 1: replace block scalar and sequence items",
+			input: `a: |
+  vault:v1:old
+b:
+  - vault:v1:old
+  - keep
+c: vault:v1:old
+`,
+			expectedResult: `a: vault:v2:new
+b:
+  - vault:v2:new
+  - keep
+c: vault:v2:new
+`,
+			expectedReplaced: 3,
+		},
+		{
+			name:             "case 2: nothing to replace",
+			input:            "a: keep # vault:v1:old\n",
+			expectedResult:   "a: keep # vault:v1:old\n",
+			expectedReplaced: 0,
+		},
+		{
+			name: "case 3: block scalar sequence item",
+			input: `a:
+  - |
+    vault:v1:old
+`,
+			expectedErrorMessage: "block scalar at line 2 outside of a mapping is not supported",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, n, err := ReplaceScalars([]byte(tc.input), func(value string) (string, error) {
+				if strings.TrimSpace(value) == "vault:v1:old" {
+					return "vault:v2:new", nil
+				}
+				return value, nil
+			})
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if n != tc.expectedReplaced {
+				t.Fatalf("replaced = %d, want %d", n, tc.expectedReplaced)
+			}
+			if !cmp.Equal(tc.expectedResult, string(result)) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedResult, string(result)))
+			}
+		})
+	}
+}
@@ -13,7 +13,10 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/giantswarm/config-controller/cmd/generate"
+	"github.com/giantswarm/config-controller/cmd/secret"
 	"github.com/giantswarm/config-controller/flag"
+	"github.com/giantswarm/config-controller/internal/vault"
+	"github.com/giantswarm/config-controller/pkg/generator"
 	"github.com/giantswarm/config-controller/pkg/project"
 	"github.com/giantswarm/config-controller/server"
 	"github.com/giantswarm/config-controller/service"
@@ -116,24 +119,61 @@ func mainE(ctx context.Context) error {
 		}
 		subcommands = append(subcommands, cmd)
 	}
+	{
+		c := secret.Config{
+			Logger: logger,
+		}
+		cmd, err := secret.New(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		subcommands = append(subcommands, cmd)
+	}
 
 	newCommand.CobraCommand().AddCommand(subcommands...)
 
 	daemonCommand := newCommand.DaemonCommand().CobraCommand()
 
 	daemonCommand.PersistentFlags().Bool(f.Service.App.Unique, false, "Whether the operator is deployed as a unique app.")
+	daemonCommand.PersistentFlags().String(f.Service.Decrypt.Backend, "vault", `Secret decryption backend. One of "vault" or "sops".`)
+	daemonCommand.PersistentFlags().String(f.Service.Decrypt.K8sSecret.AllowedNamespaces, "", `Comma separated namespaces Secret references may read from in addition to the namespace of the Config CR. References to Secrets in other namespaces are rejected.`)
+	daemonCommand.PersistentFlags().String(f.Service.Decrypt.Plugin.Command, "", "Path to the exec plugin decrypting values and resolving references starting with the plugin prefix.")
+	daemonCommand.PersistentFlags().String(f.Service.Decrypt.Plugin.Prefix, "", `Prefix of the values handled by the exec plugin, e.g. "op://".`)
+	daemonCommand.PersistentFlags().String(f.Service.Decrypt.SOPS.AgeKeyFile, "", `Path to the age identity file used by the "sops" decryption backend.`)
+	daemonCommand.PersistentFlags().String(f.Service.Decrypt.SOPS.PGPKeyFile, "", `Path to the armored PGP private key file used by the "sops" decryption backend.`)
+	daemonCommand.PersistentFlags().String(f.Service.Generator.PlaintextSecretCheck, generator.PlaintextSecretCheckWarn, fmt.Sprintf(`Check for values looking like secrets in generated ConfigMaps. One of %q, %q or %q.`, generator.PlaintextSecretCheckOff, generator.PlaintextSecretCheckWarn, generator.PlaintextSecretCheckFail))
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.App.APIURL, "https://api.github.com", "URL of the GitHub API used to mint GitHub App installation tokens.")
+	daemonCommand.PersistentFlags().Int64(f.Service.GitHub.App.ID, 0, "ID of the GitHub App used to access repositories over HTTPS. Takes precedence over the token.")
+	daemonCommand.PersistentFlags().Int64(f.Service.GitHub.App.InstallationID, 0, "ID of the GitHub App installation used to access repositories over HTTPS.")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.App.PrivateKey, "", "PEM encoded private key of the GitHub App used to access repositories over HTTPS.")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.CacheDir, "", "Directory of the on-disk git repository cache. Repositories are fetched incrementally into it. When empty repositories are cloned in memory.")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.Host, "github.com", `Git host serving the repositories without configured URL, e.g. "gitlab.com".`)
+	daemonCommand.PersistentFlags().Duration(f.Service.GitHub.PollInterval, 0, `How often the git repositories are polled for new commits to requeue the affected Config CRs, e.g. "5m". Zero disables polling.`)
+	daemonCommand.PersistentFlags().Duration(f.Service.GitHub.MaxStaleness, 0, `How long the last known good configuration is used when the git repositories are unreachable, e.g. "1h". Zero disables the fallback.`)
 	daemonCommand.PersistentFlags().String(f.Service.GitHub.Token, "", "Token used to pull repositories from GitHub")
 
 	daemonCommand.PersistentFlags().String(f.Service.GitHub.RepositoryName, "config", "Token used to pull repositories from GitHub")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.RepositoryOwner, "giantswarm", "Owner of the config repository, e.g. the GitHub organization or GitLab group.")
 	daemonCommand.PersistentFlags().String(f.Service.GitHub.RepositoryRef, "main", "Token used to pull repositories from GitHub")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.RepositoryURL, "", "URL of the config repository. Supports https://, ssh://, file:// URLs and local paths. When empty the URL is built from the host, owner and name.")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.RepositoryUsername, "", `Username sent with the config repository token. Defaults to "x-access-token".`)
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.RepositoryToken, "", "Token used to pull the config repository over HTTPS, e.g. from another host than the git host. The git host token and GitHub App tokens are only sent to their own hosts.")
+
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.Signature.TrustedGPGKeysFile, "", "Path to the armored GPG key ring trusted to sign commits. When it or the trusted SSH keys file is set, only signed commits of the config and shared configs repositories are used.")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.Signature.TrustedSSHKeysFile, "", "Path to the file with SSH public keys in authorized_keys format trusted to sign commits. When it or the trusted GPG keys file is set, only signed commits of the config and shared configs repositories are used.")
 
 	daemonCommand.PersistentFlags().String(f.Service.GitHub.SSH.Key, "", "Token used to pull repositories from GitHub")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.SSH.KnownHostsFile, "", "Path to the known_hosts file SSH host keys of git servers are strictly verified against. When empty $SSH_KNOWN_HOSTS or ~/.ssh/known_hosts is used.")
 	daemonCommand.PersistentFlags().String(f.Service.GitHub.SSH.Password, "", "Token used to pull repositories from GitHub")
 
 	daemonCommand.PersistentFlags().String(f.Service.GitHub.SharedConfigRepository.Name, "shared-configs", "Token used to pull repositories from GitHub")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.SharedConfigRepository.Owner, "", "Owner of the shared configs repository. Defaults to the owner of the config repository.")
 	daemonCommand.PersistentFlags().String(f.Service.GitHub.SharedConfigRepository.Ref, "main", "Token used to pull repositories from GitHub")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.SharedConfigRepository.URL, "", "URL of the shared configs repository. Supports https://, ssh://, file:// URLs and local paths. When empty the URL is built from the host, owner and name.")
 	daemonCommand.PersistentFlags().String(f.Service.GitHub.SharedConfigRepository.Key, "", "Token used to pull repositories from GitHub")
 	daemonCommand.PersistentFlags().String(f.Service.GitHub.SharedConfigRepository.Password, "", "Token used to pull repositories from GitHub")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.SharedConfigRepositoriesFile, "", "Path to a YAML list of shared configs repositories overlaid in order onto the config repository. Each entry has name, owner, ref, url, key, password and refuseProtectedOverrides fields. Takes precedence over the single shared configs repository.")
+	daemonCommand.PersistentFlags().Bool(f.Service.GitHub.SharedConfigRepository.RefuseProtectedOverrides, false, `Fail when the config repository overrides files listed in the ".protected" file of the shared configs repository.`)
 
 	daemonCommand.PersistentFlags().String(f.Service.Installation.Name, "", `Installation codename (e.g. "geckon")`)
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.Address, "http://127.0.0.1:6443", "Address used to connect to Kubernetes. When empty in-cluster config is created.")
@@ -143,7 +183,18 @@ func mainE(ctx context.Context) error {
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.TLS.CrtFile, "", "Certificate file path to use to authenticate with Kubernetes.")
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.TLS.KeyFile, "", "Key file path to use to authenticate with Kubernetes.")
 	daemonCommand.PersistentFlags().String(f.Service.Vault.Address, "", "Vault server address")
-	daemonCommand.PersistentFlags().String(f.Service.Vault.Token, "", "Vault server address")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.Method, "token", `Vault auth method. One of "token", "kubernetes" or "approle". Tokens obtained with "kubernetes" and "approle" are renewed in the background.`)
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.AppRole.MountPath, "approle", "Mount path of the Vault AppRole auth method.")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.AppRole.RoleID, "", "Vault AppRole role ID.")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.AppRole.SecretIDFile, "", "Path to the file containing the Vault AppRole secret ID.")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.Kubernetes.MountPath, "kubernetes", "Mount path of the Vault Kubernetes auth method.")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.Kubernetes.Role, "config-controller", "Vault Kubernetes auth role.")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.Kubernetes.TokenPath, vault.DefaultKubernetesTokenPath, "Path to the service account token used to log in with the Vault Kubernetes auth method.")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.KV.AllowedPathPrefixes, "", `Comma separated Vault paths Vault KV references may read from, e.g. "kv/data/apps". References to other paths are rejected. All references are rejected when empty.`)
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Token, "", `Vault token used with the "token" auth method.`)
+	daemonCommand.PersistentFlags().String(f.Service.Vault.TransitKey, "config", "Name of the Vault transit key used to decrypt secrets. Apps can override it in their metadata.yaml.")
+	daemonCommand.PersistentFlags().String(f.Service.Webhook.GitHub.Secret, "", `Secret GitHub push webhooks are signed with. GitHub webhooks are refused when empty.`)
+	daemonCommand.PersistentFlags().String(f.Service.Webhook.GitLab.Token, "", `Secret token sent with GitLab push webhooks. GitLab webhooks are refused when empty.`)
 
 	newCommand.CobraCommand().SilenceErrors = true
 	newCommand.CobraCommand().SilenceUsage = true
@@ -0,0 +1,79 @@
+package decrypt
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+)
+
+// decryptBatch decrypts all the ciphertexts with the decrypter. Decrypters
+// not implementing BatchDecrypter decrypt the values one by one.
+func decryptBatch(ctx context.Context, d Decrypter, ciphertexts [][]byte) ([][]byte, error) {
+	if b, ok := d.(BatchDecrypter); ok {
+		plaintexts, err := b.DecryptBatch(ctx, ciphertexts)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return plaintexts, nil
+	}
+
+	plaintexts := make([][]byte, len(ciphertexts))
+	for i, c := range ciphertexts {
+		p, err := d.Decrypt(ctx, c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		plaintexts[i] = p
+	}
+
+	return plaintexts, nil
+}
+
+// splitBatch decrypts the ciphertexts matching isOwn with own and passes the
+// rest to next as a single batch. When next is nil the rest is returned
+// unchanged. The order of the values is kept.
+func splitBatch(ctx context.Context, ciphertexts [][]byte, isOwn func(string) bool, own func(context.Context, []byte) ([]byte, error), next Decrypter) ([][]byte, error) {
+	plaintexts := make([][]byte, len(ciphertexts))
+
+	var rest [][]byte
+	var restIndexes []int
+	for i, c := range ciphertexts {
+		if !isOwn(string(c)) {
+			rest = append(rest, c)
+			restIndexes = append(restIndexes, i)
+			continue
+		}
+
+		p, err := own(ctx, c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		plaintexts[i] = p
+	}
+
+	if len(rest) == 0 {
+		return plaintexts, nil
+	}
+
+	if next == nil {
+		for j, i := range restIndexes {
+			plaintexts[i] = rest[j]
+		}
+
+		return plaintexts, nil
+	}
+
+	decrypted, err := decryptBatch(ctx, next, rest)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for j, i := range restIndexes {
+		plaintexts[i] = decrypted[j]
+	}
+
+	return plaintexts, nil
+}
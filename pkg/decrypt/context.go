@@ -0,0 +1,43 @@
+package decrypt
+
+import "context"
+
+type keyContextKey struct{}
+
+type namespaceContextKey struct{}
+
+// NewContextWithKey returns a context overriding the encryption key name
+// used by decrypters supporting named keys, e.g. the Vault transit key used
+// by VaultDecrypter.
+func NewContextWithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// KeyFromContext returns the encryption key name set with
+// NewContextWithKey.
+func KeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(string)
+	if !ok || key == "" {
+		return "", false
+	}
+
+	return key, true
+}
+
+// NewContextWithNamespace returns a context carrying the namespace of the
+// Config CR the values are decrypted for. K8sSecretResolver allows
+// references to Secrets in that namespace.
+func NewContextWithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceContextKey{}, namespace)
+}
+
+// NamespaceFromContext returns the namespace set with
+// NewContextWithNamespace.
+func NamespaceFromContext(ctx context.Context) (string, bool) {
+	namespace, ok := ctx.Value(namespaceContextKey{}).(string)
+	if !ok || namespace == "" {
+		return "", false
+	}
+
+	return namespace, true
+}
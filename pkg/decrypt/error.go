@@ -10,3 +10,47 @@ var invalidConfigError = &microerror.Error{
 func IsInvalidConfig(err error) bool {
 	return microerror.Cause(err) == invalidConfigError
 }
+
+var invalidCiphertextError = &microerror.Error{
+	Kind: "invalidCiphertextError",
+}
+
+// IsInvalidCiphertext asserts invalidCiphertextError.
+func IsInvalidCiphertext(err error) bool {
+	return microerror.Cause(err) == invalidCiphertextError
+}
+
+// executionFailedError should never be matched against and therefore there is
+// no matcher implement. For further information see:
+//
+//	https://github.com/giantswarm/fmt/blob/master/go/errors.md#matching-errors
+var executionFailedError = &microerror.Error{
+	Kind: "executionFailedError",
+}
+
+var forbiddenReferenceError = &microerror.Error{
+	Kind: "forbiddenReferenceError",
+}
+
+// IsForbiddenReference asserts forbiddenReferenceError.
+func IsForbiddenReference(err error) bool {
+	return microerror.Cause(err) == forbiddenReferenceError
+}
+
+var invalidReferenceError = &microerror.Error{
+	Kind: "invalidReferenceError",
+}
+
+// IsInvalidReference asserts invalidReferenceError.
+func IsInvalidReference(err error) bool {
+	return microerror.Cause(err) == invalidReferenceError
+}
+
+var notFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
@@ -0,0 +1,239 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// ExecProtocolVersion is the version of the protocol spoken with exec
+	// plugins.
+	ExecProtocolVersion = "v1"
+
+	// DefaultExecTimeout is the time a plugin may run when no other
+	// timeout is configured.
+	DefaultExecTimeout = 30 * time.Second
+
+	execOperationDecrypt = "decrypt"
+	execOperationResolve = "resolve"
+)
+
+type ExecDecrypterConfig struct {
+	// Command is the path of the plugin executable.
+	Command string
+	// Args are passed to the plugin executable.
+	Args []string
+	// Decrypter decrypts values without Prefix. When it is not set such
+	// values are returned unchanged.
+	Decrypter Decrypter
+	// Prefix selects the values handled by the plugin, e.g. "op://".
+	Prefix string
+	// Timeout limits the time of a single plugin run. It defaults to
+	// DefaultExecTimeout.
+	Timeout time.Duration
+}
+
+// ExecDecrypter runs a local executable to decrypt values or resolve
+// references starting with the configured prefix, so secret backends can be
+// added without changes to the controller. Other values are passed to the
+// configured Decrypter.
+//
+// The plugin is run once per batch of values. It reads a JSON request from
+// stdin:
+//
+//	{
+//	  "apiVersion": "v1",
+//	  "operation": "decrypt",
+//	  "key": "config",
+//	  "values": ["op://vault/item/password"]
+//	}
+//
+// The operation is "decrypt" for values found in secret files and "resolve"
+// for references passed to the pluginSecret template function. The key is
+// the name of the encryption key configured for the app and may be empty.
+// Values are passed with the prefix.
+//
+// The plugin writes a JSON response to stdout with the plaintext values in
+// the request order:
+//
+//	{
+//	  "values": ["s3cr3t"]
+//	}
+//
+// A plugin fails by writing {"error": "message"} or exiting with a non-zero
+// status. Its stderr is included in the returned error.
+type ExecDecrypter struct {
+	decrypter Decrypter
+
+	args    []string
+	command string
+	prefix  string
+	timeout time.Duration
+}
+
+var _ Decrypter = &ExecDecrypter{}
+var _ BatchDecrypter = &ExecDecrypter{}
+var _ Resolver = &ExecDecrypter{}
+
+type execRequest struct {
+	APIVersion string   `json:"apiVersion"`
+	Operation  string   `json:"operation"`
+	Key        string   `json:"key,omitempty"`
+	Values     []string `json:"values"`
+}
+
+type execResponse struct {
+	Error  string   `json:"error,omitempty"`
+	Values []string `json:"values"`
+}
+
+func NewExecDecrypter(config ExecDecrypterConfig) (*ExecDecrypter, error) {
+	if config.Command == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Command must not be empty", config)
+	}
+	if config.Prefix == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Prefix must not be empty", config)
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = DefaultExecTimeout
+	}
+
+	d := &ExecDecrypter{
+		decrypter: config.Decrypter,
+
+		args:    config.Args,
+		command: config.Command,
+		prefix:  config.Prefix,
+		timeout: config.Timeout,
+	}
+
+	return d, nil
+}
+
+func (d *ExecDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	plaintexts, err := d.DecryptBatch(ctx, [][]byte{ciphertext})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return plaintexts[0], nil
+}
+
+// DecryptBatch passes all the values with the prefix to a single plugin run
+// and the other values to the Decrypter as a single batch.
+func (d *ExecDecrypter) DecryptBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error) {
+	var own []string
+	var rest [][]byte
+	for _, c := range ciphertexts {
+		if d.isOwn(string(c)) {
+			own = append(own, strings.TrimSpace(string(c)))
+		} else {
+			rest = append(rest, c)
+		}
+	}
+
+	var err error
+
+	var ownPlaintexts []string
+	if len(own) > 0 {
+		ownPlaintexts, err = d.run(ctx, execOperationDecrypt, own)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	restPlaintexts := rest
+	if len(rest) > 0 && d.decrypter != nil {
+		restPlaintexts, err = decryptBatch(ctx, d.decrypter, rest)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	plaintexts := make([][]byte, 0, len(ciphertexts))
+	for _, c := range ciphertexts {
+		if d.isOwn(string(c)) {
+			plaintexts = append(plaintexts, []byte(ownPlaintexts[0]))
+			ownPlaintexts = ownPlaintexts[1:]
+		} else {
+			plaintexts = append(plaintexts, restPlaintexts[0])
+			restPlaintexts = restPlaintexts[1:]
+		}
+	}
+
+	return plaintexts, nil
+}
+
+// Resolve returns the value of the reference resolved by the plugin.
+func (d *ExecDecrypter) Resolve(ctx context.Context, reference string) (string, error) {
+	if !d.isOwn(reference) {
+		value, err := resolveNext(ctx, d.decrypter, reference)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return value, nil
+	}
+
+	values, err := d.run(ctx, execOperationResolve, []string{strings.TrimSpace(reference)})
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return values[0], nil
+}
+
+func (d *ExecDecrypter) isOwn(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), d.prefix)
+}
+
+func (d *ExecDecrypter) run(ctx context.Context, operation string, values []string) ([]string, error) {
+	request := execRequest{
+		APIVersion: ExecProtocolVersion,
+		Operation:  operation,
+		Values:     values,
+	}
+	if key, ok := KeyFromContext(ctx); ok {
+		request.Key = key
+	}
+
+	stdin, err := json.Marshal(request)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, d.command, d.args...) //nolint:gosec
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "plugin %#q failed: %s: %s", d.command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var response execResponse
+	err = json.Unmarshal(stdout.Bytes(), &response)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "plugin %#q returned invalid response: %s", d.command, err)
+	}
+	if response.Error != "" {
+		return nil, microerror.Maskf(executionFailedError, "plugin %#q failed: %s", d.command, response.Error)
+	}
+	if len(response.Values) != len(values) {
+		return nil, microerror.Maskf(executionFailedError, "plugin %#q returned %d values for %d requested", d.command, len(response.Values), len(values))
+	}
+
+	return response.Values, nil
+}
@@ -0,0 +1,150 @@
+package decrypt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+// TestExecPluginHelper is not a real test. It is run by the ExecDecrypter
+// tests as the plugin executable. It returns the values without the "test:"
+// prefix in upper case and fails for values containing "fail".
+func TestExecPluginHelper(t *testing.T) {
+	runsFile := os.Getenv("EXEC_PLUGIN_HELPER_RUNS_FILE")
+	if runsFile == "" {
+		return
+	}
+
+	var request execRequest
+	err := json.NewDecoder(os.Stdin).Decode(&request)
+	if err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	f, err := os.OpenFile(runsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(f, "%s %s %s\n", request.Operation, request.Key, strings.Join(request.Values, ","))
+	_ = f.Close()
+
+	var response execResponse
+	for _, v := range request.Values {
+		if strings.Contains(v, "fail") {
+			response = execResponse{Error: "cannot decrypt " + v}
+			break
+		}
+		response.Values = append(response.Values, strings.ToUpper(strings.TrimPrefix(v, "test:")))
+	}
+
+	_ = json.NewEncoder(os.Stdout).Encode(response)
+	os.Exit(0)
+}
+
+func TestExecDecrypter(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		ctx                  context.Context
+		decrypter            Decrypter
+		values               []string
+		expectedResult       []string
+		expectedRuns         string
+		expectedErrorMessage string
+	}{
+		{
+			name:           "case 0: decrypt values with prefix in a single run",
+			values:         []string{"test:one", "plaintext", "test:two"},
+			expectedResult: []string{"ONE", "plaintext", "TWO"},
+			expectedRuns:   "decrypt  test:one,test:two\n",
+		},
+		{
+			name:           "case 1: pass other values to decrypter",
+			decrypter:      &testDecrypter{},
+			values:         []string{"vault:v1:abc", "test:one"},
+			expectedResult: []string{"decrypted", "ONE"},
+			expectedRuns:   "decrypt  test:one\n",
+		},
+		{
+			name:           "case 2: pass key from context",
+			ctx:            NewContextWithKey(context.Background(), "app"),
+			values:         []string{"test:one"},
+			expectedResult: []string{"ONE"},
+			expectedRuns:   "decrypt app test:one\n",
+		},
+		{
+			name:                 "case 3: plugin error",
+			values:               []string{"test:fail"},
+			expectedRuns:         "decrypt  test:fail\n",
+			expectedErrorMessage: "cannot decrypt test:fail",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runsFile := filepath.Join(t.TempDir(), "runs")
+			t.Setenv("EXEC_PLUGIN_HELPER_RUNS_FILE", runsFile)
+
+			decrypter, err := NewExecDecrypter(ExecDecrypterConfig{
+				Command:   os.Args[0],
+				Args:      []string{"-test.run=^TestExecPluginHelper$"},
+				Decrypter: tc.decrypter,
+				Prefix:    "test:",
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			ctx := tc.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			var ciphertexts [][]byte
+			for _, v := range tc.values {
+				ciphertexts = append(ciphertexts, []byte(v))
+			}
+
+			result, err := decrypter.DecryptBatch(ctx, ciphertexts)
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			runs, err := os.ReadFile(runsFile)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if string(runs) != tc.expectedRuns {
+				t.Fatalf("runs = %q, want %q", runs, tc.expectedRuns)
+			}
+
+			if tc.expectedErrorMessage != "" {
+				return
+			}
+
+			for i := range tc.expectedResult {
+				if string(result[i]) != tc.expectedResult[i] {
+					t.Fatalf("result[%d] = %q, want %q", i, result[i], tc.expectedResult[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package decrypt
+
+import (
+	"context"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	k8sReferencePrefix = "k8s:"
+)
+
+type K8sSecretResolverConfig struct {
+	// Decrypter decrypts values which are not Kubernetes Secret
+	// references. When it is not set such values are returned unchanged.
+	Decrypter Decrypter
+	K8sClient client.Client
+
+	// AllowedNamespaces are the namespaces Secrets may be referenced from
+	// in addition to the namespace set with NewContextWithNamespace, i.e.
+	// the namespace of the Config CR. References to Secrets in other
+	// namespaces are rejected, so whoever can push to the config
+	// repositories can't read arbitrary Secrets.
+	AllowedNamespaces []string
+}
+
+// K8sSecretResolver resolves references to keys of in-cluster Secrets in the
+// "k8s:<namespace>/<name>#<key>" format, e.g.
+// "k8s:giantswarm/app-tls#tls.crt". Only Secrets in the namespace of the
+// Config CR and in the allowed namespaces can be referenced. Other values are passed to the
+// configured Decrypter. Other references are passed to the Decrypter too
+// when it implements Resolver.
+type K8sSecretResolver struct {
+	decrypter Decrypter
+	k8sClient client.Client
+
+	allowedNamespaces map[string]bool
+}
+
+var _ Decrypter = &K8sSecretResolver{}
+var _ BatchDecrypter = &K8sSecretResolver{}
+var _ Resolver = &K8sSecretResolver{}
+
+func NewK8sSecretResolver(config K8sSecretResolverConfig) (*K8sSecretResolver, error) {
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+
+	allowedNamespaces := map[string]bool{}
+	for _, n := range config.AllowedNamespaces {
+		allowedNamespaces[n] = true
+	}
+
+	r := &K8sSecretResolver{
+		decrypter: config.Decrypter,
+		k8sClient: config.K8sClient,
+
+		allowedNamespaces: allowedNamespaces,
+	}
+
+	return r, nil
+}
+
+func (r *K8sSecretResolver) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	if IsK8sSecretReference(string(ciphertext)) {
+		value, err := r.Resolve(ctx, string(ciphertext))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return []byte(value), nil
+	}
+
+	if r.decrypter == nil {
+		return ciphertext, nil
+	}
+
+	plaintext, err := r.decrypter.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return plaintext, nil
+}
+
+// DecryptBatch resolves the references one by one and passes the other
+// values to the Decrypter as a single batch.
+func (r *K8sSecretResolver) DecryptBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error) {
+	plaintexts, err := splitBatch(ctx, ciphertexts, IsK8sSecretReference, r.Decrypt, r.decrypter)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return plaintexts, nil
+}
+
+// Resolve returns the value of the Secret key.
+func (r *K8sSecretResolver) Resolve(ctx context.Context, reference string) (string, error) {
+	if !IsK8sSecretReference(reference) {
+		value, err := resolveNext(ctx, r.decrypter, reference)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return value, nil
+	}
+
+	ref, key, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(reference), k8sReferencePrefix), "#")
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" || key == "" {
+		return "", microerror.Maskf(invalidReferenceError, "reference %#q must be in \"k8s:<namespace>/<name>#<key>\" format", reference)
+	}
+
+	if !r.isAllowed(ctx, namespace) {
+		return "", microerror.Maskf(forbiddenReferenceError, "Secret %#q is not in the Config CR namespace or any of the allowed namespaces", namespace+"/"+name)
+	}
+
+	var secret corev1.Secret
+	err := r.k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &secret)
+	if apierrors.IsNotFound(err) {
+		return "", microerror.Maskf(notFoundError, "Secret %#q not found", namespace+"/"+name)
+	} else if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", microerror.Maskf(notFoundError, "key %#q not found in Secret %#q", key, namespace+"/"+name)
+	}
+
+	return string(value), nil
+}
+
+// isAllowed returns true when the namespace is the Config CR namespace or one
+// of the allowed namespaces.
+func (r *K8sSecretResolver) isAllowed(ctx context.Context, namespace string) bool {
+	if n, ok := NamespaceFromContext(ctx); ok && n == namespace {
+		return true
+	}
+
+	return r.allowedNamespaces[namespace]
+}
+
+// IsK8sSecretReference returns true when the value is a Kubernetes Secret
+// reference in the "k8s:<namespace>/<name>#<key>" format.
+func IsK8sSecretReference(value string) bool {
+	value = strings.TrimSpace(value)
+	return strings.HasPrefix(value, k8sReferencePrefix) && strings.Contains(value, "#")
+}
@@ -0,0 +1,145 @@
+package decrypt
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestK8sSecretResolver(t *testing.T) {
+	k8sClient := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app-tls",
+				Namespace: "giantswarm",
+			},
+			Data: map[string][]byte{
+				"tls.crt": []byte("certificate"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "shared",
+				Namespace: "monitoring",
+			},
+			Data: map[string][]byte{
+				"token": []byte("monitoring-token"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "admin",
+				Namespace: "kube-system",
+			},
+			Data: map[string][]byte{
+				"token": []byte("admin-token"),
+			},
+		},
+	).Build()
+
+	testCases := []struct {
+		name                 string
+		decrypter            Decrypter
+		value                string
+		expectedResult       string
+		expectedErrorMessage string
+	}{
+		{
+			name:           "case 0: resolve Secret reference",
+			value:          "k8s:giantswarm/app-tls#tls.crt",
+			expectedResult: "certificate",
+		},
+		{
+			name:           "case 1: return other values unchanged without decrypter",
+			value:          "plaintext",
+			expectedResult: "plaintext",
+		},
+		{
+			name:           "case 2: decrypt other values with decrypter",
+			decrypter:      &testDecrypter{},
+			value:          "vault:v1:abc",
+			expectedResult: "decrypted",
+		},
+		{
+			name:                 "case 3: missing key",
+			value:                "k8s:giantswarm/app-tls#tls.key",
+			expectedErrorMessage: "key `tls.key` not found in Secret `giantswarm/app-tls`",
+		},
+		{
+			name:                 "case 4: missing Secret",
+			value:                "k8s:giantswarm/missing#tls.crt",
+			expectedErrorMessage: "Secret `giantswarm/missing` not found",
+		},
+		{
+			name:                 "case 5: reference without namespace",
+			value:                "k8s:app-tls#tls.crt",
+			expectedErrorMessage: "reference `k8s:app-tls#tls.crt` must be in",
+		},
+		{
+			name:           "case 6: resolve Secret reference in allowed namespace",
+			value:          "k8s:monitoring/shared#token",
+			expectedResult: "monitoring-token",
+		},
+		{
+			name:                 "case 7: reject Secret reference in other namespace",
+			value:                "k8s:kube-system/admin#token",
+			expectedErrorMessage: "Secret `kube-system/admin` is not in the Config CR namespace or any of the allowed namespaces",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolver, err := NewK8sSecretResolver(K8sSecretResolverConfig{
+				Decrypter: tc.decrypter,
+				K8sClient: k8sClient,
+
+				AllowedNamespaces: []string{"monitoring"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			ctx := NewContextWithNamespace(context.Background(), "giantswarm")
+
+			result, err := resolver.Decrypt(ctx, []byte(tc.value))
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if string(result) != tc.expectedResult {
+				t.Fatalf("result = %q, want %q", result, tc.expectedResult)
+			}
+		})
+	}
+	// Without the Config CR namespace only the allowed namespaces can be
+	// referenced.
+	resolver, err := NewK8sSecretResolver(K8sSecretResolverConfig{
+		K8sClient: k8sClient,
+
+		AllowedNamespaces: []string{"monitoring"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	_, err = resolver.Resolve(context.Background(), "k8s:giantswarm/app-tls#tls.crt")
+	if !IsForbiddenReference(err) {
+		t.Fatalf("expected forbidden reference error but got %v", err)
+	}
+}
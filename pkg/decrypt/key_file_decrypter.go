@@ -0,0 +1,131 @@
+package decrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// CiphertextPrefix is the prefix of Vault transit ciphertext. It is
+	// followed by the key version, e.g. "vault:v1:...".
+	CiphertextPrefix = "vault:v"
+)
+
+type KeyFileDecrypterConfig struct {
+	// KeyFile is the content of the exported Vault transit key. It is the
+	// output of:
+	//
+	//	vault read -format=json transit/export/encryption-key/config
+	//
+	// Both the whole response and its "data" field are accepted.
+	KeyFile []byte
+}
+
+// KeyFileDecrypter decrypts Vault transit ciphertext offline using exported
+// aes256-gcm96 transit key versions.
+type KeyFileDecrypter struct {
+	keys map[int][]byte
+}
+
+var _ Decrypter = &KeyFileDecrypter{}
+
+func NewKeyFileDecrypter(config KeyFileDecrypterConfig) (*KeyFileDecrypter, error) {
+	if len(config.KeyFile) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.KeyFile must not be empty", config)
+	}
+
+	var exported struct {
+		Data struct {
+			Keys map[string]string `json:"keys"`
+		} `json:"data"`
+		Keys map[string]string `json:"keys"`
+	}
+	err := yaml.Unmarshal(config.KeyFile, &exported)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.KeyFile must be a valid exported transit key: %s", config, err)
+	}
+
+	raw := exported.Keys
+	if len(raw) == 0 {
+		raw = exported.Data.Keys
+	}
+	if len(raw) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.KeyFile must contain at least one key version", config)
+	}
+
+	keys := map[int][]byte{}
+	for v, k := range raw {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "%T.KeyFile key version %#q must be a number", config, v)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(k)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "%T.KeyFile key version %#q must be base64 encoded", config, v)
+		}
+
+		keys[version] = key
+	}
+
+	d := &KeyFileDecrypter{
+		keys: keys,
+	}
+
+	return d, nil
+}
+
+func (d *KeyFileDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	s := strings.TrimSpace(string(ciphertext))
+	if !strings.HasPrefix(s, CiphertextPrefix) {
+		return nil, microerror.Maskf(invalidCiphertextError, "ciphertext must start with %#q", CiphertextPrefix)
+	}
+
+	v, encoded, ok := strings.Cut(strings.TrimPrefix(s, CiphertextPrefix), ":")
+	if !ok {
+		return nil, microerror.Maskf(invalidCiphertextError, "ciphertext must be in \"vault:v<version>:<data>\" format")
+	}
+
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, microerror.Maskf(invalidCiphertextError, "ciphertext key version %#q must be a number", v)
+	}
+
+	key, ok := d.keys[version]
+	if !ok {
+		return nil, microerror.Maskf(invalidCiphertextError, "key version %d not found in the key file", version)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, microerror.Maskf(invalidCiphertextError, "ciphertext data must be base64 encoded")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, microerror.Maskf(invalidCiphertextError, "ciphertext data is too short")
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, microerror.Maskf(invalidCiphertextError, "failed to decrypt with key version %d: %s", version, err)
+	}
+
+	return plaintext, nil
+}
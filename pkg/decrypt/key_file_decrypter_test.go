@@ -0,0 +1,90 @@
+package decrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+func TestKeyFileDecrypter(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	keyFile := fmt.Sprintf(`{"data": {"name": "config", "type": "aes256-gcm96", "keys": {"2": %q}}}`, base64.StdEncoding.EncodeToString(key))
+
+	testCases := []struct {
+		name                 string
+		ciphertext           string
+		expectedResult       string
+		expectedErrorMessage string
+	}{
+		{
+			name:           "case 0: decrypt with known key version",
+			ciphertext:     "vault:v2:" + testEncrypt(t, key, "secret"),
+			expectedResult: "secret",
+		},
+		{
+			name:                 "case 1: unknown key version",
+			ciphertext:           "vault:v1:" + testEncrypt(t, key, "secret"),
+			expectedErrorMessage: "key version 1 not found in the key file",
+		},
+		{
+			name:                 "case 2: not a ciphertext",
+			ciphertext:           "plaintext",
+			expectedErrorMessage: "ciphertext must start with `vault:v`",
+		},
+		{
+			name:                 "case 3: wrong key",
+			ciphertext:           "vault:v2:" + testEncrypt(t, []byte("fedcba9876543210fedcba9876543210"), "secret"),
+			expectedErrorMessage: "failed to decrypt with key version 2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewKeyFileDecrypter(KeyFileDecrypterConfig{KeyFile: []byte(keyFile)})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			result, err := d.Decrypt(context.Background(), []byte(tc.ciphertext))
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if string(result) != tc.expectedResult {
+				t.Fatalf("result = %q, want %q", result, tc.expectedResult)
+			}
+		})
+	}
+}
+
+func testEncrypt(t *testing.T, key []byte, plaintext string) string {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil))
+}
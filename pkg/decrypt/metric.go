@@ -0,0 +1,66 @@
+package decrypt
+
+import (
+	"github.com/giantswarm/microerror"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	PrometheusNamespace = "config_controller"
+	PrometheusSubsystem = "vault"
+)
+
+const (
+	operationDecrypt = "decrypt"
+	operationKVRead  = "kv_read"
+)
+
+var (
+	cacheHitCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "decrypt_cache_hits_total",
+			Help:      "Number of Vault transit ciphertexts decrypted from the in-memory cache.",
+		},
+	)
+
+	cacheMissCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "decrypt_cache_misses_total",
+			Help:      "Number of Vault transit ciphertexts not found in the in-memory cache.",
+		},
+	)
+
+	requestHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Time taken by Vault requests.",
+		},
+		[]string{"operation"},
+	)
+)
+
+// RegisterMetrics registers the metrics of the decrypters and resolvers with
+// the registerer. The metrics are collected without registration too, so
+// library users not exposing them don't need to call it.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		cacheHitCounter,
+		cacheMissCounter,
+		requestHistogram,
+	}
+
+	for _, c := range collectors {
+		err := registerer.Register(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package decrypt
+
+import (
+	"context"
+)
+
+// NoneDecrypter does not decrypt anything and returns the ciphertext as is.
+// It is useful to render the secret structure without access to the
+// encryption keys.
+type NoneDecrypter struct{}
+
+var _ Decrypter = &NoneDecrypter{}
+
+func NewNoneDecrypter() *NoneDecrypter {
+	return &NoneDecrypter{}
+}
+
+func (d *NoneDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	return ciphertext, nil
+}
@@ -0,0 +1,24 @@
+package decrypt
+
+import (
+	"context"
+)
+
+// RedactDecrypter does not decrypt anything and replaces the ciphertext with
+// a placeholder of the Redactor. Equal ciphertexts result in equal
+// placeholders.
+type RedactDecrypter struct {
+	redactor *Redactor
+}
+
+var _ Decrypter = &RedactDecrypter{}
+
+func NewRedactDecrypter(redactor *Redactor) *RedactDecrypter {
+	return &RedactDecrypter{
+		redactor: redactor,
+	}
+}
+
+func (d *RedactDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	return []byte(d.redactor.Redact(ciphertext)), nil
+}
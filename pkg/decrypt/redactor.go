@@ -0,0 +1,60 @@
+package decrypt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/giantswarm/microerror"
+)
+
+type RedactorConfig struct {
+	// Key is the HMAC key of the placeholders. Redactors with the same key
+	// return the same placeholders, so redacted outputs of different runs
+	// can be compared. When empty a random key is generated.
+	Key []byte
+}
+
+// Redactor replaces values with placeholders. Placeholders are HMAC-SHA256
+// sums of the values. Equal values redacted with the same key result in
+// equal placeholders, but placeholders can't be used to confirm guesses of
+// the values without the key.
+type Redactor struct {
+	key []byte
+}
+
+func NewRedactor(config RedactorConfig) (*Redactor, error) {
+	key := config.Key
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		_, err := rand.Read(key)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	r := &Redactor{
+		key: key,
+	}
+
+	return r, nil
+}
+
+// Redact returns the placeholder of the value.
+func (r *Redactor) Redact(value []byte) string {
+	return fmt.Sprintf("redacted-%x", r.sum(value)[:4])
+}
+
+// RedactNumber returns the placeholder of the value as a number. It is used
+// where the placeholder must keep the type of a non-string value.
+func (r *Redactor) RedactNumber(value []byte) uint32 {
+	return binary.BigEndian.Uint32(r.sum(value)[:4])
+}
+
+func (r *Redactor) sum(value []byte) []byte {
+	mac := hmac.New(sha256.New, r.key)
+	_, _ = mac.Write(value)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,53 @@
+package decrypt
+
+import (
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	r1, err := NewRedactor(RedactorConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	r2, err := NewRedactor(RedactorConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if r1.Redact([]byte("secret")) != r1.Redact([]byte("secret")) {
+		t.Fatalf("placeholders of equal values differ within a Redactor")
+	}
+	if r1.Redact([]byte("secret")) == r1.Redact([]byte("other")) {
+		t.Fatalf("placeholders of different values are equal")
+	}
+	// The placeholders must not be reproducible without the random key.
+	if r1.Redact([]byte("secret")) == r2.Redact([]byte("secret")) {
+		t.Fatalf("placeholders of different Redactors are equal")
+	}
+}
+
+func TestRedactor_key(t *testing.T) {
+	r1, err := NewRedactor(RedactorConfig{Key: []byte("key")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	r2, err := NewRedactor(RedactorConfig{Key: []byte("key")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	r3, err := NewRedactor(RedactorConfig{Key: []byte("other key")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	// Runs with the same key must result in the same placeholders.
+	if r1.Redact([]byte("secret")) != r2.Redact([]byte("secret")) {
+		t.Fatalf("placeholders of Redactors with equal keys differ")
+	}
+	if r1.RedactNumber([]byte("1234")) != r2.RedactNumber([]byte("1234")) {
+		t.Fatalf("number placeholders of Redactors with equal keys differ")
+	}
+	if r1.Redact([]byte("secret")) == r3.Redact([]byte("secret")) {
+		t.Fatalf("placeholders of Redactors with different keys are equal")
+	}
+}
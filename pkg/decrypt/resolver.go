@@ -0,0 +1,23 @@
+package decrypt
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+)
+
+// resolveNext passes the reference to the next Decrypter in the chain when it
+// implements Resolver.
+func resolveNext(ctx context.Context, next Decrypter, reference string) (string, error) {
+	r, ok := next.(Resolver)
+	if !ok {
+		return "", microerror.Maskf(invalidReferenceError, "unsupported reference %#q", reference)
+	}
+
+	value, err := r.Resolve(ctx, reference)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,179 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	sopsage "github.com/getsops/sops/v3/age"
+	sopsconfig "github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/keyservice"
+	sopsyaml "github.com/getsops/sops/v3/stores/yaml"
+	"github.com/giantswarm/microerror"
+)
+
+type SOPSTraverserConfig struct {
+	// AgeKeys is the content of an age identity file, e.g. the one
+	// created with age-keygen. It may contain multiple identities.
+	AgeKeys []byte
+	// PGPKeys are ASCII armored PGP private keys. The keys must not be
+	// protected with a passphrase.
+	PGPKeys []byte
+}
+
+// SOPSTraverser decrypts whole YAML documents encrypted with SOPS using age
+// or PGP keys. Documents without SOPS metadata are returned unchanged.
+type SOPSTraverser struct {
+	keyService keyservice.KeyServiceClient
+	store      *sopsyaml.Store
+}
+
+func NewSOPSTraverser(config SOPSTraverserConfig) (*SOPSTraverser, error) {
+	if len(config.AgeKeys) == 0 && len(config.PGPKeys) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.AgeKeys or %T.PGPKeys must not be empty", config, config)
+	}
+
+	s := &sopsKeyService{}
+
+	if len(config.AgeKeys) > 0 {
+		err := s.ageIdentities.Import(string(config.AgeKeys))
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "%T.AgeKeys must contain valid age identities: %s", config, err)
+		}
+	}
+
+	if len(config.PGPKeys) > 0 {
+		keyRing, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(config.PGPKeys))
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "%T.PGPKeys must contain valid armored PGP keys: %s", config, err)
+		}
+
+		s.pgpKeyRing = keyRing
+	}
+
+	t := &SOPSTraverser{
+		keyService: keyservice.NewCustomLocalClient(s),
+		store:      sopsyaml.NewStore(&sopsconfig.YAMLStoreConfig{Indent: 2}),
+	}
+
+	return t, nil
+}
+
+func (t *SOPSTraverser) Traverse(ctx context.Context, yamlData []byte) ([]byte, error) {
+	tree, err := t.store.LoadEncryptedFile(yamlData)
+	if errors.Is(err, sops.MetadataNotFound) {
+		return yamlData, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	key, err := tree.Metadata.GetDataKeyWithKeyServices([]keyservice.KeyServiceClient{t.keyService}, nil)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "failed to get SOPS data key: %s", err)
+	}
+
+	cipher := aes.NewCipher()
+
+	mac, err := tree.Decrypt(key, cipher)
+	if err != nil {
+		return nil, microerror.Maskf(invalidCiphertextError, "%s", err)
+	}
+
+	// The MAC is checked the same way as "sops decrypt" does to detect
+	// modified or reordered values.
+	originalMAC, err := cipher.Decrypt(tree.Metadata.MessageAuthenticationCode, key, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, microerror.Maskf(invalidCiphertextError, "failed to decrypt MAC: %s", err)
+	}
+	if originalMAC != mac {
+		return nil, microerror.Maskf(invalidCiphertextError, "failed to verify data integrity, MAC mismatch")
+	}
+
+	decrypted, err := t.store.EmitPlainFile(tree.Branches)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return decrypted, nil
+}
+
+// sopsKeyService decrypts SOPS data keys in process with the configured keys
+// only. Unlike keyservice.Server it never looks up keys in the environment,
+// the home directory or cloud KMS services.
+type sopsKeyService struct {
+	ageIdentities sopsage.ParsedIdentities
+	pgpKeyRing    openpgp.EntityList
+}
+
+func (s *sopsKeyService) Encrypt(ctx context.Context, req *keyservice.EncryptRequest) (*keyservice.EncryptResponse, error) {
+	return nil, microerror.Maskf(executionFailedError, "encryption is not supported")
+}
+
+func (s *sopsKeyService) Decrypt(ctx context.Context, req *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	var plaintext []byte
+	var err error
+
+	switch k := req.GetKey().GetKeyType().(type) {
+	case *keyservice.Key_AgeKey:
+		plaintext, err = s.decryptWithAge(k.AgeKey, req.GetCiphertext())
+	case *keyservice.Key_PgpKey:
+		plaintext, err = s.decryptWithPGP(req.GetCiphertext())
+	default:
+		err = microerror.Maskf(executionFailedError, "unsupported SOPS key type %T", k)
+	}
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+}
+
+func (s *sopsKeyService) decryptWithAge(key *keyservice.AgeKey, ciphertext []byte) ([]byte, error) {
+	// An empty identity list would make MasterKey.Decrypt load identities
+	// from the environment.
+	if len(s.ageIdentities) == 0 {
+		return nil, microerror.Maskf(executionFailedError, "no age keys configured")
+	}
+
+	k := &sopsage.MasterKey{
+		Recipient:    key.GetRecipient(),
+		EncryptedKey: string(ciphertext),
+	}
+	s.ageIdentities.ApplyToMasterKey(k)
+
+	plaintext, err := k.Decrypt()
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "%s", err)
+	}
+
+	return plaintext, nil
+}
+
+func (s *sopsKeyService) decryptWithPGP(ciphertext []byte) ([]byte, error) {
+	if len(s.pgpKeyRing) == 0 {
+		return nil, microerror.Maskf(executionFailedError, "no PGP keys configured")
+	}
+
+	block, err := armor.Decode(bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "failed to decode PGP message: %s", err)
+	}
+
+	md, err := openpgp.ReadMessage(block.Body, s.pgpKeyRing, nil, nil)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "failed to read PGP message: %s", err)
+	}
+
+	plaintext, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return plaintext, nil
+}
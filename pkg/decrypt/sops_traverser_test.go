@@ -0,0 +1,123 @@
+package decrypt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSOPSTraverser(t *testing.T) {
+	ageKeys := testReadFile(t, "sops_age.key")
+	pgpKeys := testReadFile(t, "sops_pgp.asc")
+
+	expectedResult := `# Installation secrets.
+registry:
+  password: hunter2
+  port: 5000
+  tls: true
+apps:
+  - name: app-operator
+    token: s3cr3t
+`
+
+	testCases := []struct {
+		name                 string
+		config               SOPSTraverserConfig
+		input                []byte
+		expectedResult       string
+		expectedErrorMessage string
+	}{
+		{
+			name:           "case 0: decrypt with age key",
+			config:         SOPSTraverserConfig{AgeKeys: ageKeys},
+			input:          testReadFile(t, "sops_age.yaml"),
+			expectedResult: expectedResult,
+		},
+		{
+			name:           "case 1: decrypt with PGP key",
+			config:         SOPSTraverserConfig{PGPKeys: pgpKeys},
+			input:          testReadFile(t, "sops_pgp.yaml"),
+			expectedResult: expectedResult,
+		},
+		{
+			name:           "case 2: decrypt with both keys configured",
+			config:         SOPSTraverserConfig{AgeKeys: ageKeys, PGPKeys: pgpKeys},
+			input:          testReadFile(t, "sops_pgp.yaml"),
+			expectedResult: expectedResult,
+		},
+		{
+			name:           "case 3: return documents without SOPS metadata unchanged",
+			config:         SOPSTraverserConfig{AgeKeys: ageKeys},
+			input:          []byte("key: vault:v1:abc\n"),
+			expectedResult: "key: vault:v1:abc\n",
+		},
+		{
+			name:                 "case 4: fail when a value is removed",
+			config:               SOPSTraverserConfig{AgeKeys: ageKeys},
+			input:                regexp.MustCompile(`(?m)^\s+tls: .*\n`).ReplaceAll(testReadFile(t, "sops_age.yaml"), nil),
+			expectedErrorMessage: "MAC mismatch",
+		},
+		{
+			name:                 "case 5: fail without matching key",
+			config:               SOPSTraverserConfig{PGPKeys: pgpKeys},
+			input:                testReadFile(t, "sops_age.yaml"),
+			expectedErrorMessage: "failed to get SOPS data key",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			traverser, err := NewSOPSTraverser(tc.config)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			result, err := traverser.Traverse(context.Background(), tc.input)
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if !cmp.Equal(tc.expectedResult, string(result)) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedResult, string(result)))
+			}
+		})
+	}
+}
+
+func TestNewSOPSTraverser(t *testing.T) {
+	_, err := NewSOPSTraverser(SOPSTraverserConfig{})
+	if !IsInvalidConfig(err) {
+		t.Fatalf("expected invalid config error but got %v", err)
+	}
+
+	_, err = NewSOPSTraverser(SOPSTraverserConfig{AgeKeys: []byte("not a key")})
+	if !IsInvalidConfig(err) {
+		t.Fatalf("expected invalid config error but got %v", err)
+	}
+}
+
+func testReadFile(t *testing.T, name string) []byte {
+	bs, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	return bs
+}
@@ -5,3 +5,19 @@ import "context"
 type Decrypter interface {
 	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
 }
+
+// BatchDecrypter is implemented by decrypters which decrypt multiple values
+// more efficiently than one by one, e.g. with a single request.
+type BatchDecrypter interface {
+	DecryptBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error)
+}
+
+// Resolver resolves references to secrets stored outside of the
+// configuration repository.
+type Resolver interface {
+	Resolve(ctx context.Context, reference string) (string, error)
+}
+
+type Encrypter interface {
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+}
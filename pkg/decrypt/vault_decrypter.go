@@ -2,57 +2,158 @@ package decrypt
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
-
-	"github.com/giantswarm/valuemodifier/vault/decrypt"
+	"fmt"
+	"path"
+	"time"
 
 	"github.com/giantswarm/microerror"
 	vaultapi "github.com/hashicorp/vault/api"
+	gocache "github.com/patrickmn/go-cache"
 )
 
 const (
-	key = "config"
+	// DefaultKey is the name of the Vault transit key used when no other
+	// key is configured.
+	DefaultKey = "config"
+
+	// DefaultCacheTTL is the time decrypted values are kept in memory when
+	// no other TTL is configured.
+	DefaultCacheTTL = time.Minute
 )
 
 type VaultDecrypterConfig struct {
 	VaultClient *vaultapi.Client
+
+	// CacheTTL is the time decrypted values are kept in memory. It
+	// defaults to DefaultCacheTTL.
+	CacheTTL time.Duration
+	// Key is the name of the Vault transit key. It defaults to DefaultKey
+	// and can be overridden per call with NewContextWithKey.
+	Key string
 }
 
+// VaultDecrypter decrypts Vault transit ciphertext. Multiple values are
+// decrypted with a single batch request and decrypted values are cached in
+// memory by the hash of the key name and ciphertext.
 type VaultDecrypter struct {
+	cache       *gocache.Cache
 	vaultClient *vaultapi.Client
+
+	key string
 }
 
 var _ Decrypter = &VaultDecrypter{}
+var _ BatchDecrypter = &VaultDecrypter{}
 
 func NewVaultDecrypter(config VaultDecrypterConfig) (*VaultDecrypter, error) {
 	if config.VaultClient == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
 	}
 
+	if config.CacheTTL == 0 {
+		config.CacheTTL = DefaultCacheTTL
+	}
+	if config.Key == "" {
+		config.Key = DefaultKey
+	}
+
 	d := &VaultDecrypter{
+		cache:       gocache.New(config.CacheTTL, config.CacheTTL/2),
 		vaultClient: config.VaultClient,
+
+		key: config.Key,
 	}
 
 	return d, nil
 }
 
 func (d *VaultDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
-	service, err := decrypt.New(decrypt.Config{VaultClient: d.vaultClient, Key: key})
-
+	plaintexts, err := d.DecryptBatch(ctx, [][]byte{ciphertext})
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	plainText, err := service.Decrypt(ciphertext)
+	return plaintexts[0], nil
+}
 
-	if err != nil {
-		return nil, microerror.Mask(err)
+// DecryptBatch decrypts all the ciphertexts not found in the cache with a
+// single Vault request.
+func (d *VaultDecrypter) DecryptBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error) {
+	key := d.key
+	if k, ok := KeyFromContext(ctx); ok {
+		key = k
 	}
 
-	decoded, err := base64.StdEncoding.DecodeString(plainText)
+	plaintexts := make([][]byte, len(ciphertexts))
+
+	// missing maps ciphertexts not found in the cache to their indexes, so
+	// duplicates are decrypted once.
+	missing := map[string][]int{}
+	var batchInput []interface{}
+	for i, c := range ciphertexts {
+		if p, ok := d.cache.Get(cacheKey(key, c)); ok {
+			cacheHitCounter.Inc()
+			plaintexts[i] = append([]byte(nil), p.([]byte)...)
+			continue
+		}
+
+		cacheMissCounter.Inc()
+		if _, ok := missing[string(c)]; !ok {
+			batchInput = append(batchInput, map[string]interface{}{
+				"ciphertext": string(c),
+			})
+		}
+		missing[string(c)] = append(missing[string(c)], i)
+	}
+
+	if len(batchInput) == 0 {
+		return plaintexts, nil
+	}
+
+	start := time.Now()
+	secret, err := d.vaultClient.Logical().WriteWithContext(ctx, path.Join("transit", "decrypt", key), map[string]interface{}{
+		"batch_input": batchInput,
+	})
+	requestHistogram.WithLabelValues(operationDecrypt).Observe(time.Since(start).Seconds())
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
+	if secret == nil {
+		return nil, microerror.Maskf(executionFailedError, "Vault returned no data for transit key %#q", key)
+	}
+
+	results, ok := secret.Data["batch_results"].([]interface{})
+	if !ok || len(results) != len(batchInput) {
+		return nil, microerror.Maskf(executionFailedError, "Vault returned %d batch results for %d ciphertexts", len(results), len(batchInput))
+	}
+
+	for i, r := range results {
+		ciphertext := batchInput[i].(map[string]interface{})["ciphertext"].(string)
+
+		result, _ := r.(map[string]interface{})
+		if e, ok := result["error"]; ok && e != "" {
+			return nil, microerror.Maskf(executionFailedError, "failed to decrypt with transit key %#q: %v", key, e)
+		}
+
+		plaintext, err := base64.StdEncoding.DecodeString(fmt.Sprintf("%v", result["plaintext"]))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		// Callers own the returned plaintexts and may modify them, so
+		// neither the cache nor other callers share their memory.
+		d.cache.SetDefault(cacheKey(key, []byte(ciphertext)), append([]byte(nil), plaintext...))
+		for _, j := range missing[ciphertext] {
+			plaintexts[j] = append([]byte(nil), plaintext...)
+		}
+	}
+
+	return plaintexts, nil
+}
 
-	return decoded, nil
+func cacheKey(key string, ciphertext []byte) string {
+	sum := sha256.Sum256(append([]byte(key+":"), ciphertext...))
+	return fmt.Sprintf("%x", sum)
 }
@@ -0,0 +1,113 @@
+package decrypt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestVaultDecrypter(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		var body struct {
+			BatchInput []struct {
+				Ciphertext string `json:"ciphertext"`
+			} `json:"batch_input"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var results []map[string]string
+		for _, in := range body.BatchInput {
+			if !strings.HasPrefix(in.Ciphertext, "vault:v1:") {
+				results = append(results, map[string]string{"error": "invalid ciphertext"})
+				continue
+			}
+
+			plaintext := strings.TrimPrefix(in.Ciphertext, "vault:v1:")
+			results = append(results, map[string]string{"plaintext": base64.StdEncoding.EncodeToString([]byte(plaintext))})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"batch_results": results,
+			},
+		})
+	}))
+	defer server.Close()
+
+	c := vaultapi.DefaultConfig()
+	c.Address = server.URL
+	vaultClient, err := vaultapi.NewClient(c)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	decrypter, err := NewVaultDecrypter(VaultDecrypterConfig{
+		VaultClient: vaultClient,
+	})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	traverser, err := NewYAMLTraverser(YAMLTraverserConfig{
+		Decrypter: decrypter,
+	})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	input := []byte("a: vault:v1:one\nb: vault:v1:two\nc: vault:v1:one\n")
+	expectedResult := "a: one\nb: two\nc: one\n"
+
+	for i := 0; i < 2; i++ {
+		result, err := traverser.Traverse(context.Background(), input)
+		if err != nil {
+			t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+		}
+
+		if string(result) != expectedResult {
+			t.Fatalf("result = %q, want %q", result, expectedResult)
+		}
+	}
+
+	// The first traversal decrypts all the values with a single request
+	// and the second one is served from the cache.
+	if requests != 1 {
+		t.Fatalf("requests = %d, want %d", requests, 1)
+	}
+
+	// Modifying returned plaintexts changes neither the cache nor the
+	// plaintexts of duplicate ciphertexts.
+	ciphertexts := [][]byte{[]byte("vault:v1:three"), []byte("vault:v1:three")}
+	for i := 0; i < 2; i++ {
+		plaintexts, err := decrypter.DecryptBatch(context.Background(), ciphertexts)
+		if err != nil {
+			t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+		}
+
+		if string(plaintexts[0]) != "three" || string(plaintexts[1]) != "three" {
+			t.Fatalf("plaintexts = %q, want %q", plaintexts, []string{"three", "three"})
+		}
+
+		copy(plaintexts[0], "xxxxx")
+	}
+
+	_, err = decrypter.Decrypt(context.Background(), []byte("invalid"))
+	if err == nil {
+		t.Fatalf("error == nil, want non-nil")
+	}
+}
@@ -0,0 +1,58 @@
+package decrypt
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/valuemodifier/vault/encrypt"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+type VaultEncrypterConfig struct {
+	VaultClient *vaultapi.Client
+
+	// Key is the name of the Vault transit key. It defaults to DefaultKey.
+	Key string
+}
+
+// VaultEncrypter encrypts values with a Vault transit key so they can be
+// decrypted by VaultDecrypter configured with the same key.
+type VaultEncrypter struct {
+	vaultClient *vaultapi.Client
+
+	key string
+}
+
+var _ Encrypter = &VaultEncrypter{}
+
+func NewVaultEncrypter(config VaultEncrypterConfig) (*VaultEncrypter, error) {
+	if config.VaultClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	}
+
+	if config.Key == "" {
+		config.Key = DefaultKey
+	}
+
+	e := &VaultEncrypter{
+		vaultClient: config.VaultClient,
+
+		key: config.Key,
+	}
+
+	return e, nil
+}
+
+func (e *VaultEncrypter) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	service, err := encrypt.New(encrypt.Config{VaultClient: e.vaultClient, Key: e.key})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	ciphertext, err := service.Modify(plaintext)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return ciphertext, nil
+}
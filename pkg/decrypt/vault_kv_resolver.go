@@ -0,0 +1,180 @@
+package decrypt
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const (
+	kvReferencePrefix = "vault:"
+)
+
+// transitCiphertextRegexp matches Vault transit ciphertext, e.g.
+// "vault:v1:...", so it is not mistaken for a KV reference.
+var transitCiphertextRegexp = regexp.MustCompile(`^` + regexp.QuoteMeta(CiphertextPrefix) + `\d+:`)
+
+type VaultKVResolverConfig struct {
+	// Decrypter decrypts values which are not Vault KV references, e.g.
+	// Vault transit ciphertext.
+	Decrypter   Decrypter
+	VaultClient *vaultapi.Client
+
+	// AllowedPathPrefixes are the Vault paths references may read from,
+	// e.g. "kv/data/apps". A path is allowed when it equals one of them or
+	// is below one of them. References to other paths are rejected, so
+	// whoever can push to the config repositories can't read arbitrary
+	// Vault secrets. When it is empty all references are rejected.
+	AllowedPathPrefixes []string
+}
+
+// VaultKVResolver resolves references to Vault KV secrets in the
+// "vault:<path>#<field>" format, e.g. "vault:kv/data/app/db#password". Both
+// KV version 1 and 2 paths are supported. Other values are passed to the
+// configured Decrypter, so references and transit ciphertext can be mixed in
+// the same file. Other references are passed to the Decrypter too when it
+// implements Resolver.
+type VaultKVResolver struct {
+	decrypter   Decrypter
+	vaultClient *vaultapi.Client
+
+	allowedPathPrefixes []string
+}
+
+var _ Decrypter = &VaultKVResolver{}
+var _ BatchDecrypter = &VaultKVResolver{}
+var _ Resolver = &VaultKVResolver{}
+
+func NewVaultKVResolver(config VaultKVResolverConfig) (*VaultKVResolver, error) {
+	if config.Decrypter == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Decrypter must not be empty", config)
+	}
+	if config.VaultClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	}
+
+	var allowedPathPrefixes []string
+	for _, p := range config.AllowedPathPrefixes {
+		p = cleanVaultPath(p)
+		if p == "" {
+			return nil, microerror.Maskf(invalidConfigError, "%T.AllowedPathPrefixes must not contain empty paths", config)
+		}
+		allowedPathPrefixes = append(allowedPathPrefixes, p)
+	}
+
+	r := &VaultKVResolver{
+		decrypter:   config.Decrypter,
+		vaultClient: config.VaultClient,
+
+		allowedPathPrefixes: allowedPathPrefixes,
+	}
+
+	return r, nil
+}
+
+func (r *VaultKVResolver) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	if !IsVaultKVReference(string(ciphertext)) {
+		plaintext, err := r.decrypter.Decrypt(ctx, ciphertext)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return plaintext, nil
+	}
+
+	value, err := r.Resolve(ctx, string(ciphertext))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return []byte(value), nil
+}
+
+// DecryptBatch resolves the references one by one and passes the other
+// values to the Decrypter as a single batch.
+func (r *VaultKVResolver) DecryptBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error) {
+	plaintexts, err := splitBatch(ctx, ciphertexts, IsVaultKVReference, r.Decrypt, r.decrypter)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return plaintexts, nil
+}
+
+// Resolve returns the value of the Vault KV secret field.
+func (r *VaultKVResolver) Resolve(ctx context.Context, reference string) (string, error) {
+	if !IsVaultKVReference(reference) {
+		value, err := resolveNext(ctx, r.decrypter, reference)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return value, nil
+	}
+
+	p, field, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(reference), kvReferencePrefix), "#")
+	if p == "" || field == "" {
+		return "", microerror.Maskf(invalidReferenceError, "reference %#q must be in \"vault:<path>#<field>\" format", reference)
+	}
+
+	p = cleanVaultPath(p)
+	if !r.isAllowed(p) {
+		return "", microerror.Maskf(forbiddenReferenceError, "Vault path %#q is not below any of the allowed path prefixes", p)
+	}
+
+	start := time.Now()
+	secret, err := r.vaultClient.Logical().ReadWithContext(ctx, p)
+	requestHistogram.WithLabelValues(operationKVRead).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	if secret == nil || secret.Data == nil {
+		return "", microerror.Maskf(notFoundError, "Vault KV secret %#q not found", p)
+	}
+
+	data := secret.Data
+	// KV version 2 secrets are nested under "data" next to "metadata".
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	value, ok := data[field]
+	if !ok || value == nil {
+		return "", microerror.Maskf(notFoundError, "field %#q not found in Vault KV secret %#q", field, p)
+	}
+
+	return fmt.Sprintf("%v", value), nil
+}
+
+// isAllowed returns true when the cleaned path equals or is below one of the
+// allowed path prefixes.
+func (r *VaultKVResolver) isAllowed(p string) bool {
+	for _, prefix := range r.allowedPathPrefixes {
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cleanVaultPath resolves "." and ".." elements and strips leading and
+// trailing slashes, so paths can be compared to the allowed prefixes.
+func cleanVaultPath(p string) string {
+	return strings.Trim(path.Clean("/"+strings.TrimSpace(p)), "/")
+}
+
+// IsVaultKVReference returns true when the value is a Vault KV reference in
+// the "vault:<path>#<field>" format.
+func IsVaultKVReference(value string) bool {
+	value = strings.TrimSpace(value)
+	return strings.HasPrefix(value, kvReferencePrefix) && !transitCiphertextRegexp.MatchString(value) && strings.Contains(value, "#")
+}
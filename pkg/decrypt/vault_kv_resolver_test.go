@@ -0,0 +1,135 @@
+package decrypt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestVaultKVResolver(t *testing.T) {
+	secrets := map[string]string{
+		"/v1/kv/data/app/db": `{"data": {"data": {"password": "kv2-password", "port": 5432}, "metadata": {"version": 3}}}`,
+		"/v1/secret/app/db":  `{"data": {"password": "kv1-password"}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	c := vaultapi.DefaultConfig()
+	c.Address = server.URL
+	vaultClient, err := vaultapi.NewClient(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	resolver, err := NewVaultKVResolver(VaultKVResolverConfig{
+		Decrypter:   &testDecrypter{},
+		VaultClient: vaultClient,
+
+		AllowedPathPrefixes: []string{"kv/data/app", "/secret/app/"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	testCases := []struct {
+		name                 string
+		value                string
+		expectedResult       string
+		expectedErrorMessage string
+	}{
+		{
+			name:           "case 0: resolve KV version 2 reference",
+			value:          "vault:kv/data/app/db#password",
+			expectedResult: "kv2-password",
+		},
+		{
+			name:           "case 1: resolve KV version 1 reference",
+			value:          "vault:secret/app/db#password",
+			expectedResult: "kv1-password",
+		},
+		{
+			name:           "case 2: resolve non-string field",
+			value:          "vault:kv/data/app/db#port",
+			expectedResult: "5432",
+		},
+		{
+			name:           "case 3: decrypt transit ciphertext",
+			value:          "vault:v1:abc#def",
+			expectedResult: "decrypted",
+		},
+		{
+			name:                 "case 4: missing field",
+			value:                "vault:kv/data/app/db#user",
+			expectedErrorMessage: "field `user` not found in Vault KV secret `kv/data/app/db`",
+		},
+		{
+			name:                 "case 5: missing secret",
+			value:                "vault:kv/data/app/missing#password",
+			expectedErrorMessage: "Vault KV secret `kv/data/app/missing` not found",
+		},
+		{
+			name:                 "case 6: path outside of allowed prefixes",
+			value:                "vault:kv/data/other/db#password",
+			expectedErrorMessage: "Vault path `kv/data/other/db` is not below any of the allowed path prefixes",
+		},
+		{
+			name:                 "case 7: path escaping allowed prefix",
+			value:                "vault:kv/data/app/../other/db#password",
+			expectedErrorMessage: "Vault path `kv/data/other/db` is not below any of the allowed path prefixes",
+		},
+		{
+			name:                 "case 8: path sharing allowed prefix characters",
+			value:                "vault:kv/data/application/db#password",
+			expectedErrorMessage: "Vault path `kv/data/application/db` is not below any of the allowed path prefixes",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := resolver.Decrypt(context.Background(), []byte(tc.value))
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if string(result) != tc.expectedResult {
+				t.Fatalf("result = %q, want %q", result, tc.expectedResult)
+			}
+		})
+	}
+
+	_, err = resolver.Resolve(context.Background(), "kv/data/app/db")
+	if !IsInvalidReference(err) {
+		t.Fatalf("expected invalid reference error but got %v", err)
+	}
+
+	_, err = resolver.Resolve(context.Background(), "vault:kv/data/other/db#password")
+	if !IsForbiddenReference(err) {
+		t.Fatalf("expected forbidden reference error but got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package decrypt
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+type VaultRewrapperConfig struct {
+	VaultClient *vaultapi.Client
+
+	// Key is the name of the Vault transit key. It defaults to DefaultKey.
+	Key string
+}
+
+// VaultRewrapper rewraps ciphertext with the latest version of the Vault
+// transit key VaultDecrypter uses. The plaintext is never revealed to the
+// caller.
+type VaultRewrapper struct {
+	vaultClient *vaultapi.Client
+
+	key           string
+	latestVersion int
+}
+
+func NewVaultRewrapper(config VaultRewrapperConfig) (*VaultRewrapper, error) {
+	if config.VaultClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	}
+
+	if config.Key == "" {
+		config.Key = DefaultKey
+	}
+
+	r := &VaultRewrapper{
+		vaultClient: config.VaultClient,
+
+		key: config.Key,
+	}
+
+	return r, nil
+}
+
+// Rewrap returns the ciphertext rewrapped with the latest key version.
+// Ciphertext already encrypted with the latest key version is returned as is
+// to avoid needless changes.
+func (r *VaultRewrapper) Rewrap(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	if r.latestVersion == 0 {
+		secret, err := r.vaultClient.Logical().ReadWithContext(ctx, path.Join("transit", "keys", r.key))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if secret == nil {
+			return nil, microerror.Maskf(executionFailedError, "Vault transit key %#q not found", r.key)
+		}
+
+		v, err := strconv.Atoi(fmt.Sprintf("%v", secret.Data["latest_version"]))
+		if err != nil {
+			return nil, microerror.Maskf(executionFailedError, "Vault transit key %#q latest version must be a number", r.key)
+		}
+
+		r.latestVersion = v
+	}
+
+	if strings.HasPrefix(string(ciphertext), fmt.Sprintf("%s%d:", CiphertextPrefix, r.latestVersion)) {
+		return ciphertext, nil
+	}
+
+	secret, err := r.vaultClient.Logical().WriteWithContext(ctx, path.Join("transit", "rewrap", r.key), map[string]interface{}{
+		"ciphertext": string(ciphertext),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if secret == nil || secret.Data["ciphertext"] == nil {
+		return nil, microerror.Maskf(executionFailedError, "Vault rewrap response does not contain ciphertext")
+	}
+
+	return []byte(fmt.Sprintf("%v", secret.Data["ciphertext"])), nil
+}
@@ -27,13 +27,46 @@ func NewYAMLTraverser(config YAMLTraverserConfig) (*YAMLTraverser, error) {
 	return t, nil
 }
 
+// Traverse decrypts all the values of the YAML data. The values are
+// collected first, so decrypters implementing BatchDecrypter can decrypt
+// them all at once.
 func (t *YAMLTraverser) Traverse(ctx context.Context, yamlData []byte) ([]byte, error) {
+	collector := &collectDecrypter{
+		seen: map[string]bool{},
+	}
+	_, err := traverse(ctx, collector, yamlData)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	plaintexts, err := decryptBatch(ctx, t.decrypter, collector.ciphertexts)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	lookup := &lookupDecrypter{
+		decrypter:  t.decrypter,
+		plaintexts: map[string][]byte{},
+	}
+	for i, c := range collector.ciphertexts {
+		lookup.plaintexts[string(c)] = plaintexts[i]
+	}
+
+	decrypted, err := traverse(ctx, lookup, yamlData)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return decrypted, nil
+}
+
+func traverse(ctx context.Context, d Decrypter, yamlData []byte) ([]byte, error) {
 	var err error
 	var modifier *valuemodifier.Service
 	{
 		c := valuemodifier.Config{
 			ValueModifiers: []valuemodifier.ValueModifier{
-				newValueModifier(ctx, t.decrypter),
+				newValueModifier(ctx, d),
 			},
 		}
 
@@ -43,10 +76,45 @@ func (t *YAMLTraverser) Traverse(ctx context.Context, yamlData []byte) ([]byte,
 		}
 	}
 
-	decrypted, err := modifier.Traverse(yamlData)
+	modified, err := modifier.Traverse(yamlData)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	return decrypted, nil
+	return modified, nil
+}
+
+// collectDecrypter collects unique values and returns them unchanged.
+type collectDecrypter struct {
+	ciphertexts [][]byte
+	seen        map[string]bool
+}
+
+func (d *collectDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	if !d.seen[string(ciphertext)] {
+		d.seen[string(ciphertext)] = true
+		d.ciphertexts = append(d.ciphertexts, ciphertext)
+	}
+
+	return ciphertext, nil
+}
+
+// lookupDecrypter returns values decrypted upfront and falls back to the
+// decrypter for values it doesn't know.
+type lookupDecrypter struct {
+	decrypter  Decrypter
+	plaintexts map[string][]byte
+}
+
+func (d *lookupDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	if p, ok := d.plaintexts[string(ciphertext)]; ok {
+		return p, nil
+	}
+
+	plaintext, err := d.decrypter.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return plaintext, nil
 }
@@ -1,6 +1,8 @@
 package generator
 
 import (
+	"os"
+
 	"github.com/giantswarm/microerror"
 
 	"github.com/giantswarm/config-controller/pkg/github"
@@ -19,11 +21,32 @@ var notFoundError = &microerror.Error{
 	Kind: "notFoundError",
 }
 
-// IsNotFound asserts notFoundError.
+// IsNotFound asserts notFoundError. Filesystems reading local directories
+// return os errors, so those are matched as well.
 func IsNotFound(err error) bool {
 	if github.IsNotFound(err) {
 		return true
 	}
+	if os.IsNotExist(microerror.Cause(err)) {
+		return true
+	}
 
 	return microerror.Cause(err) == notFoundError
 }
+
+// executionFailedError should never be matched against and therefore there is
+// no matcher implement. For further information see:
+//
+//	https://github.com/giantswarm/fmt/blob/master/go/errors.md#matching-errors
+var executionFailedError = &microerror.Error{
+	Kind: "executionFailedError",
+}
+
+var plaintextSecretError = &microerror.Error{
+	Kind: "plaintextSecretError",
+}
+
+// IsPlaintextSecret asserts plaintextSecretError.
+func IsPlaintextSecret(err error) bool {
+	return microerror.Cause(err) == plaintextSecretError
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 	"text/template"
 
 	"github.com/Masterminds/sprig/v3"
@@ -14,6 +15,8 @@ import (
 	pathmodifier "github.com/giantswarm/valuemodifier/path"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/config-controller/pkg/decrypt"
 )
 
 /*
@@ -30,6 +33,7 @@ import (
 					...
 				azure-operator/
 					configmap-values.yaml.template
+					metadata.yaml
 					secret-values.yaml.template
 		installations/
 			ghost/
@@ -40,6 +44,7 @@ import (
 				apps/
 					azure-operator/
 						configmap-values.yaml.patch
+						metadata.yaml.patch
 						secret-values.yaml.patch
 */
 
@@ -48,17 +53,32 @@ const installationsPath = "installations/"
 type Config struct {
 	Fs               Filesystem
 	DecryptTraverser DecryptTraverser
+	// PlaintextSecretReporter receives ConfigMap values looking like
+	// secrets in PlaintextSecretCheckWarn mode. It must be set in that
+	// mode.
+	PlaintextSecretReporter PlaintextSecretReporter
+	// SecretResolver backs the vaultKV, k8sSecret and pluginSecret template
+	// functions available in secret-values templates. The functions fail
+	// when it is not set.
+	SecretResolver SecretResolver
 
 	Installation string
-	Verbose      bool
+	// PlaintextSecretCheck is one of PlaintextSecretCheckOff,
+	// PlaintextSecretCheckWarn or PlaintextSecretCheckFail. It defaults to
+	// PlaintextSecretCheckOff.
+	PlaintextSecretCheck string
+	Verbose              bool
 }
 
 type Generator struct {
-	fs               Filesystem
-	decryptTraverser DecryptTraverser
-
-	installation string
-	verbose      bool
+	fs                      Filesystem
+	decryptTraverser        DecryptTraverser
+	plaintextSecretReporter PlaintextSecretReporter
+	secretResolver          SecretResolver
+
+	installation         string
+	plaintextSecretCheck string
+	verbose              bool
 }
 
 func New(config Config) (*Generator, error) {
@@ -72,12 +92,26 @@ func New(config Config) (*Generator, error) {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Installation must not be empty", config)
 	}
 
+	switch config.PlaintextSecretCheck {
+	case "":
+		config.PlaintextSecretCheck = PlaintextSecretCheckOff
+	case PlaintextSecretCheckOff, PlaintextSecretCheckWarn, PlaintextSecretCheckFail:
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "%T.PlaintextSecretCheck must be one of %q, %q or %q, got %q", config, PlaintextSecretCheckOff, PlaintextSecretCheckWarn, PlaintextSecretCheckFail, config.PlaintextSecretCheck)
+	}
+	if config.PlaintextSecretCheck == PlaintextSecretCheckWarn && config.PlaintextSecretReporter == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.PlaintextSecretReporter must not be empty when %T.PlaintextSecretCheck is %q", config, config, PlaintextSecretCheckWarn)
+	}
+
 	g := Generator{
-		fs:               config.Fs,
-		decryptTraverser: config.DecryptTraverser,
+		fs:                      config.Fs,
+		decryptTraverser:        config.DecryptTraverser,
+		plaintextSecretReporter: config.PlaintextSecretReporter,
+		secretResolver:          config.SecretResolver,
 
-		installation: config.Installation,
-		verbose:      config.Verbose,
+		installation:         config.Installation,
+		plaintextSecretCheck: config.PlaintextSecretCheck,
+		verbose:              config.Verbose,
 	}
 
 	return &g, nil
@@ -94,12 +128,20 @@ func New(config Config) (*Generator, error) {
 //     of 3.) app overrides
 //  5. Get installation-specific secret template data and decrypt it
 //  6. Get global secret template for the app (if available) and render it with
-//     installation secret template data (result of 5.)
+//     installation secret template data (result of 5.) and the vaultKV,
+//     k8sSecret and pluginSecret template functions
 //  7. Get installation-specific secret template patch (if available) and
-//     decrypt it
+//     decrypt it with the App encryption key (if configured in the App
+//     metadata)
 //  8. Patch secret template (result of 6.) with decrypted patch values (result
 //     of 7.)
+//  9. Check configmap values (result of 4.) for values equal to secret values
+//     decrypted in 5. and 7. or resolved in 6., or looking like credentials
 func (g Generator) generateRawConfig(ctx context.Context, app string) (configmap string, secret string, err error) {
+	// secretValues collects the decrypted and resolved secret values for the
+	// plaintext secret check.
+	secretValues := map[string]bool{}
+
 	// 1.
 	configmapContext, err := g.getWithPatchIfExists(
 		ctx,
@@ -163,7 +205,7 @@ func (g Generator) generateRawConfig(ctx context.Context, app string) (configmap
 	}
 	g.logMessage(ctx, "loaded installation secret")
 
-	decryptedBytes, err := g.decryptTraverser.Traverse(ctx, []byte(secretContext))
+	decryptedBytes, err := g.decrypt(ctx, []byte(secretContext), secretValues)
 	if err != nil {
 		return "", "", microerror.Mask(err)
 	}
@@ -177,6 +219,12 @@ func (g Generator) generateRawConfig(ctx context.Context, app string) (configmap
 		"",
 	)
 	if IsNotFound(err) {
+		// 9.
+		err = g.checkPlaintextSecrets(ctx, app, configmap, secretValues)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
+
 		g.logMessage(ctx, "secret-values template not found, generated configmap")
 		return configmap, "", nil
 	} else if err != nil {
@@ -184,7 +232,7 @@ func (g Generator) generateRawConfig(ctx context.Context, app string) (configmap
 	}
 	g.logMessage(ctx, "loaded secret-values template")
 
-	secret, err = g.renderTemplate(ctx, secretTemplate, secretContext)
+	secret, err = g.renderTemplateWithFuncs(ctx, secretTemplate, secretContext, g.secretFuncs(ctx, secretValues))
 	if err != nil {
 		return "", "", microerror.Mask(err)
 	}
@@ -201,7 +249,19 @@ func (g Generator) generateRawConfig(ctx context.Context, app string) (configmap
 			return "", "", microerror.Mask(err)
 		} else {
 			g.logMessage(ctx, "loaded secret-values patch")
-			decryptedBytes, err := g.decryptTraverser.Traverse(ctx, []byte(patch))
+
+			md, err := g.getAppMetadata(ctx, app)
+			if err != nil {
+				return "", "", microerror.Mask(err)
+			}
+
+			decryptCtx := ctx
+			if md.EncryptionKey != "" {
+				decryptCtx = decrypt.NewContextWithKey(ctx, md.EncryptionKey)
+				g.logMessage(ctx, "using %#q encryption key for secret-values patch", md.EncryptionKey)
+			}
+
+			decryptedBytes, err := g.decrypt(decryptCtx, []byte(patch), secretValues)
 			if err != nil {
 				return "", "", microerror.Mask(err)
 			}
@@ -211,19 +271,24 @@ func (g Generator) generateRawConfig(ctx context.Context, app string) (configmap
 	}
 
 	// 8.
-	if secretPatch == "" {
-		g.logMessage(ctx, "generated configmap and secret")
-		return configmap, secret, nil
+	if secretPatch != "" {
+		secret, err = applyPatch(
+			ctx,
+			[]byte(secret),
+			[]byte(secretPatch),
+		)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
+		g.logMessage(ctx, "patched secret-values")
 	}
-	secret, err = applyPatch(
-		ctx,
-		[]byte(secret),
-		[]byte(secretPatch),
-	)
+
+	// 9.
+	err = g.checkPlaintextSecrets(ctx, app, configmap, secretValues)
 	if err != nil {
 		return "", "", microerror.Mask(err)
 	}
-	g.logMessage(ctx, "patched secret-values, generated configmap and secret")
+	g.logMessage(ctx, "generated configmap and secret")
 
 	return configmap, secret, nil
 }
@@ -261,6 +326,54 @@ func (g Generator) GenerateConfig(ctx context.Context, app string, meta metav1.O
 	return configmap, secret, nil
 }
 
+// appMetadata holds optional App settings. It is read from
+// default/apps/<app>/metadata.yaml patched with
+// installations/<installation>/apps/<app>/metadata.yaml.patch.
+type appMetadata struct {
+	// EncryptionKey is the name of the key used to decrypt the App
+	// secret-values patch, so App secrets can be encrypted with a
+	// dedicated key. When empty the decrypter default key is used.
+	EncryptionKey string `json:"encryptionKey"`
+}
+
+// AppEncryptionKey returns the name of the key the App secret-values patch
+// is encrypted with according to the App metadata. It is empty when the
+// decrypter default key is used.
+func AppEncryptionKey(ctx context.Context, fs Filesystem, installation, app string) (string, error) {
+	g := Generator{
+		fs:           fs,
+		installation: installation,
+	}
+
+	md, err := g.getAppMetadata(ctx, app)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return md.EncryptionKey, nil
+}
+
+func (g Generator) getAppMetadata(ctx context.Context, app string) (appMetadata, error) {
+	data, err := g.getWithPatchIfExists(
+		ctx,
+		"default/apps/"+app+"/metadata.yaml",
+		installationsPath+g.installation+"/apps/"+app+"/metadata.yaml.patch",
+	)
+	if IsNotFound(err) {
+		return appMetadata{}, nil
+	} else if err != nil {
+		return appMetadata{}, microerror.Mask(err)
+	}
+
+	var md appMetadata
+	err = yaml.Unmarshal([]byte(data), &md)
+	if err != nil {
+		return appMetadata{}, microerror.Mask(err)
+	}
+
+	return md, nil
+}
+
 // getWithPatchIfExists provides contents of filepath overwritten by patch at
 // patchFilepath. File at patchFilepath may be non-existent, resulting in pure
 // file at filepath being returned.
@@ -269,7 +382,7 @@ func (g Generator) getWithPatchIfExists(ctx context.Context, filepath, patchFile
 
 	var base []byte
 	{
-		base, err = g.fs.ReadFile(filepath)
+		base, err = g.readFile(ctx, filepath)
 		if err != nil {
 			return "", microerror.Mask(err)
 		}
@@ -282,7 +395,7 @@ func (g Generator) getWithPatchIfExists(ctx context.Context, filepath, patchFile
 
 	var patch []byte
 	{
-		patch, err = g.fs.ReadFile(patchFilepath)
+		patch, err = g.readFile(ctx, patchFilepath)
 		if err != nil {
 			if IsNotFound(err) {
 				return string(base), nil
@@ -299,7 +412,7 @@ func (g Generator) getWithPatchIfExists(ctx context.Context, filepath, patchFile
 }
 
 func (g Generator) getRenderedTemplate(ctx context.Context, filepath, templateData string) (string, error) {
-	templateBytes, err := g.fs.ReadFile(filepath)
+	templateBytes, err := g.readFile(ctx, filepath)
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
@@ -377,6 +490,10 @@ func applyPatch(ctx context.Context, base, patch []byte) (string, error) {
 }
 
 func (g Generator) renderTemplate(ctx context.Context, templateText string, templateData string) (string, error) {
+	return g.renderTemplateWithFuncs(ctx, templateText, templateData, nil)
+}
+
+func (g Generator) renderTemplateWithFuncs(ctx context.Context, templateText string, templateData string, extraFuncs template.FuncMap) (string, error) {
 	c := map[string]interface{}{}
 	err := yaml.Unmarshal([]byte(templateData), &c)
 	if err != nil {
@@ -386,6 +503,9 @@ func (g Generator) renderTemplate(ctx context.Context, templateText string, temp
 	funcMap := sprig.TxtFuncMap()
 	funcMap["include"] = g.include
 	funcMap["includeSelf"] = g.includeSelf
+	for k, v := range extraFuncs {
+		funcMap[k] = v
+	}
 
 	t, err := template.New("main").Funcs(funcMap).Option("missingkey=error").Parse(templateText)
 	if err != nil {
@@ -402,6 +522,45 @@ func (g Generator) renderTemplate(ctx context.Context, templateText string, temp
 	return out.String(), nil
 }
 
+// secretFuncs returns template functions available only in secret
+// templates, so secrets can't leak into the ConfigMap.
+func (g Generator) secretFuncs(ctx context.Context, secretValues map[string]bool) template.FuncMap {
+	return template.FuncMap{
+		// vaultKV returns the value of a Vault KV secret field, e.g.
+		// {{ vaultKV "kv/data/app/db#password" }}.
+		"vaultKV": func(reference string) (string, error) {
+			return g.resolveSecret(ctx, secretValues, "vaultKV", "vault:"+strings.TrimPrefix(reference, "vault:"))
+		},
+		// k8sSecret returns the value of an in-cluster Secret key, e.g.
+		// {{ k8sSecret "giantswarm/app-tls#tls.crt" }}.
+		"k8sSecret": func(reference string) (string, error) {
+			return g.resolveSecret(ctx, secretValues, "k8sSecret", "k8s:"+strings.TrimPrefix(reference, "k8s:"))
+		},
+		// pluginSecret returns the value of a reference resolved by an exec
+		// plugin. The reference is passed as is, e.g.
+		// {{ pluginSecret "op://vault/item/password" }}.
+		"pluginSecret": func(reference string) (string, error) {
+			return g.resolveSecret(ctx, secretValues, "pluginSecret", reference)
+		},
+	}
+}
+
+// resolveSecret resolves the reference and records the value in
+// secretValues for the plaintext secret check.
+func (g Generator) resolveSecret(ctx context.Context, secretValues map[string]bool, funcName, reference string) (string, error) {
+	if g.secretResolver == nil {
+		return "", microerror.Maskf(executionFailedError, "%s template function is not configured", funcName)
+	}
+
+	value, err := g.secretResolver.Resolve(ctx, reference)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	addSecretValue(secretValues, value)
+
+	return value, nil
+}
+
 func (g Generator) include(templateName string, templateData interface{}) (string, error) {
 	return g.includeFromRoot("include", templateName, templateData)
 }
@@ -411,7 +570,7 @@ func (g Generator) includeSelf(templateName string, templateData interface{}) (s
 }
 
 func (g Generator) includeFromRoot(root string, templateName string, templateData interface{}) (string, error) {
-	contents, err := g.fs.ReadFile(path.Join(root, templateName+".yaml.template"))
+	contents, err := g.readFile(context.Background(), path.Join(root, templateName+".yaml.template"))
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
@@ -430,6 +589,25 @@ func (g Generator) includeFromRoot(root string, templateName string, templateDat
 	return out.String(), nil
 }
 
+// readFile reads the file and logs the repository it comes from when the
+// filesystem implements SourceFilesystem.
+func (g Generator) readFile(ctx context.Context, filepath string) ([]byte, error) {
+	contents, err := g.fs.ReadFile(filepath)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if sfs, ok := g.fs.(SourceFilesystem); ok && g.verbose {
+		source, err := sfs.Source(filepath)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		g.logMessage(ctx, "read %#q from %#q", filepath, source)
+	}
+
+	return contents, nil
+}
+
 func (g Generator) logMessage(ctx context.Context, format string, params ...interface{}) {
 	if g.verbose {
 		fmt.Fprintf(os.Stderr, "generator: "+format+"\n", params...)
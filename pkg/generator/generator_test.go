@@ -6,11 +6,14 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/ghodss/yaml"
 	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/config-controller/pkg/decrypt"
 )
 
 func TestGenerator_generateRawConfig(t *testing.T) {
@@ -22,7 +25,10 @@ func TestGenerator_generateRawConfig(t *testing.T) {
 		app          string
 		installation string
 
-		decryptTraverser DecryptTraverser
+		decryptTraverser     DecryptTraverser
+		secretResolver       SecretResolver
+		plaintextSecretCheck string
+		expectedReported     []PlaintextSecret
 	}{
 		{
 			name:     "case 0 - basic config with config.yaml.patch",
@@ -123,6 +129,70 @@ func TestGenerator_generateRawConfig(t *testing.T) {
 			installation:     "puma",
 			decryptTraverser: &noopTraverser{},
 		},
+
+		{
+			name:     "case 11 - decrypt secret patch with app encryption key",
+			caseFile: "testdata/case11.yaml",
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &mapStringTraverser{},
+		},
+
+		{
+			name:     "case 12 - resolve Vault KV, Secret and plugin references in secret template",
+			caseFile: "testdata/case12.yaml",
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &noopTraverser{},
+			secretResolver:   &mapResolver{"vault:kv/data/operator#password": "kv-password", "k8s:giantswarm/operator#token": "k8s-token", "op://vault/operator/api-key": "plugin-api-key"},
+		},
+
+		{
+			name:                 "case 13 - vaultKV is not available in configmap template",
+			caseFile:             "testdata/case13.yaml",
+			expectedErrorMessage: `function "vaultKV" not defined`,
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &noopTraverser{},
+			secretResolver:   &mapResolver{"vault:kv/data/operator#password": "kv-password", "k8s:giantswarm/operator#token": "k8s-token"},
+		},
+
+		{
+			name:                 "case 14 - fail on configmap value equal to secret value",
+			caseFile:             "testdata/case14.yaml",
+			expectedErrorMessage: "database.password: equals a secret value",
+
+			app:                  "operator",
+			installation:         "puma",
+			decryptTraverser:     &mapStringTraverser{},
+			plaintextSecretCheck: PlaintextSecretCheckFail,
+		},
+
+		{
+			name:     "case 15 - report configmap value matching credential pattern",
+			caseFile: "testdata/case15.yaml",
+
+			app:                  "operator",
+			installation:         "puma",
+			decryptTraverser:     &noopTraverser{},
+			plaintextSecretCheck: PlaintextSecretCheckWarn,
+			expectedReported: []PlaintextSecret{
+				{Path: "aws.accessKeyID", Reason: "matches AWS access key ID pattern"},
+			},
+		},
+
+		{
+			name:     "case 16 - ignore configmap value equal to plaintext secret value",
+			caseFile: "testdata/case16.yaml",
+
+			app:                  "operator",
+			installation:         "puma",
+			decryptTraverser:     &noopTraverser{},
+			plaintextSecretCheck: PlaintextSecretCheckFail,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -135,11 +205,15 @@ func TestGenerator_generateRawConfig(t *testing.T) {
 
 			fs := newMockFilesystem(tmpDir, tc.caseFile)
 
+			reporter := &sliceReporter{}
 			config := Config{
-				Fs:               fs,
-				DecryptTraverser: tc.decryptTraverser,
+				Fs:                      fs,
+				DecryptTraverser:        tc.decryptTraverser,
+				PlaintextSecretReporter: reporter,
+				SecretResolver:          tc.secretResolver,
 
-				Installation: tc.installation,
+				Installation:         tc.installation,
+				PlaintextSecretCheck: tc.plaintextSecretCheck,
 			}
 			g, err := New(config)
 			if err != nil {
@@ -167,6 +241,9 @@ func TestGenerator_generateRawConfig(t *testing.T) {
 			if secret != fs.ExpectedSecret {
 				t.Fatalf("secret not expected, got: %s", secret)
 			}
+			if !reflect.DeepEqual(reporter.secrets, tc.expectedReported) {
+				t.Fatalf("reported = %v, want %v", reporter.secrets, tc.expectedReported)
+			}
 		})
 	}
 }
@@ -254,6 +331,17 @@ func (t noopTraverser) Traverse(ctx context.Context, encrypted []byte) ([]byte,
 	return encrypted, nil
 }
 
+type mapResolver map[string]string
+
+func (r mapResolver) Resolve(ctx context.Context, reference string) (string, error) {
+	v, ok := r[reference]
+	if !ok {
+		return "", microerror.Maskf(notFoundError, "%q not found", reference)
+	}
+
+	return v, nil
+}
+
 type mapStringTraverser struct{}
 
 func (t mapStringTraverser) Traverse(ctx context.Context, encrypted []byte) ([]byte, error) {
@@ -263,9 +351,14 @@ func (t mapStringTraverser) Traverse(ctx context.Context, encrypted []byte) ([]b
 		return []byte{}, microerror.Mask(err)
 	}
 
+	prefix := "decrypted-"
+	if key, ok := decrypt.KeyFromContext(ctx); ok {
+		prefix += key + "-"
+	}
+
 	decryptedMap := map[string]string{}
 	for k, v := range encryptedMap {
-		decryptedMap[k] = "decrypted-" + v
+		decryptedMap[k] = prefix + v
 	}
 	decrypted, err := yaml.Marshal(decryptedMap)
 	if err != nil {
@@ -273,3 +366,11 @@ func (t mapStringTraverser) Traverse(ctx context.Context, encrypted []byte) ([]b
 	}
 	return decrypted, nil
 }
+
+type sliceReporter struct {
+	secrets []PlaintextSecret
+}
+
+func (r *sliceReporter) ReportPlaintextSecrets(ctx context.Context, app string, secrets []PlaintextSecret) {
+	r.secrets = append(r.secrets, secrets...)
+}
@@ -0,0 +1,246 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/giantswarm/microerror"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// PlaintextSecretCheckOff disables the plaintext secret check.
+	PlaintextSecretCheckOff = "off"
+	// PlaintextSecretCheckWarn reports ConfigMap values looking like
+	// secrets to the PlaintextSecretReporter.
+	PlaintextSecretCheckWarn = "warn"
+	// PlaintextSecretCheckFail fails generation when ConfigMap values look
+	// like secrets.
+	PlaintextSecretCheckFail = "fail"
+
+	// minSecretLength is the minimum length of secret values compared with
+	// ConfigMap values. Shorter values, e.g. "true" or ports, are too likely
+	// to be equal by accident.
+	minSecretLength = 8
+
+	// minHighEntropyLength and minHighEntropy are the thresholds for
+	// random looking strings, e.g. base64 encoded keys. Shannon entropy of
+	// hex strings like digests never exceeds 4 bits per character.
+	minHighEntropyLength = 24
+	minHighEntropy       = 4.2
+)
+
+var credentialPatterns = []struct {
+	name   string
+	regexp *regexp.Regexp
+}{
+	{name: "private key", regexp: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{name: "AWS access key ID", regexp: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "GitHub token", regexp: regexp.MustCompile(`\bgh[oprsu]_[A-Za-z0-9]{36,}\b`)},
+	{name: "Slack token", regexp: regexp.MustCompile(`\bxox[abopsr]-[A-Za-z0-9-]{10,}`)},
+	{name: "JSON web token", regexp: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]+`)},
+	{name: "URL with password", regexp: regexp.MustCompile(`://[^/\s:@]+:[^/\s@]+@`)},
+}
+
+// PlaintextSecret is a ConfigMap value which looks like a secret. The value
+// itself is never included, so findings can be logged safely.
+type PlaintextSecret struct {
+	// Path of the value in configmap-values.yaml, e.g. "aws.secretKey".
+	Path string
+	// Reason why the value looks like a secret.
+	Reason string
+}
+
+func (s PlaintextSecret) String() string {
+	return fmt.Sprintf("%s: %s", s.Path, s.Reason)
+}
+
+// checkPlaintextSecrets looks for ConfigMap values equal to any of the
+// secret values or matching credential patterns. Depending on the configured
+// mode the findings are reported or fail the generation.
+func (g Generator) checkPlaintextSecrets(ctx context.Context, app string, configmap string, secretValues map[string]bool) error {
+	if g.plaintextSecretCheck == PlaintextSecretCheckOff {
+		return nil
+	}
+
+	leaves, err := yamlLeaves([]byte(configmap))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var findings []PlaintextSecret
+	for _, l := range leaves {
+		reason := plaintextSecretReason(l.value, secretValues)
+		if reason != "" {
+			findings = append(findings, PlaintextSecret{Path: l.path, Reason: reason})
+		}
+	}
+
+	if len(findings) == 0 {
+		return nil
+	}
+
+	if g.plaintextSecretCheck == PlaintextSecretCheckFail {
+		var messages []string
+		for _, f := range findings {
+			messages = append(messages, f.String())
+		}
+
+		return microerror.Maskf(plaintextSecretError, "configmap-values for app %#q contain values looking like secrets: %s", app, strings.Join(messages, ", "))
+	}
+
+	g.plaintextSecretReporter.ReportPlaintextSecrets(ctx, app, findings)
+
+	return nil
+}
+
+// decrypt decrypts the YAML data and records the decrypted values in
+// secretValues for the plaintext secret check. Values the decrypter returns
+// unchanged are plaintext in the repository and are not recorded.
+func (g Generator) decrypt(ctx context.Context, data []byte, secretValues map[string]bool) ([]byte, error) {
+	decrypted, err := g.decryptTraverser.Traverse(ctx, data)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if g.plaintextSecretCheck == PlaintextSecretCheckOff {
+		return decrypted, nil
+	}
+
+	encryptedLeaves, err := yamlLeaves(data)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	decryptedLeaves, err := yamlLeaves(decrypted)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	encrypted := map[string]string{}
+	for _, l := range encryptedLeaves {
+		encrypted[l.path] = l.value
+	}
+	for _, l := range decryptedLeaves {
+		if v, ok := encrypted[l.path]; ok && v == l.value {
+			continue
+		}
+		addSecretValue(secretValues, l.value)
+	}
+
+	return decrypted, nil
+}
+
+// addSecretValue records the value for the plaintext secret check unless it
+// is too short to be compared.
+func addSecretValue(secretValues map[string]bool, value string) {
+	if len(value) >= minSecretLength {
+		secretValues[value] = true
+	}
+}
+
+func plaintextSecretReason(value string, secretValues map[string]bool) string {
+	if secretValues[value] {
+		return "equals a secret value"
+	}
+
+	for _, p := range credentialPatterns {
+		if p.regexp.MatchString(value) {
+			return fmt.Sprintf("matches %s pattern", p.name)
+		}
+	}
+
+	if isHighEntropy(value) {
+		return "high-entropy string"
+	}
+
+	return ""
+}
+
+// isHighEntropy returns true for random looking strings without whitespace
+// mixing upper case letters, lower case letters and digits.
+func isHighEntropy(value string) bool {
+	if len(value) < minHighEntropyLength || strings.Contains(value, "://") {
+		return false
+	}
+
+	var upper, lower, digit bool
+	counts := map[rune]int{}
+	for _, r := range value {
+		switch {
+		case unicode.IsSpace(r):
+			return false
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+		counts[r]++
+	}
+	if !upper || !lower || !digit {
+		return false
+	}
+
+	var entropy float64
+	n := float64(len([]rune(value)))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy >= minHighEntropy
+}
+
+type yamlLeaf struct {
+	path  string
+	value string
+}
+
+// yamlLeaves returns all the scalar values of the YAML document with their
+// paths.
+func yamlLeaves(yamlData []byte) ([]yamlLeaf, error) {
+	if len(bytes.TrimSpace(yamlData)) == 0 {
+		return nil, nil
+	}
+
+	var node yaml.Node
+	err := yaml.Unmarshal(yamlData, &node)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var leaves []yamlLeaf
+	collectLeaves(&node, "", &leaves)
+
+	return leaves, nil
+}
+
+func collectLeaves(n *yaml.Node, path string, leaves *[]yamlLeaf) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			collectLeaves(c, path, leaves)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			collectLeaves(c, fmt.Sprintf("%s[%d]", path, i), leaves)
+		}
+	case yaml.MappingNode:
+		// Content holds keys and values interleaved.
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			p := n.Content[i].Value
+			if path != "" {
+				p = path + "." + p
+			}
+			collectLeaves(n.Content[i+1], p, leaves)
+		}
+	case yaml.ScalarNode:
+		*leaves = append(*leaves, yamlLeaf{path: path, value: n.Value})
+	}
+}
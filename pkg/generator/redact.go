@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/giantswarm/microerror"
+	"gopkg.in/yaml.v3"
+
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+)
+
+// Redact replaces every scalar value in the YAML document with a placeholder
+// of the Redactor. Keys, structure and types are kept: numbers and booleans
+// are replaced with numbers and booleans derived from the placeholder, and
+// nulls are kept. Equal values get equal placeholders, so they can be
+// spotted without revealing them.
+func Redact(yamlData []byte, redactor *decrypt.Redactor) ([]byte, error) {
+	if len(bytes.TrimSpace(yamlData)) == 0 {
+		return yamlData, nil
+	}
+
+	var node yaml.Node
+	err := yaml.Unmarshal(yamlData, &node)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	redactNode(&node, redactor)
+
+	out := bytes.NewBuffer([]byte{})
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+
+	err = enc.Encode(&node)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = enc.Close()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return out.Bytes(), nil
+}
+
+func redactNode(n *yaml.Node, redactor *decrypt.Redactor) {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			redactNode(c, redactor)
+		}
+	case yaml.MappingNode:
+		// Content holds keys and values interleaved. Only values are
+		// redacted.
+		for i := 1; i < len(n.Content); i += 2 {
+			redactNode(n.Content[i], redactor)
+		}
+	case yaml.ScalarNode:
+		value := []byte(n.Value)
+		switch n.ShortTag() {
+		case "!!null":
+			return
+		case "!!int":
+			n.Value = strconv.FormatUint(uint64(redactor.RedactNumber(value)), 10)
+		case "!!float":
+			n.Value = strconv.FormatUint(uint64(redactor.RedactNumber(value)), 10) + ".0"
+		case "!!bool":
+			n.Value = strconv.FormatBool(redactor.RedactNumber(value)%2 == 1)
+		default:
+			n.Value = redactor.Redact(value)
+			n.Tag = "!!str"
+		}
+		n.Style = 0
+	}
+}
@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+)
+
+func TestRedact(t *testing.T) {
+	redactor, err := decrypt.NewRedactor(decrypt.RedactorConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	redact := func(v string) string {
+		return redactor.Redact([]byte(v))
+	}
+	number := func(v string) string {
+		return strconv.FormatUint(uint64(redactor.RedactNumber([]byte(v))), 10)
+	}
+	boolean := func(v string) string {
+		return strconv.FormatBool(redactor.RedactNumber([]byte(v))%2 == 1)
+	}
+
+	testCases := []struct {
+		name           string
+		input          string
+		expectedResult string
+	}{
+		{
+			name:           "case 0: empty input",
+			input:          "",
+			expectedResult: "",
+		},
+		{
+			name: "case 1: redact all nested scalars keeping their types",
+			input: `a:
+  b: secret
+  c: 1234
+  d: true
+  e: ""
+  f: 1.5
+  g: null
+  h: "1234"
+list:
+- secret
+- other
+cert: |
+  line 1
+  line 2
+`,
+			expectedResult: `a:
+  b: ` + redact("secret") + `
+  c: ` + number("1234") + `
+  d: ` + boolean("true") + `
+  e: ` + redact("") + `
+  f: ` + number("1.5") + `.0
+  g: null
+  h: ` + redact("1234") + `
+list:
+  - ` + redact("secret") + `
+  - ` + redact("other") + `
+cert: ` + redact("line 1\nline 2\n") + `
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := Redact([]byte(tc.input), redactor)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if !cmp.Equal(tc.expectedResult, string(result)) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedResult, string(result)))
+			}
+		})
+	}
+}
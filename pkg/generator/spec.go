@@ -10,6 +10,26 @@ type Filesystem interface {
 	ReadDir(string) ([]os.FileInfo, error)
 }
 
+// SourceFilesystem is implemented by filesystems assembled from several
+// repositories. Source returns the name of the repository the file is read
+// from.
+type SourceFilesystem interface {
+	Source(path string) (string, error)
+}
+
 type DecryptTraverser interface {
 	Traverse(context.Context, []byte) ([]byte, error)
 }
+
+// SecretResolver resolves references to secrets stored outside of the
+// configuration repository.
+type SecretResolver interface {
+	Resolve(ctx context.Context, reference string) (string, error)
+}
+
+// PlaintextSecretReporter is notified about ConfigMap values looking like
+// secrets when the plaintext secret check is in PlaintextSecretCheckWarn
+// mode.
+type PlaintextSecretReporter interface {
+	ReportPlaintextSecrets(ctx context.Context, app string, secrets []PlaintextSecret)
+}
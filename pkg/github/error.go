@@ -27,3 +27,21 @@ func IsNotFound(err error) bool {
 
 	return microerror.Cause(err) == notFoundError
 }
+
+// IsSignatureVerificationFailed asserts that a commit is not signed by a
+// trusted key.
+func IsSignatureVerificationFailed(err error) bool {
+	return gitrepo.IsSignatureVerificationFailed(err)
+}
+
+// IsNotSupported asserts that the operation is not supported by the
+// configuration, e.g. ChangedPaths without the on-disk cache.
+func IsNotSupported(err error) bool {
+	return gitrepo.IsNotSupported(err)
+}
+
+// IsProtectedOverride asserts that the config repository overrides a
+// protected file of the shared configs repository.
+func IsProtectedOverride(err error) bool {
+	return gitrepo.IsProtectedOverride(err)
+}
@@ -2,19 +2,63 @@ package github
 
 import (
 	"context"
+	"time"
 
 	"github.com/giantswarm/config-controller/internal/shared"
 
 	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
 
+	"github.com/giantswarm/config-controller/internal/githubapp"
 	"github.com/giantswarm/config-controller/internal/ssh"
+	appauth "github.com/giantswarm/config-controller/pkg/github/internal/githubapp"
 	"github.com/giantswarm/config-controller/pkg/github/internal/gitrepo"
 )
 
 type Config struct {
-	SharedConfigRepository shared.ConfigRepository
-	SSHCredential          ssh.Credential
-	Token                  string
+	// Logger is optional. It logs commits rejected by the signature
+	// verification.
+	Logger micrologger.Logger
+
+	// App is the GitHub App installation minting short-lived tokens for
+	// HTTPS requests. It takes precedence over Token.
+	App githubapp.Credential
+	// CacheDir is the directory of the on-disk repository cache. When it
+	// is empty repositories are cloned in memory.
+	CacheDir string
+	// Host is the git host serving repositories without configured URL,
+	// e.g. "gitlab.com". It defaults to "github.com".
+	Host string
+	// Installation is the name of the installation the configuration is
+	// assembled for. Its installations/<name>/shared-configs.yaml file in
+	// the config repository pins the refs of the shared configs
+	// repositories.
+	Installation string
+	// KnownHosts is the content of a known_hosts file SSH host keys are
+	// strictly verified against. When it is empty $SSH_KNOWN_HOSTS or
+	// ~/.ssh/known_hosts is used.
+	KnownHosts string
+	// MaxStaleness is how long the last known good configuration of a
+	// reference is served by LastKnownGood when the repositories are
+	// unreachable. Zero disables the fallback.
+	MaxStaleness time.Duration
+	// RepositoryURL is the URL of the config repository. It may be a
+	// file:// URL or a local path too. When it is empty the URL is built
+	// from Host, the owner and the repository name.
+	RepositoryURL string
+	// RepositoryUsername and RepositoryToken authenticate HTTPS requests
+	// to the config repository, e.g. on another host than Host.
+	RepositoryUsername       string
+	RepositoryToken          string
+	SharedConfigRepositories []shared.ConfigRepository
+	SSHCredential            ssh.Credential
+	// Token authenticates HTTPS requests to Host.
+	Token string
+	// TrustedGPGKeys is an armored GPG key ring and TrustedSSHKeys holds
+	// SSH public keys in authorized_keys format. When any of them is set,
+	// only commits signed by one of the keys are used.
+	TrustedGPGKeys string
+	TrustedSSHKeys string
 }
 
 type GitHub struct {
@@ -22,17 +66,46 @@ type GitHub struct {
 }
 
 func New(config Config) (*GitHub, error) {
-	if config.Token == "" && config.SSHCredential.IsEmpty() {
-		return nil, microerror.Maskf(invalidConfigError, "%T.Token or %T.ConfigRepoSSHCredential must not be empty", config, config)
+	if config.Token == "" && config.App.IsEmpty() && config.SSHCredential.IsEmpty() && config.RepositoryURL == "" && config.RepositoryToken == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Token, %T.App, %T.ConfigRepoSSHCredential, %T.RepositoryURL or %T.RepositoryToken must not be empty", config, config, config, config, config)
 	}
 
 	var err error
+
+	var tokenSource gitrepo.TokenSource
+	if !config.App.IsEmpty() {
+		c := appauth.TokenSourceConfig{
+			APIURL:         config.App.APIURL,
+			AppID:          config.App.AppID,
+			InstallationID: config.App.InstallationID,
+			PrivateKey:     config.App.PrivateKey,
+		}
+
+		tokenSource, err = appauth.NewTokenSource(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var repo *gitrepo.Repo
 	{
 		c := gitrepo.Config{
-			SharedConfigRepository: config.SharedConfigRepository,
-			GitHubSSHCredential:    config.SSHCredential,
-			GitHubToken:            config.Token,
+			Logger: config.Logger,
+
+			CacheDir:                 config.CacheDir,
+			Host:                     config.Host,
+			Installation:             config.Installation,
+			KnownHosts:               config.KnownHosts,
+			MaxStaleness:             config.MaxStaleness,
+			RepositoryURL:            config.RepositoryURL,
+			RepositoryUsername:       config.RepositoryUsername,
+			RepositoryToken:          config.RepositoryToken,
+			SharedConfigRepositories: config.SharedConfigRepositories,
+			GitHubSSHCredential:      config.SSHCredential,
+			GitHubToken:              config.Token,
+			TokenSource:              tokenSource,
+			TrustedGPGKeys:           config.TrustedGPGKeys,
+			TrustedSSHKeys:           config.TrustedSSHKeys,
 		}
 
 		repo, err = gitrepo.New(c)
@@ -48,8 +121,64 @@ func New(config Config) (*GitHub, error) {
 	return g, nil
 }
 
-func (g *GitHub) AssembleConfigRepository(ctx context.Context, owner, name, branch string) (Store, error) {
-	store, err := g.repo.AssembleConfigRepository(ctx, owner, name, branch)
+// ResolveRevision resolves the reference of the config repository and, for
+// split config setups, the reference of the shared configs repository to
+// commit SHAs.
+func (g *GitHub) ResolveRevision(ctx context.Context, owner, name, branch string) (Revision, error) {
+	revision, err := g.repo.ResolveRevision(ctx, owner, name, branch)
+	if err != nil {
+		return Revision{}, microerror.Mask(err)
+	}
+
+	return revision, nil
+}
+
+// PinnedSharedConfigRepositories returns the shared configs repositories of split
+// config setups with the refs pinned at the current commit of the config
+// repository.
+func (g *GitHub) PinnedSharedConfigRepositories(ctx context.Context, owner, name, branch string) ([]shared.ConfigRepository, error) {
+	repositories, err := g.repo.PinnedSharedConfigRepositories(ctx, owner, name, branch)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return repositories, nil
+}
+
+// ChangedPaths returns the paths changed in the config repository and the
+// shared configs repositories between the revisions of the reference. It
+// requires the on-disk cache and returns an error matched by IsNotSupported
+// without it. An error matched by IsNotFound is returned when the old
+// revision can't be compared, e.g. after a force push.
+func (g *GitHub) ChangedPaths(ctx context.Context, owner, name, branch string, from, to Revision) (Changes, error) {
+	changes, err := g.repo.ChangedPaths(ctx, owner, name, branch, from, to)
+	if err != nil {
+		return Changes{}, microerror.Mask(err)
+	}
+
+	return changes, nil
+}
+
+// LastKnownGood returns the last configuration assembled for the reference
+// when resolving or assembling it failed with cause because the repositories
+// are unreachable. The Stale method of the returned Store returns true.
+// Otherwise the cause is returned.
+func (g *GitHub) LastKnownGood(ctx context.Context, owner, name, branch string, cause error) (Store, error) {
+	store, err := g.repo.LastKnownGood(ctx, owner, name, branch, cause)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return store, nil
+}
+
+// AssembleConfigRepository assembles the configuration of the revision.
+// Check the Revision of the returned Store for the commits actually used.
+// With signature verification enabled, these are the last verified commits
+// when the ones of the revision are rejected. Without verified commits an
+// error matched by IsSignatureVerificationFailed is returned.
+func (g *GitHub) AssembleConfigRepository(ctx context.Context, owner, name, branch string, revision Revision) (Store, error) {
+	store, err := g.repo.AssembleConfigRepository(ctx, owner, name, branch, revision)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
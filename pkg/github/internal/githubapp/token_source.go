@@ -0,0 +1,189 @@
+package githubapp
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// DefaultAPIURL is the URL of the public GitHub API.
+	DefaultAPIURL = "https://api.github.com"
+
+	// defaultAPIHost is the host of DefaultAPIURL. Repositories are served
+	// by defaultGitHost instead.
+	defaultAPIHost = "api.github.com"
+	defaultGitHost = "github.com"
+
+	// jwtExpiration is the lifetime of the app JWT. GitHub accepts at
+	// most 10 minutes.
+	jwtExpiration = 9 * time.Minute
+	// jwtClockDrift backdates the JWT to allow for clock drift.
+	jwtClockDrift = time.Minute
+	// refreshBefore is the time before the expiry of an installation
+	// token when a new one is minted. Installation tokens are valid for an
+	// hour, so clones never start with a token about to expire.
+	refreshBefore = 10 * time.Minute
+)
+
+type TokenSourceConfig struct {
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// APIURL is the URL of the GitHub API, e.g.
+	// "https://github.example.com/api/v3" for GitHub Enterprise Server.
+	// It defaults to DefaultAPIURL.
+	APIURL         string
+	AppID          int64
+	InstallationID int64
+	// PrivateKey is the PEM encoded private key of the app.
+	PrivateKey string
+}
+
+// TokenSource mints short-lived GitHub App installation tokens. Tokens are
+// cached and minted again shortly before they expire.
+type TokenSource struct {
+	httpClient *http.Client
+
+	apiURL         string
+	host           string
+	appID          int64
+	installationID int64
+	privateKey     *rsa.PrivateKey
+
+	mutex     sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func NewTokenSource(config TokenSourceConfig) (*TokenSource, error) {
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+
+	if config.APIURL == "" {
+		config.APIURL = DefaultAPIURL
+	}
+	if config.AppID == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.AppID must not be empty", config)
+	}
+	if config.InstallationID == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.InstallationID must not be empty", config)
+	}
+	if config.PrivateKey == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.PrivateKey must not be empty", config)
+	}
+
+	apiURL, err := url.Parse(config.APIURL)
+	if err != nil || apiURL.Hostname() == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.APIURL %#q is invalid", config, config.APIURL)
+	}
+
+	host := apiURL.Hostname()
+	if host == defaultAPIHost {
+		host = defaultGitHost
+	}
+
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(config.PrivateKey))
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.PrivateKey is invalid: %s", config, err)
+	}
+
+	s := &TokenSource{
+		httpClient: config.HTTPClient,
+
+		apiURL:         strings.TrimSuffix(config.APIURL, "/"),
+		host:           host,
+		appID:          config.AppID,
+		installationID: config.InstallationID,
+		privateKey:     privateKey,
+	}
+
+	return s, nil
+}
+
+// Host returns the git host the installation tokens are valid for. It is the
+// host of the GitHub API URL, or github.com for the public GitHub API.
+func (s *TokenSource) Host() string {
+	return s.host
+}
+
+// Token returns a valid installation token.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.token != "" && time.Until(s.expiresAt) > refreshBefore {
+		return s.token, nil
+	}
+
+	token, expiresAt, err := s.mint(ctx)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	s.token = token
+	s.expiresAt = expiresAt
+
+	return s.token, nil
+}
+
+// mint creates an installation token authenticating as the app. See
+// https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-an-installation-access-token-for-a-github-app.
+func (s *TokenSource) mint(ctx context.Context) (string, time.Time, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now.Add(-jwtClockDrift)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(jwtExpiration)),
+		Issuer:    strconv.FormatInt(s.appID, 10),
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(s.privateKey)
+	if err != nil {
+		return "", time.Time{}, microerror.Mask(err)
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", s.apiURL, s.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return "", time.Time{}, microerror.Mask(err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+signed)
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, microerror.Mask(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusCreated {
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&body)
+
+		return "", time.Time{}, microerror.Maskf(executionFailedError, "minting token for installation %d of app %d failed with status %d: %s", s.installationID, s.appID, res.StatusCode, body.Message)
+	}
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return "", time.Time{}, microerror.Mask(err)
+	}
+
+	return body.Token, body.ExpiresAt, nil
+}
@@ -0,0 +1,192 @@
+package githubapp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTokenSource_Token(t *testing.T) {
+	ctx := context.Background()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+
+	testCases := []struct {
+		name           string
+		installationID int64
+		// lifetime of the minted tokens.
+		lifetime             time.Duration
+		expectedTokens       []string
+		expectedErrorMessage string
+	}{
+		{
+			name:           "case 0: token is cached",
+			installationID: 42,
+			lifetime:       time.Hour,
+			expectedTokens: []string{"token-1", "token-1"},
+		},
+		{
+			name:           "case 1: token about to expire is refreshed",
+			installationID: 42,
+			lifetime:       5 * time.Minute,
+			expectedTokens: []string{"token-1", "token-2"},
+		},
+		{
+			name:                 "case 2: unknown installation",
+			installationID:       7,
+			lifetime:             time.Hour,
+			expectedTokens:       []string{""},
+			expectedErrorMessage: "failed with status 404",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var minted int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+					return
+				}
+
+				// Verify the app JWT.
+				signed := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+				var claims jwt.RegisteredClaims
+				_, err := jwt.ParseWithClaims(signed, &claims, func(*jwt.Token) (interface{}, error) {
+					return &key.PublicKey, nil
+				}, jwt.WithValidMethods([]string{"RS256"}))
+				if err != nil || claims.Issuer != "1" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				n := atomic.AddInt32(&minted, 1)
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"token":      fmt.Sprintf("token-%d", n),
+					"expires_at": time.Now().Add(tc.lifetime).UTC(),
+				})
+			}))
+			defer server.Close()
+
+			c := TokenSourceConfig{
+				APIURL:         server.URL,
+				AppID:          1,
+				InstallationID: tc.installationID,
+				PrivateKey:     privateKey,
+			}
+
+			s, err := NewTokenSource(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			for _, expected := range tc.expectedTokens {
+				token, err := s.Token(ctx)
+				if tc.expectedErrorMessage == "" {
+					if err != nil {
+						t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+					}
+				} else {
+					switch {
+					case err == nil:
+						t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+					case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+						t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+					default:
+						return
+					}
+				}
+
+				if token != expected {
+					t.Fatalf("token = %q, want %q", token, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenSource_Host(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+
+	testCases := []struct {
+		name                 string
+		apiURL               string
+		expectedHost         string
+		expectedErrorMessage string
+	}{
+		{
+			name:         "case 0: public GitHub API",
+			expectedHost: "github.com",
+		},
+		{
+			name:         "case 1: GitHub Enterprise Server",
+			apiURL:       "https://github.example.com/api/v3",
+			expectedHost: "github.example.com",
+		},
+		{
+			name:                 "case 2: invalid API URL",
+			apiURL:               "api/v3",
+			expectedErrorMessage: "APIURL `api/v3` is invalid",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := TokenSourceConfig{
+				APIURL:         tc.apiURL,
+				AppID:          1,
+				InstallationID: 42,
+				PrivateKey:     privateKey,
+			}
+
+			s, err := NewTokenSource(c)
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if s.Host() != tc.expectedHost {
+				t.Fatalf("host = %q, want %q", s.Host(), tc.expectedHost)
+			}
+		})
+	}
+}
@@ -0,0 +1,486 @@
+package gitrepo
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	remoteName = "origin"
+
+	// worktreeExpiration is the time after which worktrees of commits which
+	// weren't checked out anymore are removed unless they are pinned.
+	worktreeExpiration = time.Hour
+
+	// maxSymlinks is the maximum number of symlinks followed to resolve a
+	// file, so symlink loops fail.
+	maxSymlinks = 40
+
+	// checkoutTimeout bounds checkouts shared by concurrent callers. They
+	// don't use the context of any of the callers, so one cancelled caller
+	// doesn't fail the others.
+	checkoutTimeout = 5 * time.Minute
+
+	// rebuildInterval is the age after which a bare repository is
+	// recreated on its next fetch. Objects which are not reachable from any
+	// reference anymore, e.g. after force pushes, are dropped with it so the
+	// repository doesn't grow without bound. go-git can't prune or repack
+	// repositories containing symlinks.
+	rebuildInterval = 24 * time.Hour
+)
+
+// cache keeps a bare repository per remote URL on disk and fetches only the
+// objects missing for the requested reference. Files of every resolved
+// commit are materialised once in a worktree directory which is shared by
+// all readers of that commit.
+//
+// The layout of the cache directory is:
+//
+//	<dir>/<url hash>/repo.git
+//	<dir>/<url hash>/worktrees/<commit SHA>/
+type cache struct {
+	dir string
+
+	// group makes concurrent checkouts of the same reference share a
+	// single fetch.
+	group singleflight.Group
+	// locks holds a *sync.Mutex per repository serialising operations on
+	// its bare repository.
+	locks sync.Map
+	// created holds the time.Time each bare repository was first used or
+	// last recreated.
+	created sync.Map
+
+	// pinned counts the references to worktrees which must not be pruned,
+	// e.g. of Stores kept as the last verified or last known good ones.
+	pinned      map[string]int
+	pinnedMutex sync.Mutex
+}
+
+type checkout struct {
+	fs billy.Filesystem
+	// path is the directory of the worktree.
+	path   string
+	sha    string
+	commit *object.Commit
+}
+
+func newCache(dir string) *cache {
+	return &cache{
+		dir: dir,
+
+		pinned: map[string]int{},
+	}
+}
+
+// Pin keeps the worktree directories from being pruned until they are
+// unpinned as often as they were pinned.
+func (c *cache) Pin(paths []string) {
+	c.pinnedMutex.Lock()
+	defer c.pinnedMutex.Unlock()
+
+	for _, p := range paths {
+		c.pinned[p]++
+	}
+}
+
+// Unpin releases worktree directories pinned with Pin.
+func (c *cache) Unpin(paths []string) {
+	c.pinnedMutex.Lock()
+	defer c.pinnedMutex.Unlock()
+
+	for _, p := range paths {
+		c.pinned[p]--
+		if c.pinned[p] <= 0 {
+			delete(c.pinned, p)
+		}
+	}
+}
+
+func (c *cache) isPinned(path string) bool {
+	c.pinnedMutex.Lock()
+	defer c.pinnedMutex.Unlock()
+
+	return c.pinned[path] > 0
+}
+
+// Checkout returns the files of the commit. The commit is fetched from the
+// remote reference, e.g. a branch or tag name, unless it is in the cache
+// already. When sha is empty the commit the reference currently points to
+// is checked out.
+//
+// Concurrent checkouts of the same commit share a single fetch which runs
+// with its own timeout. Callers stop waiting for it when their context is
+// done.
+func (c *cache) Checkout(ctx context.Context, url string, auth transport.AuthMethod, ref, sha string) (checkout, error) {
+	key := repositoryKey(url)
+
+	ch := c.group.DoChan(key+"@"+ref+"@"+sha, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), checkoutTimeout)
+		defer cancel()
+
+		return c.checkout(ctx, key, url, auth, ref, sha)
+	})
+
+	select {
+	case <-ctx.Done():
+		return checkout{}, microerror.Mask(ctx.Err())
+	case res := <-ch:
+		if res.Err != nil {
+			return checkout{}, microerror.Mask(res.Err)
+		}
+
+		return res.Val.(checkout), nil
+	}
+}
+
+func (c *cache) checkout(ctx context.Context, key, url string, auth transport.AuthMethod, ref, sha string) (checkout, error) {
+	lock, _ := c.locks.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	repo, err := c.openRepository(key, url)
+	if err != nil {
+		return checkout{}, microerror.Mask(err)
+	}
+
+	hash := plumbing.NewHash(sha)
+	if sha == "" || !hasObject(repo, hash) {
+		repo, err = c.rebuildRepository(key, url, repo)
+		if err != nil {
+			return checkout{}, microerror.Mask(err)
+		}
+
+		head, err := c.fetch(ctx, repo, auth, ref)
+		if err != nil {
+			return checkout{}, microerror.Mask(err)
+		}
+
+		// The commit is fetched along with the reference as long as it is
+		// still an ancestor of it.
+		if sha == "" {
+			hash = head
+		} else if !hasObject(repo, hash) {
+			return checkout{}, microerror.Maskf(notFoundError, "commit %#q of reference %#q not found", sha, ref)
+		}
+	}
+
+	commit, err := resolveCommit(repo, hash)
+	if err != nil {
+		return checkout{}, microerror.Mask(err)
+	}
+
+	fs, path, err := c.worktree(key, commit)
+	if err != nil {
+		return checkout{}, microerror.Mask(err)
+	}
+
+	return checkout{fs: fs, path: path, sha: commit.Hash.String(), commit: commit}, nil
+}
+
+// fetch fetches the reference and returns the commit it points to. Only
+// the objects missing in the cache are transferred.
+func (c *cache) fetch(ctx context.Context, repo *git.Repository, auth transport.AuthMethod, ref string) (plumbing.Hash, error) {
+	remote, err := repo.Remote(remoteName)
+	if err != nil {
+		return plumbing.ZeroHash, microerror.Mask(err)
+	}
+
+	remoteRef, commit, err := listReference(ctx, remote, auth, ref)
+	if err != nil {
+		return plumbing.ZeroHash, microerror.Mask(err)
+	}
+
+	if hasObject(repo, commit) {
+		return commit, nil
+	}
+
+	err = remote.FetchContext(ctx, &git.FetchOptions{
+		Auth:     auth,
+		RefSpecs: []config.RefSpec{localRefSpec(remoteRef.Name())},
+		Tags:     git.NoTags,
+		Force:    true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return plumbing.ZeroHash, microerror.Mask(err)
+	}
+
+	return commit, nil
+}
+
+// rebuildRepository recreates the bare repository once it is older than
+// rebuildInterval, so the following fetch transfers only the objects
+// reachable from the reference. Worktrees are kept. The caller must hold the
+// lock of the repository.
+func (c *cache) rebuildRepository(key, url string, repo *git.Repository) (*git.Repository, error) {
+	created, loaded := c.created.LoadOrStore(key, time.Now())
+	if !loaded || time.Since(created.(time.Time)) < rebuildInterval {
+		return repo, nil
+	}
+
+	err := os.RemoveAll(filepath.Join(c.dir, key, "repo.git"))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	c.created.Store(key, time.Now())
+
+	repo, err = c.openRepository(key, url)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return repo, nil
+}
+
+func (c *cache) openRepository(key, url string) (*git.Repository, error) {
+	path := filepath.Join(c.dir, key, "repo.git")
+
+	repo, err := git.PlainOpen(path)
+	if err == git.ErrRepositoryNotExists {
+		repo, err = git.PlainInit(path, true)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		_, err = repo.CreateRemote(&config.RemoteConfig{
+			Name: remoteName,
+			URLs: []string{url},
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return repo, nil
+}
+
+// worktree returns the files of the commit and the directory holding them.
+// They are written to the cache directory when the commit is checked out
+// for the first time.
+func (c *cache) worktree(key string, commit *object.Commit) (billy.Filesystem, string, error) {
+	worktrees := filepath.Join(c.dir, key, "worktrees")
+	path := filepath.Join(worktrees, commit.Hash.String())
+
+	_, err := os.Stat(path)
+	if err == nil {
+		// The modification time tracks the last use for pruning.
+		now := time.Now()
+		err = os.Chtimes(path, now, now)
+		if err != nil {
+			return nil, "", microerror.Mask(err)
+		}
+
+		return osfs.New(path, osfs.WithBoundOS()), path, nil
+	} else if !os.IsNotExist(err) {
+		return nil, "", microerror.Mask(err)
+	}
+
+	err = os.MkdirAll(worktrees, 0750)
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	// Files are written to a temporary directory which is renamed when
+	// complete, so readers never see partial worktrees.
+	tmp, err := os.MkdirTemp(worktrees, ".tmp-")
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	err = writeTree(commit, osfs.New(tmp, osfs.WithBoundOS()))
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	err = c.pruneWorktrees(worktrees, worktreeExpiration)
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	return osfs.New(path, osfs.WithBoundOS()), path, nil
+}
+
+// writeTree writes the files of the commit to the filesystem. Symlinks are
+// replaced with copies of the files they point to, so the files are the same
+// on every filesystem and can't point outside of the tree. Symlinks pointing
+// outside of the tree, to directories or to missing files fail with an error
+// matched by IsInvalidSymlink.
+func writeTree(commit *object.Commit, fs billy.Filesystem) error {
+	tree, err := commit.Tree()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		target, err := resolveSymlink(tree, f)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = fs.MkdirAll(path.Dir(f.Name), 0750)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		r, err := target.Reader()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		defer func() { _ = r.Close() }()
+
+		w, err := fs.OpenFile(f.Name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		_, err = io.Copy(w, r)
+		if err != nil {
+			_ = w.Close()
+			return microerror.Mask(err)
+		}
+
+		return w.Close()
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// resolveSymlink returns the file the symlink points to within the tree.
+// Other files are returned as they are.
+func resolveSymlink(tree *object.Tree, f *object.File) (*object.File, error) {
+	name := f.Name
+	for i := 0; f.Mode == filemode.Symlink; i++ {
+		if i == maxSymlinks {
+			return nil, microerror.Maskf(invalidSymlinkError, "symlink %#q has too many levels of symlinks", name)
+		}
+
+		target, err := f.Contents()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		if path.IsAbs(target) {
+			return nil, microerror.Maskf(invalidSymlinkError, "symlink %#q points to absolute path %#q", name, target)
+		}
+
+		p := path.Join(path.Dir(f.Name), target)
+		if p == ".." || strings.HasPrefix(p, "../") {
+			return nil, microerror.Maskf(invalidSymlinkError, "symlink %#q points outside of the repository", name)
+		}
+
+		entry, err := tree.FindEntry(p)
+		if err != nil {
+			return nil, microerror.Maskf(invalidSymlinkError, "symlink %#q points to missing file %#q", name, p)
+		}
+		if !entry.Mode.IsFile() {
+			return nil, microerror.Maskf(invalidSymlinkError, "symlink %#q points to directory %#q", name, p)
+		}
+
+		f, err = tree.TreeEntryFile(entry)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		f.Name = p
+	}
+
+	return f, nil
+}
+
+// pruneWorktrees removes worktrees not used for longer than expiration
+// unless they are pinned.
+func (c *cache) pruneWorktrees(dir string, expiration time.Duration) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		path := filepath.Join(dir, e.Name())
+		if time.Since(info.ModTime()) > expiration && !c.isPinned(path) {
+			err = os.RemoveAll(path)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// localRefSpec maps the remote reference to the same name in the bare
+// repository.
+func localRefSpec(name plumbing.ReferenceName) config.RefSpec {
+	return config.RefSpec(fmt.Sprintf("+%s:%s", name, name))
+}
+
+// resolveCommit returns the commit the hash points to. Annotated tags are
+// peeled.
+func resolveCommit(repo *git.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	tag, err := repo.TagObject(hash)
+	if err == nil {
+		commit, err := tag.Commit()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return commit, nil
+	} else if err != plumbing.ErrObjectNotFound {
+		return nil, microerror.Mask(err)
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return commit, nil
+}
+
+func hasObject(repo *git.Repository, hash plumbing.Hash) bool {
+	_, err := repo.Storer.EncodedObject(plumbing.AnyObject, hash)
+	return err == nil
+}
+
+func repositoryKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return fmt.Sprintf("%x", sum[:8])
+}
@@ -0,0 +1,349 @@
+package gitrepo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+
+	origin := t.TempDir()
+	repo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	first := commitFile(t, repo, origin, "default/config.yaml", "answer: 42\n")
+
+	c := newCache(t.TempDir())
+	url := "file://" + origin
+
+	// Concurrent checkouts of the same reference share a single fetch.
+	var wg sync.WaitGroup
+	checkouts := make([]checkout, 5)
+	errs := make([]error, 5)
+	for i := range checkouts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			checkouts[i], errs[i] = c.Checkout(ctx, url, nil, "master", "")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range checkouts {
+		if errs[i] != nil {
+			t.Fatalf("unexpected error: %s", microerror.Pretty(errs[i], true))
+		}
+		if checkouts[i].sha != first {
+			t.Fatalf("sha = %q, want %q", checkouts[i].sha, first)
+		}
+	}
+
+	assertFile(t, checkouts[0], "default/config.yaml", "answer: 42\n")
+
+	second := commitFile(t, repo, origin, "default/config.yaml", "answer: 43\n")
+
+	co, err := c.Checkout(ctx, url, nil, "master", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	if co.sha != second {
+		t.Fatalf("sha = %q, want %q", co.sha, second)
+	}
+
+	assertFile(t, co, "default/config.yaml", "answer: 43\n")
+	// Worktrees of older commits stay readable.
+	assertFile(t, checkouts[0], "default/config.yaml", "answer: 42\n")
+
+	_, err = c.Checkout(ctx, url, nil, "missing", "")
+	if !IsNotFound(err) {
+		t.Fatalf("expected not found error but got %v", err)
+	}
+
+	// Commits in the cache are checked out without contacting the remote.
+	err = os.RemoveAll(origin)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	co, err = c.Checkout(ctx, url, nil, "master", first)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	if co.sha != first {
+		t.Fatalf("sha = %q, want %q", co.sha, first)
+	}
+
+	assertFile(t, co, "default/config.yaml", "answer: 42\n")
+}
+
+func Test_lsRemote(t *testing.T) {
+	ctx := context.Background()
+
+	origin := t.TempDir()
+	repo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	sha := commitFile(t, repo, origin, "default/config.yaml", "answer: 42\n")
+
+	_, err = repo.CreateTag("v1.0.0", plumbing.NewHash(sha), &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "v1.0.0",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	testCases := []struct {
+		name                 string
+		ref                  string
+		expectedErrorMessage string
+	}{
+		{
+			name: "case 0: branch name",
+			ref:  "master",
+		},
+		{
+			name: "case 1: full reference name",
+			ref:  "refs/heads/master",
+		},
+		{
+			name: "case 2: annotated tag is peeled",
+			ref:  "v1.0.0",
+		},
+		{
+			name:                 "case 3: missing reference",
+			ref:                  "missing",
+			expectedErrorMessage: "reference `missing` not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hash, err := lsRemote(ctx, "file://"+origin, nil, tc.ref)
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if hash.String() != sha {
+				t.Fatalf("hash = %q, want %q", hash, sha)
+			}
+		})
+	}
+}
+
+func TestRepo_pinnedWorktrees(t *testing.T) {
+	ctx := context.Background()
+
+	origin := t.TempDir()
+	repo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, repo, origin, "default/config.yaml", "answer: 42\n")
+
+	cacheDir := t.TempDir()
+	r, err := New(Config{
+		CacheDir:      cacheDir,
+		RepositoryURL: origin,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	assemble := func(content string) *Store {
+		t.Helper()
+
+		// Worktrees not checked out anymore expire.
+		expireWorktrees(t, cacheDir)
+		commitFile(t, repo, origin, "default/config.yaml", content)
+
+		revision, err := r.ResolveRevision(ctx, "giantswarm", "config", "master")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+		}
+
+		store, err := r.AssembleConfigRepository(ctx, "giantswarm", "config", "master", revision)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+		}
+
+		return store
+	}
+
+	first := assemble("answer: 43\n")
+	second := assemble("answer: 44\n")
+
+	// The worktree of the last known good Store was pinned while the
+	// second one was checked out.
+	content, err := first.ReadFile("default/config.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	if string(content) != "answer: 43\n" {
+		t.Fatalf("content = %q, want %q", content, "answer: 43\n")
+	}
+
+	assemble("answer: 45\n")
+
+	// The first Store was replaced, so its worktree was pruned.
+	_, err = first.ReadFile("default/config.yaml")
+	if err == nil {
+		t.Fatalf("expected error but got nil")
+	}
+
+	content, err = second.ReadFile("default/config.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	if string(content) != "answer: 44\n" {
+		t.Fatalf("content = %q, want %q", content, "answer: 44\n")
+	}
+}
+
+func TestCache_rebuild(t *testing.T) {
+	ctx := context.Background()
+
+	origin := t.TempDir()
+	repo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	first := commitFile(t, repo, origin, "default/config.yaml", "answer: 42\n")
+	second := commitFile(t, repo, origin, "default/config.yaml", "answer: 43\n")
+
+	dir := t.TempDir()
+	c := newCache(dir)
+	url := "file://" + origin
+
+	co, err := c.Checkout(ctx, url, nil, "master", second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	// Force pushing makes the second commit unreachable.
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	err = wt.Reset(&git.ResetOptions{Commit: plumbing.NewHash(first), Mode: git.HardReset})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	third := commitFile(t, repo, origin, "default/config.yaml", "answer: 44\n")
+
+	c.created.Store(repositoryKey(url), time.Now().Add(-2*rebuildInterval))
+
+	latest, err := c.Checkout(ctx, url, nil, "master", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	if latest.sha != third {
+		t.Fatalf("sha = %q, want %q", latest.sha, third)
+	}
+
+	cached, err := git.PlainOpen(filepath.Join(dir, repositoryKey(url), "repo.git"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	if hasObject(cached, plumbing.NewHash(second)) {
+		t.Fatalf("expected unreachable commit %q to be dropped", second)
+	}
+	if !hasObject(cached, plumbing.NewHash(first)) {
+		t.Fatalf("expected reachable commit %q to be kept", first)
+	}
+
+	// Worktrees survive the rebuild.
+	assertFile(t, co, "default/config.yaml", "answer: 43\n")
+	assertFile(t, latest, "default/config.yaml", "answer: 44\n")
+}
+
+// expireWorktrees backdates all worktrees of the cache directory beyond
+// worktreeExpiration.
+func expireWorktrees(t *testing.T, dir string) {
+	t.Helper()
+
+	worktrees, err := filepath.Glob(filepath.Join(dir, "*", "worktrees", "*"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	expired := time.Now().Add(-2 * worktreeExpiration)
+	for _, w := range worktrees {
+		err = os.Chtimes(w, expired, expired)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+		}
+	}
+}
+
+func commitFile(t *testing.T, repo *git.Repository, dir, name, content string) string {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0750)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	_, err = wt.Add(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	hash, err := wt.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	return hash.String()
+}
+
+func assertFile(t *testing.T, co checkout, name, expected string) {
+	t.Helper()
+
+	content, err := util.ReadFile(co.fs, name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	if string(content) != expected {
+		t.Fatalf("content = %q, want %q", content, expected)
+	}
+}
@@ -0,0 +1,154 @@
+package gitrepo
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// Changes holds the paths changed between two revisions.
+type Changes struct {
+	// Config holds the paths changed in the config repository.
+	Config []string
+	// Shared holds the paths changed in any of the shared configs
+	// repositories.
+	Shared []string
+}
+
+// ChangedPaths returns the paths changed between the revisions of the
+// reference, both resolved with ResolveRevision. It requires the on-disk
+// cache, which keeps the history of the repositories, and returns an error
+// matched by IsNotSupported without it. An error matched by IsNotFound is
+// returned when a commit of the old revision is gone, e.g. after a force
+// push, or when the shared configs repositories differ between the
+// revisions.
+func (r *Repo) ChangedPaths(ctx context.Context, owner, name, branch string, from, to Revision) (Changes, error) {
+	if r.cache == nil {
+		return Changes{}, microerror.Maskf(notSupportedError, "changed paths require the on-disk repository cache")
+	}
+
+	var changes Changes
+	{
+		url, auth, err := r.configRepository(ctx, owner, name)
+		if err != nil {
+			return Changes{}, microerror.Mask(err)
+		}
+
+		changes.Config, err = r.cache.Diff(ctx, url, auth, branch, from.Config, to.Config)
+		if err != nil {
+			return Changes{}, microerror.Mask(err)
+		}
+	}
+
+	if len(from.Shared) != len(to.Shared) {
+		return Changes{}, microerror.Maskf(notFoundError, "shared configs repositories differ between revisions")
+	}
+
+	if len(to.Shared) > 0 {
+		repositories, err := r.resolveSharedConfigRepositories(ctx, owner, name, branch, to.Config)
+		if err != nil {
+			return Changes{}, microerror.Mask(err)
+		}
+		if len(repositories) != len(to.Shared) {
+			return Changes{}, microerror.Maskf(notFoundError, "shared configs repositories differ between revisions")
+		}
+
+		seen := map[string]bool{}
+		for i, repository := range repositories {
+			if from.Shared[i] == to.Shared[i] {
+				continue
+			}
+
+			url, auth, err := r.sharedRepository(ctx, owner, repository)
+			if err != nil {
+				return Changes{}, microerror.Mask(err)
+			}
+
+			paths, err := r.cache.Diff(ctx, url, auth, repository.Ref, from.Shared[i], to.Shared[i])
+			if err != nil {
+				return Changes{}, microerror.Mask(err)
+			}
+
+			for _, p := range paths {
+				if !seen[p] {
+					seen[p] = true
+					changes.Shared = append(changes.Shared, p)
+				}
+			}
+		}
+		sort.Strings(changes.Shared)
+	}
+
+	return changes, nil
+}
+
+// Diff returns the sorted paths changed between the commits. The new commit
+// is fetched from the remote reference unless it is in the cache already.
+func (c *cache) Diff(ctx context.Context, url string, auth transport.AuthMethod, ref, from, to string) ([]string, error) {
+	if from == to {
+		return nil, nil
+	}
+
+	key := repositoryKey(url)
+
+	lock, _ := c.locks.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	repo, err := c.openRepository(key, url)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if !hasObject(repo, plumbing.NewHash(to)) {
+		_, err = c.fetch(ctx, repo, auth, ref)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var trees []*object.Tree
+	for _, sha := range []string{from, to} {
+		hash := plumbing.NewHash(sha)
+		if !hasObject(repo, hash) {
+			return nil, microerror.Maskf(notFoundError, "commit %#q of reference %#q not found", sha, ref)
+		}
+
+		commit, err := resolveCommit(repo, hash)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		trees = append(trees, tree)
+	}
+
+	diff, err := object.DiffTreeWithOptions(ctx, trees[0], trees[1], object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	seen := map[string]bool{}
+	var paths []string
+	for _, change := range diff {
+		// Renamed files change both paths.
+		for _, p := range []string{change.From.Name, change.To.Name} {
+			if p != "" && !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
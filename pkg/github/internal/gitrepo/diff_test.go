@@ -0,0 +1,96 @@
+package gitrepo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5"
+
+	"github.com/giantswarm/config-controller/internal/shared"
+)
+
+func TestRepo_ChangedPaths(t *testing.T) {
+	ctx := context.Background()
+
+	configDir := t.TempDir()
+	configRepo, err := git.PlainInit(configDir, false)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	commitFile(t, configRepo, configDir, "installations/puma/apps/foo/configmap-values.yaml.patch", "foo: 1\n")
+
+	sharedDir := t.TempDir()
+	sharedRepo, err := git.PlainInit(sharedDir, false)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	commitFile(t, sharedRepo, sharedDir, "default/apps/bar/configmap-values.yaml.template", "bar: 1\n")
+
+	c := Config{
+		CacheDir:      t.TempDir(),
+		Installation:  "puma",
+		RepositoryURL: configDir,
+		SharedConfigRepositories: []shared.ConfigRepository{
+			{
+				Name: "shared-configs",
+				Ref:  "master",
+				URL:  sharedDir,
+			},
+		},
+	}
+
+	r, err := New(c)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	from, err := r.ResolveRevision(ctx, "acme", "acme-config", "master")
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	_, err = r.AssembleConfigRepository(ctx, "acme", "acme-config", "master", from)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	commitFile(t, configRepo, configDir, "installations/puma/apps/foo/configmap-values.yaml.patch", "foo: 2\n")
+	commitFile(t, configRepo, configDir, "installations/lion/config.yaml.patch", "lion: 1\n")
+	commitFile(t, sharedRepo, sharedDir, "default/apps/baz/configmap-values.yaml.template", "baz: 1\n")
+
+	to, err := r.ResolveRevision(ctx, "acme", "acme-config", "master")
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	changes, err := r.ChangedPaths(ctx, "acme", "acme-config", "master", from, to)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	expected := Changes{
+		Config: []string{
+			"installations/lion/config.yaml.patch",
+			"installations/puma/apps/foo/configmap-values.yaml.patch",
+		},
+		Shared: []string{
+			"default/apps/baz/configmap-values.yaml.template",
+		},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("changes = %#v, want %#v", changes, expected)
+	}
+
+	// Without the on-disk cache the history is not available.
+	c.CacheDir = ""
+	r, err = New(c)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	_, err = r.ChangedPaths(ctx, "acme", "acme-config", "master", from, to)
+	if !IsNotSupported(err) {
+		t.Fatalf("error == %#v, want matching", err)
+	}
+}
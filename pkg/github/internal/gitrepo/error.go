@@ -18,3 +18,66 @@ var notFoundError = &microerror.Error{
 func IsNotFound(err error) bool {
 	return microerror.Cause(err) == notFoundError
 }
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var hostKeyVerificationFailedError = &microerror.Error{
+	Kind: "hostKeyVerificationFailedError",
+}
+
+// IsHostKeyVerificationFailed asserts hostKeyVerificationFailedError.
+func IsHostKeyVerificationFailed(err error) bool {
+	return microerror.Cause(err) == hostKeyVerificationFailedError
+}
+
+var protectedOverrideError = &microerror.Error{
+	Kind: "protectedOverrideError",
+}
+
+// IsProtectedOverride asserts protectedOverrideError.
+func IsProtectedOverride(err error) bool {
+	return microerror.Cause(err) == protectedOverrideError
+}
+
+var signatureVerificationFailedError = &microerror.Error{
+	Kind: "signatureVerificationFailedError",
+}
+
+// IsSignatureVerificationFailed asserts signatureVerificationFailedError.
+func IsSignatureVerificationFailed(err error) bool {
+	return microerror.Cause(err) == signatureVerificationFailedError
+}
+
+var notSupportedError = &microerror.Error{
+	Kind: "notSupportedError",
+}
+
+// IsNotSupported asserts notSupportedError.
+func IsNotSupported(err error) bool {
+	return microerror.Cause(err) == notSupportedError
+}
+
+var invalidSymlinkError = &microerror.Error{
+	Kind: "invalidSymlinkError",
+}
+
+// IsInvalidSymlink asserts invalidSymlinkError.
+func IsInvalidSymlink(err error) bool {
+	return microerror.Cause(err) == invalidSymlinkError
+}
+
+var referenceMovedError = &microerror.Error{
+	Kind: "referenceMovedError",
+}
+
+// IsReferenceMoved asserts referenceMovedError.
+func IsReferenceMoved(err error) bool {
+	return microerror.Cause(err) == referenceMovedError
+}
@@ -0,0 +1,78 @@
+package gitrepo
+
+import (
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/skeema/knownhosts"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+// knownHosts verifies SSH host keys of git servers strictly against the
+// configured known_hosts content. Unknown hosts and changed host keys are
+// rejected.
+type knownHosts struct {
+	db *knownhosts.HostKeyDB
+}
+
+func newKnownHosts(content string) (*knownHosts, error) {
+	// knownhosts only parses files. The content is loaded in memory, so the
+	// file is removed right away.
+	f, err := os.CreateTemp("", "known_hosts-")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	_, err = f.WriteString(content)
+	if err != nil {
+		_ = f.Close()
+		return nil, microerror.Mask(err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	db, err := knownhosts.NewDB(f.Name())
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "invalid known_hosts: %s", err)
+	}
+
+	k := &knownHosts{
+		db: db,
+	}
+
+	return k, nil
+}
+
+// configure makes the auth method verify host keys. Host key algorithms are
+// limited to the key types known for the endpoint, so the server does not
+// present a key of another type which would be rejected.
+func (k *knownHosts) configure(auth *ssh.PublicKeys, endpoint *transport.Endpoint) {
+	port := endpoint.Port
+	if port == 0 {
+		port = 22
+	}
+
+	auth.HostKeyCallback = k.verify
+	auth.HostKeyAlgorithms = k.db.HostKeyAlgorithms(net.JoinHostPort(endpoint.Host, strconv.Itoa(port)))
+}
+
+func (k *knownHosts) verify(hostname string, remote net.Addr, key cryptossh.PublicKey) error {
+	err := k.db.HostKeyCallback()(hostname, remote, key)
+	if knownhosts.IsHostKeyChanged(err) {
+		return microerror.Maskf(hostKeyVerificationFailedError, "%s host key %s of %#q does not match known_hosts", key.Type(), cryptossh.FingerprintSHA256(key), hostname)
+	} else if knownhosts.IsHostUnknown(err) {
+		return microerror.Maskf(hostKeyVerificationFailedError, "host %#q is not in known_hosts", hostname)
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
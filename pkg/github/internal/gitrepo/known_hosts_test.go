@@ -0,0 +1,200 @@
+package gitrepo
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5"
+	"github.com/skeema/knownhosts"
+	cryptossh "golang.org/x/crypto/ssh"
+
+	intssh "github.com/giantswarm/config-controller/internal/ssh"
+)
+
+func TestRepo_knownHosts(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary is required to serve repositories over SSH")
+	}
+
+	ctx := context.Background()
+
+	origin := t.TempDir()
+	repo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	sha := commitFile(t, repo, origin, "default/config.yaml", "answer: 42\n")
+
+	hostKey := newTestSigner(t)
+	addr := serveGitSSH(t, hostKey)
+	otherKey := newTestSigner(t)
+
+	testCases := []struct {
+		name                 string
+		knownHosts           string
+		expectedErrorMessage string
+	}{
+		{
+			name:       "case 0: known host key",
+			knownHosts: knownhosts.Line([]string{addr}, hostKey.PublicKey()),
+		},
+		{
+			name:                 "case 1: host key mismatch",
+			knownHosts:           knownhosts.Line([]string{addr}, otherKey.PublicKey()),
+			expectedErrorMessage: "does not match known_hosts",
+		},
+		{
+			name:                 "case 2: unknown host",
+			knownHosts:           knownhosts.Line([]string{"git.example.com"}, hostKey.PublicKey()),
+			expectedErrorMessage: "is not in known_hosts",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{
+				GitHubSSHCredential: intssh.Credential{
+					Key: testSSHKey(t),
+				},
+				KnownHosts:    tc.knownHosts + "\n",
+				RepositoryURL: "ssh://git@" + addr + origin,
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			revision, err := r.ResolveRevision(ctx, "giantswarm", "config", "master")
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if revision.Config != sha {
+				t.Fatalf("revision = %q, want %q", revision.Config, sha)
+			}
+		})
+	}
+}
+
+func newTestSigner(t *testing.T) cryptossh.Signer {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	signer, err := cryptossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	return signer
+}
+
+// serveGitSSH starts an SSH server accepting any client key and serving
+// git-upload-pack requests with the git binary. It returns the address of
+// the server.
+func serveGitSSH(t *testing.T, hostKey cryptossh.Signer) string {
+	t.Helper()
+
+	config := &cryptossh.ServerConfig{
+		PublicKeyCallback: func(cryptossh.ConnMetadata, cryptossh.PublicKey) (*cryptossh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveGitSSHConn(conn, config)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func serveGitSSHConn(conn net.Conn, config *cryptossh.ServerConfig) {
+	defer func() { _ = conn.Close() }()
+
+	_, chans, reqs, err := cryptossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go cryptossh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(cryptossh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer func() { _ = channel.Close() }()
+
+			for req := range requests {
+				if req.Type != "exec" {
+					_ = req.Reply(false, nil)
+					continue
+				}
+
+				// The payload is the command as an SSH string, e.g.
+				// "git-upload-pack '/path/to/repo'".
+				command := string(req.Payload[4:])
+				path := strings.Trim(strings.TrimPrefix(command, "git-upload-pack "), "'")
+				_ = req.Reply(true, nil)
+
+				cmd := exec.Command("git", "upload-pack", path) // #nosec G204
+				cmd.Stdin = channel
+				cmd.Stdout = channel
+				cmd.Stderr = channel.Stderr()
+
+				var status uint32
+				if err := cmd.Run(); err != nil {
+					status = 1
+				}
+
+				payload := make([]byte, 4)
+				binary.BigEndian.PutUint32(payload, status)
+				_, _ = channel.SendRequest("exit-status", false, payload)
+
+				return
+			}
+		}()
+	}
+}
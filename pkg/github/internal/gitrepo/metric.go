@@ -0,0 +1,66 @@
+package gitrepo
+
+import (
+	"github.com/giantswarm/microerror"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	PrometheusNamespace = "config_controller"
+	PrometheusSubsystem = "git"
+)
+
+const (
+	labelReference = "reference"
+)
+
+var (
+	staleCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "stale_stores_total",
+			Help:      "Number of times the last known good configuration was served because the repositories were unreachable.",
+		},
+		[]string{labelReference},
+	)
+
+	rejectedGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "rejected_revision",
+			Help:      "1 when the resolved commits are not signed by a trusted key, so the last verified commits are served or, without them, reconciliations fail. It is 0 when the resolved commits are verified.",
+		},
+		[]string{labelReference},
+	)
+
+	stalenessGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "staleness_seconds",
+			Help:      "Time since the served configuration was last known to be up to date. It is 0 when the repositories are reachable.",
+		},
+		[]string{labelReference},
+	)
+)
+
+// RegisterMetrics registers the metrics of the repository cache with the
+// registerer.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		rejectedGauge,
+		staleCounter,
+		stalenessGauge,
+	}
+
+	for _, c := range collectors {
+		err := registerer.Register(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
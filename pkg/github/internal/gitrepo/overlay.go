@@ -0,0 +1,124 @@
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// ProtectedFile is the file of the shared configs repository listing the
+// files the config repository must not override. Every line holds a
+// path.Match pattern relative to the repository root, e.g.
+// "default/apps/*/configmap-values.yaml.template". Empty lines and lines
+// starting with "#" are ignored.
+const ProtectedFile = ".protected"
+
+// findOverrides returns the sorted paths of the files in the sharedDirs of
+// shared which exist in any of the higher precedence file systems too.
+func findOverrides(shared billy.Filesystem, higher []billy.Filesystem) ([]string, error) {
+	var overrides []string
+	for _, dir := range sharedDirs {
+		err := walkFiles(shared, dir, func(p string) error {
+			for _, fs := range higher {
+				stat, err := fs.Stat(p)
+				if os.IsNotExist(err) {
+					continue
+				} else if err != nil {
+					return microerror.Mask(err)
+				}
+
+				if !stat.IsDir() {
+					overrides = append(overrides, p)
+					return nil
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	sort.Strings(overrides)
+
+	return overrides, nil
+}
+
+// walkFiles calls fn with the path of every regular file below dir. A
+// missing dir is skipped.
+func walkFiles(fs billy.Filesystem, dir string, fn func(p string) error) error {
+	infos, err := fs.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, info := range infos {
+		p := path.Join(dir, info.Name())
+
+		if info.IsDir() {
+			err = walkFiles(fs, p, fn)
+		} else {
+			err = fn(p)
+		}
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// findProtected returns the overrides matching the patterns of the
+// ProtectedFile of shared.
+func findProtected(shared billy.Filesystem, overrides []string) ([]string, error) {
+	content, err := util.ReadFile(shared, ProtectedFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern := strings.TrimPrefix(path.Clean("/"+line), "/")
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "invalid pattern %#q in %#q: %s", line, ProtectedFile, err)
+		}
+
+		patterns = append(patterns, pattern)
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var protected []string
+	for _, o := range overrides {
+		for _, pattern := range patterns {
+			matched, _ := path.Match(pattern, o)
+			if matched {
+				protected = append(protected, o)
+				break
+			}
+		}
+	}
+
+	return protected, nil
+}
@@ -0,0 +1,50 @@
+package gitrepo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+)
+
+func Test_findOverrides(t *testing.T) {
+	config := memfs.New()
+	writeMemFile(t, config, "default/config.yaml", "config")
+	writeMemFile(t, config, "default/apps/foo/configmap-values.yaml.template", "foo")
+	writeMemFile(t, config, "include/self.yaml", "include")
+	writeMemFile(t, config, "installations/puma/config.yaml.patch", "patch")
+
+	shared := memfs.New()
+	writeMemFile(t, shared, "default/config.yaml", "shared")
+	writeMemFile(t, shared, "default/apps/foo/configmap-values.yaml.template", "foo")
+	writeMemFile(t, shared, "default/apps/bar/configmap-values.yaml.template", "bar")
+	writeMemFile(t, shared, "installations/puma/config.yaml.patch", "ignored")
+	writeMemFile(t, shared, ProtectedFile, "# Shared app defaults.\ndefault/apps/*/configmap-values.yaml.template\n\n")
+
+	overrides, err := findOverrides(shared, []billy.Filesystem{config})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	expectedOverrides := []string{
+		"default/apps/foo/configmap-values.yaml.template",
+		"default/config.yaml",
+	}
+	if !reflect.DeepEqual(overrides, expectedOverrides) {
+		t.Fatalf("overrides = %v, want %v", overrides, expectedOverrides)
+	}
+
+	protected, err := findProtected(shared, overrides)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	expectedProtected := []string{
+		"default/apps/foo/configmap-values.yaml.template",
+	}
+	if !reflect.DeepEqual(protected, expectedProtected) {
+		t.Fatalf("protected = %v, want %v", protected, expectedProtected)
+	}
+}
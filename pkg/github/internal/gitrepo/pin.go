@@ -0,0 +1,142 @@
+package gitrepo
+
+import (
+	"errors"
+	"os"
+	"path"
+
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/giantswarm/config-controller/internal/shared"
+)
+
+// SharedConfigsPinFile is the file of an installation in the config
+// repository pinning the refs of the shared configs repositories, e.g.
+//
+//	# installations/puma/shared-configs.yaml
+//	ref: v1.2.0
+//	repositories:
+//	  business-unit-configs: v0.3.0
+//
+// Ref pins all shared configs repositories not listed in Repositories,
+// which maps repository names to refs.
+const SharedConfigsPinFile = "shared-configs.yaml"
+
+// pinnedRepositories are the shared configs repositories pinned by the
+// commit of the config repository.
+type pinnedRepositories struct {
+	sha          string
+	repositories []shared.ConfigRepository
+}
+
+type sharedConfigsPin struct {
+	Ref          string            `json:"ref,omitempty"`
+	Repositories map[string]string `json:"repositories,omitempty"`
+}
+
+// pinSharedConfigs returns the shared configs repositories with the refs
+// pinned by the SharedConfigsPinFile of the installation in the config
+// repository. Repositories are returned unchanged when the file does not
+// exist.
+func pinSharedConfigs(fs billy.Filesystem, installation string, repositories []shared.ConfigRepository) ([]shared.ConfigRepository, error) {
+	if installation == "" {
+		return repositories, nil
+	}
+
+	p := pinFile(installation)
+	data, err := util.ReadFile(fs, p)
+	if os.IsNotExist(err) {
+		return repositories, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	pinned, err := applyPin(p, data, repositories)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return pinned, nil
+}
+
+// pinSharedConfigsAt is like pinSharedConfigs but reads the
+// SharedConfigsPinFile from the tree of the commit.
+func pinSharedConfigsAt(commit *object.Commit, installation string, repositories []shared.ConfigRepository) ([]shared.ConfigRepository, error) {
+	if installation == "" {
+		return repositories, nil
+	}
+
+	p := pinFile(installation)
+	f, err := commit.File(p)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return repositories, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Symlinked pin files are resolved like in checked out files.
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	f, err = resolveSymlink(tree, f)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	data, err := f.Contents()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	pinned, err := applyPin(p, []byte(data), repositories)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return pinned, nil
+}
+
+// pinFile returns the path of the SharedConfigsPinFile of the installation.
+func pinFile(installation string) string {
+	return path.Join("installations", installation, SharedConfigsPinFile)
+}
+
+// applyPin returns the repositories with the refs pinned by the data of the
+// pin file p.
+func applyPin(p string, data []byte, repositories []shared.ConfigRepository) ([]shared.ConfigRepository, error) {
+	var pin sharedConfigsPin
+	err := yaml.Unmarshal(data, &pin)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "invalid %#q: %s", p, err)
+	}
+
+	known := map[string]bool{}
+	pinned := make([]shared.ConfigRepository, len(repositories))
+	for i, repository := range repositories {
+		known[repository.Name] = true
+
+		if ref, ok := pin.Repositories[repository.Name]; ok {
+			repository.Ref = ref
+		} else if pin.Ref != "" {
+			repository.Ref = pin.Ref
+		}
+		if repository.Ref == "" {
+			return nil, microerror.Maskf(invalidConfigError, "%#q pins an empty ref for shared configs repository %#q", p, repository.Name)
+		}
+
+		pinned[i] = repository
+	}
+
+	for name := range pin.Repositories {
+		if !known[name] {
+			return nil, microerror.Maskf(invalidConfigError, "%#q pins unknown shared configs repository %#q", p, name)
+		}
+	}
+
+	return pinned, nil
+}
@@ -0,0 +1,282 @@
+package gitrepo
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/giantswarm/config-controller/internal/shared"
+)
+
+func Test_pinSharedConfigs(t *testing.T) {
+	repositories := []shared.ConfigRepository{
+		{Name: "shared-configs", Ref: "main"},
+		{Name: "business-unit-configs", Ref: "main"},
+	}
+
+	testCases := []struct {
+		name                 string
+		installation         string
+		pin                  string
+		expectedResult       []shared.ConfigRepository
+		expectedErrorMessage string
+	}{
+		{
+			name:           "case 0: no pin file",
+			installation:   "puma",
+			expectedResult: repositories,
+		},
+		{
+			name:         "case 1: pin all repositories",
+			installation: "puma",
+			pin:          "ref: v1.2.0\n",
+			expectedResult: []shared.ConfigRepository{
+				{Name: "shared-configs", Ref: "v1.2.0"},
+				{Name: "business-unit-configs", Ref: "v1.2.0"},
+			},
+		},
+		{
+			name:         "case 2: pin single repository",
+			installation: "puma",
+			pin:          "repositories:\n  business-unit-configs: v0.3.0\n",
+			expectedResult: []shared.ConfigRepository{
+				{Name: "shared-configs", Ref: "main"},
+				{Name: "business-unit-configs", Ref: "v0.3.0"},
+			},
+		},
+		{
+			name:           "case 3: pin file of another installation",
+			installation:   "lion",
+			pin:            "ref: v1.2.0\n",
+			expectedResult: repositories,
+		},
+		{
+			name:                 "case 4: unknown repository",
+			installation:         "puma",
+			pin:                  "repositories:\n  unknown: v0.3.0\n",
+			expectedErrorMessage: "pins unknown shared configs repository `unknown`",
+		},
+		{
+			name:                 "case 5: invalid YAML",
+			installation:         "puma",
+			pin:                  "ref: [v1.2.0\n",
+			expectedErrorMessage: "invalid `installations/puma/shared-configs.yaml`",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := memfs.New()
+			writeMemFile(t, fs, "installations/lion/config.yaml.patch", "patch")
+			if tc.pin != "" {
+				writeMemFile(t, fs, "installations/puma/"+SharedConfigsPinFile, tc.pin)
+			}
+
+			result, err := pinSharedConfigs(fs, tc.installation, repositories)
+
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if !reflect.DeepEqual(result, tc.expectedResult) {
+				t.Fatalf("result = %#v, want %#v", result, tc.expectedResult)
+			}
+		})
+	}
+}
+
+func TestRepo_AssembleConfigRepository_pin(t *testing.T) {
+	ctx := context.Background()
+
+	configDir := t.TempDir()
+	configRepo, err := git.PlainInit(configDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, configRepo, configDir, "installations/puma/"+SharedConfigsPinFile, "ref: v1.0.0\n")
+
+	sharedDir := t.TempDir()
+	sharedRepo, err := git.PlainInit(sharedDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	pinnedSHA := commitFile(t, sharedRepo, sharedDir, "default/config.yaml", "v1.0.0\n")
+	_, err = sharedRepo.CreateTag("v1.0.0", plumbing.NewHash(pinnedSHA), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, sharedRepo, sharedDir, "default/config.yaml", "master\n")
+
+	testCases := []struct {
+		name     string
+		cacheDir string
+	}{
+		{
+			name: "case 0: shallow clone",
+		},
+		{
+			name:     "case 1: on-disk cache",
+			cacheDir: t.TempDir(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{
+				CacheDir:      tc.cacheDir,
+				Installation:  "puma",
+				RepositoryURL: configDir,
+				SharedConfigRepositories: []shared.ConfigRepository{
+					{
+						Name: "shared-configs",
+						Ref:  "master",
+						URL:  sharedDir,
+					},
+				},
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			revision, err := r.ResolveRevision(ctx, "acme", "acme-config", "master")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if !reflect.DeepEqual(revision.Shared, []string{pinnedSHA}) {
+				t.Fatalf("shared revision = %v, want %v", revision.Shared, []string{pinnedSHA})
+			}
+
+			repositories, err := r.PinnedSharedConfigRepositories(ctx, "acme", "acme-config", "master")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if repositories[0].Ref != "v1.0.0" {
+				t.Fatalf("ref = %q, want %q", repositories[0].Ref, "v1.0.0")
+			}
+
+			store, err := r.AssembleConfigRepository(ctx, "acme", "acme-config", "master", revision)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if !reflect.DeepEqual(store.Revision().Shared, []string{pinnedSHA}) {
+				t.Fatalf("shared revision = %v, want %v", store.Revision().Shared, []string{pinnedSHA})
+			}
+
+			content, err := store.ReadFile("default/config.yaml")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if string(content) != "v1.0.0\n" {
+				t.Fatalf("content = %q, want %q", content, "v1.0.0\n")
+			}
+		})
+	}
+}
+
+func TestRepo_resolveSharedConfigRepositories(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name     string
+		cacheDir string
+		// expectedOldRef is the ref pinned by the previous commit. When it
+		// is empty the previous commit can't be read.
+		expectedOldRef string
+	}{
+		{
+			name: "case 0: shallow clone",
+		},
+		{
+			name:           "case 1: on-disk cache",
+			cacheDir:       t.TempDir(),
+			expectedOldRef: "v1.0.0",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			configDir := t.TempDir()
+			configRepo, err := git.PlainInit(configDir, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			oldSHA := commitFile(t, configRepo, configDir, "installations/puma/"+SharedConfigsPinFile, "ref: v1.0.0\n")
+			sha := commitFile(t, configRepo, configDir, "installations/puma/"+SharedConfigsPinFile, "ref: v2.0.0\n")
+
+			c := Config{
+				CacheDir:      tc.cacheDir,
+				Installation:  "puma",
+				RepositoryURL: configDir,
+				SharedConfigRepositories: []shared.ConfigRepository{
+					{
+						Name: "shared-configs",
+						Ref:  "master",
+					},
+				},
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			// The pin is read at the commit, not at the branch head.
+			repositories, err := r.resolveSharedConfigRepositories(ctx, "acme", "acme-config", "master", oldSHA)
+			if tc.expectedOldRef == "" {
+				if !IsReferenceMoved(err) {
+					t.Fatalf("expected reference moved error but got %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+				if repositories[0].Ref != tc.expectedOldRef {
+					t.Fatalf("ref = %q, want %q", repositories[0].Ref, tc.expectedOldRef)
+				}
+			}
+
+			repositories, err = r.resolveSharedConfigRepositories(ctx, "acme", "acme-config", "master", sha)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if repositories[0].Ref != "v2.0.0" {
+				t.Fatalf("ref = %q, want %q", repositories[0].Ref, "v2.0.0")
+			}
+
+			// The pin of the commit is cached, so the repository isn't
+			// read again.
+			err = os.RemoveAll(configDir)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			repositories, err = r.resolveSharedConfigRepositories(ctx, "acme", "acme-config", "master", sha)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if repositories[0].Ref != "v2.0.0" {
+				t.Fatalf("ref = %q, want %q", repositories[0].Ref, "v2.0.0")
+			}
+		})
+	}
+}
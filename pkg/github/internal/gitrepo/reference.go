@@ -0,0 +1,108 @@
+package gitrepo
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// Revision holds the commit SHAs the configuration is assembled from.
+type Revision struct {
+	// Config is the commit SHA of the config repository.
+	Config string
+	// Shared holds the commit SHAs of the shared configs repositories in
+	// overlay order. It is empty when the config repository is not a split
+	// config setup.
+	Shared []string
+}
+
+// lsRemote resolves the reference on the remote without fetching any
+// objects.
+func lsRemote(ctx context.Context, url string, auth transport.AuthMethod, ref string) (plumbing.Hash, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{url},
+	})
+
+	_, commit, err := listReference(ctx, remote, auth, ref)
+	if err != nil {
+		return plumbing.ZeroHash, microerror.Mask(err)
+	}
+
+	return commit, nil
+}
+
+// referenceName resolves the branch or tag name on the remote to the full
+// reference name, e.g. "refs/tags/v1.0.0".
+func referenceName(ctx context.Context, url string, auth transport.AuthMethod, name string) (plumbing.ReferenceName, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{url},
+	})
+
+	ref, _, err := listReference(ctx, remote, auth, name)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return ref.Name(), nil
+}
+
+// listReference lists the references of the remote and returns the one
+// matching the name together with the commit it points to.
+func listReference(ctx context.Context, remote *git.Remote, auth transport.AuthMethod, name string) (*plumbing.Reference, plumbing.Hash, error) {
+	refs, err := remote.ListContext(ctx, &git.ListOptions{
+		Auth:          auth,
+		PeelingOption: git.AppendPeeled,
+	})
+	if err != nil {
+		return nil, plumbing.ZeroHash, microerror.Mask(err)
+	}
+
+	ref, commit, err := findReference(refs, name)
+	if err != nil {
+		return nil, plumbing.ZeroHash, microerror.Mask(err)
+	}
+
+	return ref, commit, nil
+}
+
+// findReference returns the remote reference matching the name and the
+// commit it points to. Full reference names, branch names and tag names are
+// supported in that order. Annotated tags point to the commit of their
+// peeled reference, e.g. "refs/tags/v1.0.0^{}".
+func findReference(refs []*plumbing.Reference, name string) (*plumbing.Reference, plumbing.Hash, error) {
+	byName := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, r := range refs {
+		if r.Type() == plumbing.HashReference {
+			byName[r.Name()] = r
+		}
+	}
+
+	candidates := []plumbing.ReferenceName{
+		plumbing.ReferenceName(name),
+		plumbing.NewBranchReferenceName(name),
+		plumbing.NewTagReferenceName(name),
+	}
+
+	for _, c := range candidates {
+		ref, ok := byName[c]
+		if !ok {
+			continue
+		}
+
+		peeled, ok := byName[c+"^{}"]
+		if ok {
+			return ref, peeled.Hash(), nil
+		}
+
+		return ref, ref.Hash(), nil
+	}
+
+	return nil, plumbing.ZeroHash, microerror.Maskf(notFoundError, "reference %#q not found", name)
+}
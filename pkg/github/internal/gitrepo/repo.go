@@ -2,13 +2,22 @@ package gitrepo
 
 import (
 	"context"
-	"os"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
@@ -19,43 +28,567 @@ import (
 	intssh "github.com/giantswarm/config-controller/internal/ssh"
 )
 
+const (
+	// DefaultHost is the git host repositories are cloned from when their
+	// URL is not configured.
+	DefaultHost = "github.com"
+)
+
 type Config struct {
-	SharedConfigRepository shared.ConfigRepository
-	GitHubSSHCredential    intssh.Credential
-	GitHubToken            string
+	// Logger is optional. It logs when commits fail the signature
+	// verification and the last verified commit is used instead.
+	Logger micrologger.Logger
+
+	// CacheDir is the directory of the on-disk repository cache. When it
+	// is empty repositories are cloned in memory on every assembly.
+	CacheDir string
+	// Host is the git host serving repositories without configured URL.
+	// It defaults to DefaultHost.
+	Host string
+	// Installation is the name of the installation the configuration is
+	// assembled for. Its SharedConfigsPinFile in the config repository
+	// pins the refs of the shared configs repositories.
+	Installation string
+	// KnownHosts is the content of a known_hosts file. When it is set, host
+	// keys of SSH git servers are verified strictly against it. Otherwise
+	// the file set in $SSH_KNOWN_HOSTS or ~/.ssh/known_hosts is used.
+	KnownHosts string
+	// MaxStaleness is how long the last successfully assembled Store of a
+	// reference is served by LastKnownGood when the repositories are
+	// unreachable. Zero disables the fallback.
+	MaxStaleness time.Duration
+	// RepositoryURL is the URL of the config repository. Besides https://
+	// and ssh:// URLs, file:// URLs and local paths are supported. When it
+	// is empty the URL is built from Host, the owner and the repository
+	// name.
+	RepositoryURL string
+	// RepositoryUsername and RepositoryToken authenticate HTTPS requests
+	// to the config repository, e.g. when RepositoryURL is on another host
+	// than Host. RepositoryUsername defaults to "x-access-token".
+	RepositoryUsername string
+	RepositoryToken    string
+	// SharedConfigRepositories are overlaid in order onto the config
+	// repository of split config setups. Files of later repositories take
+	// precedence over the ones of earlier repositories and files of the
+	// config repository take precedence over all of them.
+	SharedConfigRepositories []shared.ConfigRepository
+	GitHubSSHCredential      intssh.Credential
+	// GitHubToken authenticates HTTPS requests to Host.
+	GitHubToken string
+	// TokenSource provides tokens for HTTPS requests to its host, e.g.
+	// short-lived GitHub App installation tokens. It takes precedence over
+	// GitHubToken.
+	TokenSource TokenSource
+	// TrustedGPGKeys is an armored GPG key ring and TrustedSSHKeys holds
+	// SSH public keys in authorized_keys format. When any of them is set,
+	// the commits of the config repository and the shared configs
+	// repository must be signed by one of the keys.
+	TrustedGPGKeys string
+	TrustedSSHKeys string
+}
+
+// TokenSource provides tokens for HTTPS authentication with the git host
+// returned by Host. Implementations must be safe for concurrent use.
+type TokenSource interface {
+	Host() string
+	Token(ctx context.Context) (string, error)
 }
 
 type Repo struct {
-	sharedConfigRepository shared.ConfigRepository
-	gitHubSSHCredential    intssh.Credential
-	gitHubToken            string
+	logger micrologger.Logger
+
+	cache                    *cache
+	host                     string
+	installation             string
+	knownHosts               *knownHosts
+	repositoryURL            string
+	repositoryUsername       string
+	repositoryToken          string
+	sharedConfigRepositories []shared.ConfigRepository
+	gitHubSSHCredential      intssh.Credential
+	gitHubToken              string
+	tokenSource              TokenSource
+	verifier                 *signatureVerifier
+
+	// verified holds the last Store assembled from verified commits per
+	// config repository reference.
+	verified map[string]*Store
+	// rejected holds the last revision per config repository reference
+	// whose commits failed the signature verification, so they aren't
+	// checked out and verified again on every assembly.
+	rejected      map[string]rejection
+	verifiedMutex sync.Mutex
+
+	// pins holds the shared configs repositories pinned by the last
+	// resolved commit per config repository reference.
+	pins      map[string]pinnedRepositories
+	pinsMutex sync.Mutex
+
+	// lastGood holds the last successfully assembled Store per config
+	// repository reference.
+	lastGood      map[string]lastGood
+	lastGoodMutex sync.Mutex
+	maxStaleness  time.Duration
+	now           func() time.Time
 }
 
 func New(config Config) (*Repo, error) {
+	if config.Host == "" {
+		config.Host = DefaultHost
+	}
+
 	r := &Repo{
-		sharedConfigRepository: config.SharedConfigRepository,
-		gitHubSSHCredential:    config.GitHubSSHCredential,
-		gitHubToken:            config.GitHubToken,
+		logger: config.Logger,
+
+		host:                     config.Host,
+		installation:             config.Installation,
+		repositoryURL:            config.RepositoryURL,
+		repositoryUsername:       config.RepositoryUsername,
+		repositoryToken:          config.RepositoryToken,
+		sharedConfigRepositories: config.SharedConfigRepositories,
+		gitHubSSHCredential:      config.GitHubSSHCredential,
+		gitHubToken:              config.GitHubToken,
+		tokenSource:              config.TokenSource,
+
+		verified: map[string]*Store{},
+		rejected: map[string]rejection{},
+
+		pins: map[string]pinnedRepositories{},
+
+		lastGood:     map[string]lastGood{},
+		maxStaleness: config.MaxStaleness,
+		now:          time.Now,
+	}
+
+	if config.CacheDir != "" {
+		r.cache = newCache(config.CacheDir)
+	}
+
+	if config.KnownHosts != "" {
+		var err error
+		r.knownHosts, err = newKnownHosts(config.KnownHosts)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	if config.TrustedGPGKeys != "" || config.TrustedSSHKeys != "" {
+		var err error
+		r.verifier, err = newSignatureVerifier(config.TrustedGPGKeys, config.TrustedSSHKeys)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
 	}
 
 	return r, nil
 }
 
-func (r *Repo) AssembleConfigRepository(ctx context.Context, owner, name, branch string) (*Store, error) {
-	return r.ShallowAssembleConfigRepository(ctx, owner, name, branch)
+// ResolveRevision resolves the reference of the config repository and, for
+// split config setups, the references of the shared configs repositories to
+// commit SHAs. The references of the shared configs repositories may be
+// pinned by the SharedConfigsPinFile of the installation in the config
+// repository.
+func (r *Repo) ResolveRevision(ctx context.Context, owner, name, branch string) (Revision, error) {
+	var revision Revision
+	{
+		url, auth, err := r.configRepository(ctx, owner, name)
+		if err != nil {
+			return Revision{}, microerror.Mask(err)
+		}
+
+		hash, err := lsRemote(ctx, url, auth, branch)
+		if err != nil {
+			return Revision{}, microerror.Mask(err)
+		}
+
+		revision.Config = hash.String()
+	}
+
+	if r.isSplitSetup(owner, name) {
+		repositories, err := r.resolveSharedConfigRepositories(ctx, owner, name, branch, revision.Config)
+		if err != nil {
+			return Revision{}, microerror.Mask(err)
+		}
+
+		for _, repository := range repositories {
+			url, auth, err := r.sharedRepository(ctx, owner, repository)
+			if err != nil {
+				return Revision{}, microerror.Mask(err)
+			}
+
+			hash, err := lsRemote(ctx, url, auth, repository.Ref)
+			if err != nil {
+				return Revision{}, microerror.Mask(err)
+			}
+
+			revision.Shared = append(revision.Shared, hash.String())
+		}
+	}
+
+	r.confirmLastGood(referenceKey(owner, name, branch), revision)
+
+	return revision, nil
+}
+
+// PinnedSharedConfigRepositories returns the shared configs repositories of split
+// config setups with the refs pinned at the commit the reference of the
+// config repository currently points to.
+func (r *Repo) PinnedSharedConfigRepositories(ctx context.Context, owner, name, branch string) ([]shared.ConfigRepository, error) {
+	if !r.isSplitSetup(owner, name) {
+		return nil, nil
+	}
+
+	url, auth, err := r.configRepository(ctx, owner, name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	hash, err := lsRemote(ctx, url, auth, branch)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	repositories, err := r.resolveSharedConfigRepositories(ctx, owner, name, branch, hash.String())
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return repositories, nil
+}
+
+// AssembleConfigRepository assembles the configuration of the revision
+// resolved with ResolveRevision. Without the on-disk cache the references
+// are cloned instead and an error matched by IsReferenceMoved is returned
+// when they don't point to the commits of the revision anymore.
+//
+// When signature verification is enabled and a commit isn't signed by a
+// trusted key, the Store of the last verified commits is returned instead.
+// Without such Store an error matched by IsSignatureVerificationFailed is
+// returned. The rejected revision is remembered, so it is not checked out
+// again when it is assembled again.
+//
+// The returned Store is remembered for LastKnownGood.
+func (r *Repo) AssembleConfigRepository(ctx context.Context, owner, name, branch string, revision Revision) (*Store, error) {
+	store, err := r.assembleVerified(ctx, owner, name, branch, revision)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	r.rememberLastGood(referenceKey(owner, name, branch), store)
+
+	return store, nil
+}
+
+func (r *Repo) assembleVerified(ctx context.Context, owner, name, branch string, revision Revision) (*Store, error) {
+	if r.verifier == nil {
+		return r.assemble(ctx, owner, name, branch, revision)
+	}
+
+	key := referenceKey(owner, name, branch)
+
+	var store *Store
+	err := r.rejectedError(key, revision)
+	if err == nil {
+		store, err = r.assemble(ctx, owner, name, branch, revision)
+	}
+
+	r.verifiedMutex.Lock()
+	defer r.verifiedMutex.Unlock()
+
+	if IsSignatureVerificationFailed(err) {
+		r.rejected[key] = rejection{
+			revision: revision,
+			err:      err,
+		}
+		rejectedGauge.WithLabelValues(key).Set(1)
+
+		verified, ok := r.verified[key]
+		if !ok {
+			return nil, microerror.Mask(err)
+		}
+
+		if r.logger != nil {
+			r.logger.LogCtx(ctx, "level", "warning", "message", fmt.Sprintf("rejected %#q, using last verified commit %#q", key, verified.revision.Config), "reason", err.Error())
+		}
+
+		return verified, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	delete(r.rejected, key)
+	rejectedGauge.WithLabelValues(key).Set(0)
+
+	r.pinStore(store, r.verified[key])
+	r.verified[key] = store
+
+	return store, nil
+}
+
+func (r *Repo) assemble(ctx context.Context, owner, name, branch string, revision Revision) (*Store, error) {
+	if r.cache != nil {
+		return r.CachedAssembleConfigRepository(ctx, owner, name, branch, revision)
+	}
+
+	return r.ShallowAssembleConfigRepository(ctx, owner, name, branch, revision)
+}
+
+// rejection is the last revision of a reference that failed the signature
+// verification.
+type rejection struct {
+	revision Revision
+	err      error
+}
+
+// rejectedError returns the signature verification error of the revision
+// when it was rejected before.
+func (r *Repo) rejectedError(key string, revision Revision) error {
+	r.verifiedMutex.Lock()
+	defer r.verifiedMutex.Unlock()
+
+	rj, ok := r.rejected[key]
+	if !ok || !reflect.DeepEqual(rj.revision, revision) {
+		return nil
+	}
+
+	return rj.err
+}
+
+// pinStore keeps the worktrees of the Store from being pruned and releases
+// the ones of the replaced Store. The worktrees of Stores kept for later use
+// would be pruned otherwise once they weren't checked out for a while.
+func (r *Repo) pinStore(store, replaced *Store) {
+	if r.cache == nil {
+		return
+	}
+
+	r.cache.Pin(store.worktrees)
+	if replaced != nil {
+		r.cache.Unpin(replaced.worktrees)
+	}
+}
+
+// CachedAssembleConfigRepository assembles the configuration from
+// worktrees of the on-disk repository cache. Repositories are fetched
+// incrementally and the worktrees are shared by all assemblies of the same
+// commits.
+func (r *Repo) CachedAssembleConfigRepository(ctx context.Context, owner, name, branch string, revision Revision) (*Store, error) {
+	url, auth, err := r.configRepository(ctx, owner, name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	config, err := r.cache.Checkout(ctx, url, auth, branch, revision.Config)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = r.verifyCommit(config.commit)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	store := &Store{
+		fs:   config.fs,
+		name: owner + "/" + name,
+		revision: Revision{
+			Config: config.sha,
+		},
+		worktrees: []string{config.path},
+	}
+
+	// Use shared defaults and includes for split config setups.
+	if r.isSplitSetup(owner, name) {
+		repositories, err := pinSharedConfigs(config.fs, r.installation, r.sharedConfigRepositories)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		var layers []layer
+		for i, repository := range repositories {
+			url, auth, err := r.sharedRepository(ctx, owner, repository)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			var sha string
+			if i < len(revision.Shared) {
+				sha = revision.Shared[i]
+			}
+
+			shared, err := r.cache.Checkout(ctx, url, auth, repository.Ref, sha)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			err = r.verifyCommit(shared.commit)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			store.revision.Shared = append(store.revision.Shared, shared.sha)
+			store.worktrees = append(store.worktrees, shared.path)
+			layers = append(layers, layer{
+				name:       sharedRepositoryName(owner, repository),
+				fs:         shared.fs,
+				repository: repository,
+			})
+		}
+
+		err = r.overlaySharedConfigs(ctx, store, layers)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return store, nil
+}
+
+// ShallowAssembleConfigRepository assembles the configuration from shallow
+// in-memory clones of the references. Empty SHAs of the revision accept any
+// commit the references point to.
+func (r *Repo) ShallowAssembleConfigRepository(ctx context.Context, owner, name, branch string, revision Revision) (*Store, error) {
+	fs, commit, err := r.cloneConfigRepository(ctx, owner, name, branch, revision.Config)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = r.verifyCommit(commit)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	store := &Store{
+		fs:   fs,
+		name: owner + "/" + name,
+		revision: Revision{
+			Config: commit.Hash.String(),
+		},
+	}
+
+	// Assemble shared configs for split config setups
+	if r.isSplitSetup(owner, name) {
+		repositories, err := pinSharedConfigs(fs, r.installation, r.sharedConfigRepositories)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		var layers []layer
+		for i, repository := range repositories {
+			var sha string
+			if i < len(revision.Shared) {
+				sha = revision.Shared[i]
+			}
+
+			sharedFs, sha, err := r.cloneSharedConfigs(ctx, owner, repository, sha)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			store.revision.Shared = append(store.revision.Shared, sha)
+			layers = append(layers, layer{
+				name:       sharedRepositoryName(owner, repository),
+				fs:         sharedFs,
+				repository: repository,
+			})
+		}
+
+		err = r.overlaySharedConfigs(ctx, store, layers)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return store, nil
+}
+
+// resolveSharedConfigRepositories returns the shared configs repositories
+// with the refs pinned by the config repository at the commit. The pinned
+// repositories of the last commit of every reference are kept, so the pin
+// file is only read again when the reference moved.
+func (r *Repo) resolveSharedConfigRepositories(ctx context.Context, owner, name, branch, sha string) ([]shared.ConfigRepository, error) {
+	if r.installation == "" {
+		return r.sharedConfigRepositories, nil
+	}
+
+	key := referenceKey(owner, name, branch)
+
+	r.pinsMutex.Lock()
+	p, ok := r.pins[key]
+	r.pinsMutex.Unlock()
+	if ok && p.sha == sha {
+		return p.repositories, nil
+	}
+
+	var repositories []shared.ConfigRepository
+	if r.cache != nil {
+		url, auth, err := r.configRepository(ctx, owner, name)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		config, err := r.cache.Checkout(ctx, url, auth, branch, sha)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		repositories, err = pinSharedConfigs(config.fs, r.installation, r.sharedConfigRepositories)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	} else {
+		// Only the pin file is read from the commit, its tree isn't
+		// written.
+		commit, err := r.cloneConfigCommit(ctx, owner, name, branch, sha)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		repositories, err = pinSharedConfigsAt(commit, r.installation, r.sharedConfigRepositories)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	r.pinsMutex.Lock()
+	r.pins[key] = pinnedRepositories{sha: sha, repositories: repositories}
+	r.pinsMutex.Unlock()
+
+	return repositories, nil
 }
 
-func (r *Repo) ShallowAssembleConfigRepository(ctx context.Context, owner, name, branch string) (*Store, error) {
-	// Initialize file system
+// cloneConfigRepository clones the branch of the config repository in
+// memory and returns its files and the checked out commit. When sha isn't
+// empty and the branch doesn't point to it anymore an error matched by
+// IsReferenceMoved is returned.
+func (r *Repo) cloneConfigRepository(ctx context.Context, owner, name, branch, sha string) (billy.Filesystem, *object.Commit, error) {
+	commit, err := r.cloneConfigCommit(ctx, owner, name, branch, sha)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	// The files are written like the ones of the on-disk cache, so both
+	// handle symlinks the same way.
 	fs := memfs.New()
+	err = writeTree(commit, fs)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	return fs, commit, nil
+}
 
-	// Clone root config repository
-	url, auth, err := r.createUrlAndAuthMethod(owner, name, r.gitHubToken, r.gitHubSSHCredential.Key, r.gitHubSSHCredential.Password)
+// cloneConfigCommit clones the branch of the config repository in memory
+// without worktree and returns the commit it points to. When sha isn't empty
+// and the branch doesn't point to it anymore an error matched by
+// IsReferenceMoved is returned.
+func (r *Repo) cloneConfigCommit(ctx context.Context, owner, name, branch, sha string) (*object.Commit, error) {
+	url, auth, err := r.configRepository(ctx, owner, name)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	_, err = git.CloneContext(ctx, memory.NewStorage(), fs, &git.CloneOptions{
+	repo, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
 		Auth:          auth,
 		URL:           url,
 		ReferenceName: plumbing.ReferenceName(branch),
@@ -66,20 +599,22 @@ func (r *Repo) ShallowAssembleConfigRepository(ctx context.Context, owner, name,
 		return nil, microerror.Mask(err)
 	}
 
-	// Assemble shared configs for split config setups
-	if r.isSplitSetup(owner, name) {
-		err = r.assembleWithSharedConfigs(ctx, fs, owner)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
+	commit, err := headCommit(repo)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
-	// Initialize and return store
-	store := &Store{
-		fs: fs,
+	err = checkCommit(owner+"/"+name, branch, commit, sha)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
-	return store, err
+	return commit, nil
+}
+
+// referenceKey identifies the branch of the config repository.
+func referenceKey(owner, name, branch string) string {
+	return owner + "/" + name + "@" + branch
 }
 
 // If the referenced config repository is giantswarm/config then we use the original
@@ -93,121 +628,285 @@ func (r *Repo) isSplitSetup(owner, configRepositoryName string) bool {
 	return owner != "giantswarm" || configRepositoryName != "config"
 }
 
-func (r *Repo) createUrlAndAuthMethod(owner, repositoryName, token, key, password string) (string, transport.AuthMethod, error) {
-	repository := owner + "/" + repositoryName + ".git"
+// configRepository returns the URL and the auth method of the config
+// repository.
+func (r *Repo) configRepository(ctx context.Context, owner, name string) (string, transport.AuthMethod, error) {
+	return r.createUrlAndAuthMethod(ctx, r.repositoryURL, owner, name, r.gitHubSSHCredential.Key, r.gitHubSSHCredential.Password, r.repositoryUsername, r.repositoryToken)
+}
+
+// sharedRepository returns the URL and the auth method of the shared
+// configs repository. It belongs to the owner of the config repository
+// unless configured otherwise.
+func (r *Repo) sharedRepository(ctx context.Context, owner string, repository shared.ConfigRepository) (string, transport.AuthMethod, error) {
+	if repository.Owner != "" {
+		owner = repository.Owner
+	}
+
+	return r.createUrlAndAuthMethod(ctx, repository.URL, owner, repository.Name, repository.Key, repository.Password, repository.Username, repository.Token)
+}
+
+// sharedRepositoryName returns the name of the shared configs repository
+// including its owner, e.g. "giantswarm/shared-configs".
+func sharedRepositoryName(owner string, repository shared.ConfigRepository) string {
+	if repository.Owner != "" {
+		owner = repository.Owner
+	}
+
+	return owner + "/" + repository.Name
+}
+
+// createUrlAndAuthMethod returns the URL of the repository and the auth
+// method matching its protocol. When url is empty, it is built from the host,
+// owner and repository name using SSH when an SSH key is set and HTTPS
+// otherwise. HTTPS requests authenticate with the token of the repository.
+// Without it, requests to the host of the token source authenticate with a
+// minted token and requests to the git host with the static token. No
+// credentials are sent over plain HTTP.
+func (r *Repo) createUrlAndAuthMethod(ctx context.Context, url, owner, repositoryName, key, password, username, token string) (string, transport.AuthMethod, error) {
+	if url == "" {
+		repository := owner + "/" + repositoryName + ".git"
+
+		if key != "" || password != "" {
+			host := r.host
+			// GitHub serves SSH on port 443 of ssh.github.com too, which
+			// is less likely to be blocked.
+			if host == DefaultHost {
+				host = "ssh.github.com:443"
+			}
+
+			url = "ssh://git@" + host + "/" + repository
+		} else {
+			url = "https://" + r.host + "/" + repository
+		}
+	}
+
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return "", nil, microerror.Maskf(invalidConfigError, "invalid repository URL %#q: %s", url, err)
+	}
+
+	var auth transport.AuthMethod
+	switch endpoint.Protocol {
+	case "ssh":
+		if key == "" && password == "" {
+			break
+		}
 
-	var (
-		auth transport.AuthMethod
-		err  error
-		url  string
-	)
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
 
-	if key != "" || password != "" {
-		auth, err = ssh.NewPublicKeys(
-			"git",
-			[]byte(key),
-			password,
-		)
+		publicKeys, err := ssh.NewPublicKeys(user, []byte(key), password)
 		if err != nil {
 			return "", nil, microerror.Mask(err)
 		}
 
-		url = "ssh://git@ssh.github.com:443/" + repository
-	} else {
-		auth = &http.BasicAuth{
-			Username: "can-be-anything-but-not-empty",
-			Password: token,
+		if r.knownHosts != nil {
+			r.knownHosts.configure(publicKeys, endpoint)
 		}
 
-		url = "https://github.com/" + repository
+		auth = publicKeys
+	case "http":
+		if token != "" {
+			return "", nil, microerror.Maskf(invalidConfigError, "token of repository %#q must not be sent over HTTP", url)
+		}
+	case "https":
+		if token != "" {
+			if username == "" {
+				username = "x-access-token"
+			}
+
+			auth = &http.BasicAuth{
+				Username: username,
+				Password: token,
+			}
+		} else if r.tokenSource != nil && isHost(endpoint, r.tokenSource.Host()) {
+			token, err := r.tokenSource.Token(ctx)
+			if err != nil {
+				return "", nil, microerror.Mask(err)
+			}
+
+			auth = &http.BasicAuth{
+				Username: "x-access-token",
+				Password: token,
+			}
+		} else if r.gitHubToken != "" && isHost(endpoint, r.host) {
+			auth = &http.BasicAuth{
+				Username: "can-be-anything-but-not-empty",
+				Password: r.gitHubToken,
+			}
+		}
 	}
 
 	return url, auth, nil
 }
 
-func (r *Repo) assembleWithSharedConfigs(ctx context.Context, fs billy.Filesystem, owner string) error {
-	var (
-		auth transport.AuthMethod
-		err  error
-		url  string
-	)
-
-	// Clone shared config repository
-	sharedRepositoryPath := r.sharedConfigRepository.Name
-
-	err = fs.MkdirAll(sharedRepositoryPath, os.FileMode(int(0777)))
+// isHost returns true when the HTTPS endpoint is served by the host. The
+// port is only compared when the host includes one.
+func isHost(endpoint *transport.Endpoint, host string) bool {
+	name, port, err := net.SplitHostPort(host)
 	if err != nil {
-		return microerror.Mask(err)
+		return strings.EqualFold(endpoint.Host, host)
+	}
+
+	endpointPort := endpoint.Port
+	if endpointPort == 0 {
+		endpointPort = 443
 	}
 
-	url, auth, err = r.createUrlAndAuthMethod(owner, r.sharedConfigRepository.Name, r.gitHubToken, r.sharedConfigRepository.Key, r.sharedConfigRepository.Password)
+	return strings.EqualFold(endpoint.Host, name) && strconv.Itoa(endpointPort) == port
+}
+
+// cloneSharedConfigs clones the shared configs repository in memory and
+// returns its files and the SHA of the checked out commit. When sha isn't
+// empty and the ref doesn't point to it anymore an error matched by
+// IsReferenceMoved is returned.
+func (r *Repo) cloneSharedConfigs(ctx context.Context, owner string, repository shared.ConfigRepository, sha string) (billy.Filesystem, string, error) {
+	url, auth, err := r.sharedRepository(ctx, owner, repository)
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, "", microerror.Mask(err)
 	}
 
-	sharedRepositoryCloneFs, err := fs.Chroot(sharedRepositoryPath)
+	// Resolve the ref, so that tags can be cloned as well as branches.
+	ref, err := referenceName(ctx, url, auth, repository.Ref)
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, "", microerror.Mask(err)
 	}
 
-	_, err = git.CloneContext(ctx, memory.NewStorage(), sharedRepositoryCloneFs, &git.CloneOptions{
+	repo, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
 		Auth:          auth,
 		URL:           url,
-		ReferenceName: plumbing.ReferenceName(r.sharedConfigRepository.Ref),
+		ReferenceName: ref,
 		SingleBranch:  true,
 		Depth:         1,
 	})
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, "", microerror.Mask(err)
 	}
 
-	// Move shared defaults to root
-	err = fs.Rename("./"+sharedRepositoryPath+"/default", "./default")
+	commit, err := headCommit(repo)
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, "", microerror.Mask(err)
 	}
 
-	// Move shared includes to root
-	err = fs.Rename("./"+sharedRepositoryPath+"/include", "./include")
+	err = checkCommit(sharedRepositoryName(owner, repository), repository.Ref, commit, sha)
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, "", microerror.Mask(err)
 	}
 
-	err = r.removeAll(fs, sharedRepositoryPath)
+	err = r.verifyCommit(commit)
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, "", microerror.Mask(err)
 	}
 
-	return nil
-}
-
-func (r *Repo) removeAll(fs billy.Filesystem, path string) error {
-	fileInfo, err := fs.Lstat(path)
+	fs := memfs.New()
+	err = writeTree(commit, fs)
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, "", microerror.Mask(err)
 	}
 
-	if fileInfo.IsDir() {
-		fileInfos, err := fs.ReadDir(path)
+	return fs, commit.Hash.String(), nil
+}
+
+// overlaySharedConfigs merges the default and include directories of the
+// shared configs repositories into the store. Files of later layers take
+// precedence over the ones of earlier layers with the same path and files
+// of the config repository take precedence over all of them. When
+// RefuseProtectedOverrides of a shared configs repository is set,
+// overriding files listed in its ProtectedFile fails with an error matched
+// by IsProtectedOverride.
+func (r *Repo) overlaySharedConfigs(ctx context.Context, store *Store, layers []layer) error {
+	seen := map[string]bool{}
+	var overrides []string
+	for i, l := range layers {
+		configOverrides, err := findOverrides(l.fs, []billy.Filesystem{store.fs})
 		if err != nil {
 			return microerror.Mask(err)
 		}
-		for _, fileInfo := range fileInfos {
-			err = r.removeAll(fs, fs.Join(path, fileInfo.Name()))
+
+		// Only overrides of the config repository are refused. Later
+		// shared configs repositories may override protected files, e.g.
+		// of a company wide repository, and they are only reported.
+		if l.repository.RefuseProtectedOverrides && len(configOverrides) > 0 {
+			protected, err := findProtected(l.fs, configOverrides)
 			if err != nil {
 				return microerror.Mask(err)
 			}
+
+			if len(protected) > 0 {
+				return microerror.Maskf(protectedOverrideError, "protected files %s of %#q are overridden by %#q", strings.Join(protected, ", "), l.name, store.name)
+			}
 		}
-		err = fs.Remove(path)
-		if err != nil {
-			return microerror.Mask(err)
+
+		var higher []billy.Filesystem
+		for _, h := range layers[i+1:] {
+			higher = append(higher, h.fs)
 		}
-	} else {
-		err = fs.Remove(path)
+
+		sharedOverrides, err := findOverrides(l.fs, higher)
 		if err != nil {
 			return microerror.Mask(err)
 		}
+
+		for _, o := range append(configOverrides, sharedOverrides...) {
+			if !seen[o] {
+				seen[o] = true
+				overrides = append(overrides, o)
+			}
+		}
+	}
+	sort.Strings(overrides)
+
+	if r.logger != nil && len(overrides) > 0 {
+		r.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("%d files are defined in several config repositories", len(overrides)), "files", strings.Join(overrides, ","))
+	}
+
+	store.shared = layers
+	store.overrides = overrides
+
+	return nil
+}
+
+// verifyCommit verifies the signature of the commit when signature
+// verification is enabled.
+func (r *Repo) verifyCommit(commit *object.Commit) error {
+	if r.verifier == nil {
+		return nil
+	}
+
+	err := r.verifier.verify(commit)
+	if err != nil {
+		return microerror.Mask(err)
 	}
 
 	return nil
 }
+
+// checkCommit returns an error matched by IsReferenceMoved when sha isn't
+// empty and differs from the commit the ref of the repository was cloned
+// at. Shallow clones can't check out other commits, so the reference must
+// be resolved again.
+func checkCommit(repository, ref string, commit *object.Commit, sha string) error {
+	if sha == "" || commit.Hash.String() == sha {
+		return nil
+	}
+
+	return microerror.Maskf(referenceMovedError, "%#q of %#q moved from %#q to %#q", ref, repository, sha, commit.Hash.String())
+}
+
+// headCommit returns the commit checked out in the repository. Annotated
+// tags are peeled.
+func headCommit(repo *git.Repository) (*object.Commit, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	commit, err := resolveCommit(repo, head.Hash())
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return commit, nil
+}
@@ -0,0 +1,822 @@
+package gitrepo
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	nethttp "net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	cryptossh "golang.org/x/crypto/ssh"
+
+	"github.com/giantswarm/config-controller/internal/shared"
+)
+
+func TestRepo_AssembleConfigRepository(t *testing.T) {
+	ctx := context.Background()
+
+	configDir := t.TempDir()
+	configRepo, err := git.PlainInit(configDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	configSHA := commitFile(t, configRepo, configDir, "installations/puma/config.yaml.patch", "patch\n")
+
+	sharedDir := t.TempDir()
+	sharedRepo, err := git.PlainInit(sharedDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, sharedRepo, sharedDir, "include/self.yaml", "include\n")
+	commitFile(t, sharedRepo, sharedDir, "default/apps/foo/configmap-values.yaml.template", "shared\n")
+	sharedSHA := commitFile(t, sharedRepo, sharedDir, "default/config.yaml", "shared\n")
+
+	businessDir := t.TempDir()
+	businessRepo, err := git.PlainInit(businessDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	businessSHA := commitFile(t, businessRepo, businessDir, "default/config.yaml", "business\n")
+
+	testCases := []struct {
+		name     string
+		cacheDir string
+	}{
+		{
+			name: "case 0: shallow clone",
+		},
+		{
+			name:     "case 1: on-disk cache",
+			cacheDir: t.TempDir(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{
+				CacheDir:      tc.cacheDir,
+				RepositoryURL: "file://" + configDir,
+				SharedConfigRepositories: []shared.ConfigRepository{
+					{
+						Name: "shared-configs",
+						Ref:  "master",
+						// Local paths work as well as file:// URLs.
+						URL: sharedDir,
+					},
+					{
+						Name:  "business-configs",
+						Owner: "business",
+						Ref:   "master",
+						URL:   businessDir,
+					},
+				},
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			revision, err := r.ResolveRevision(ctx, "acme", "acme-config", "master")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			expectedRevision := Revision{Config: configSHA, Shared: []string{sharedSHA, businessSHA}}
+			if !reflect.DeepEqual(revision, expectedRevision) {
+				t.Fatalf("revision = %#v, want %#v", revision, expectedRevision)
+			}
+
+			store, err := r.AssembleConfigRepository(ctx, "acme", "acme-config", "master", revision)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			if !reflect.DeepEqual(store.Revision(), expectedRevision) {
+				t.Fatalf("revision = %#v, want %#v", store.Revision(), expectedRevision)
+			}
+
+			files := []struct {
+				name    string
+				content string
+				source  string
+			}{
+				{"default/apps/foo/configmap-values.yaml.template", "shared\n", "acme/shared-configs"},
+				// Later shared repositories take precedence.
+				{"default/config.yaml", "business\n", "business/business-configs"},
+				{"include/self.yaml", "include\n", "acme/shared-configs"},
+				{"installations/puma/config.yaml.patch", "patch\n", "acme/acme-config"},
+			}
+			for _, f := range files {
+				content, err := store.ReadFile(f.name)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+				if string(content) != f.content {
+					t.Fatalf("content of %q = %q, want %q", f.name, content, f.content)
+				}
+
+				source, err := store.Source(f.name)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+				if source != f.source {
+					t.Fatalf("source of %q = %q, want %q", f.name, source, f.source)
+				}
+			}
+
+			expectedOverrides := []string{"default/config.yaml"}
+			if !reflect.DeepEqual(store.Overrides(), expectedOverrides) {
+				t.Fatalf("overrides = %v, want %v", store.Overrides(), expectedOverrides)
+			}
+		})
+	}
+}
+
+func TestRepo_AssembleConfigRepository_protected(t *testing.T) {
+	ctx := context.Background()
+
+	configDir := t.TempDir()
+	configRepo, err := git.PlainInit(configDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, configRepo, configDir, "default/apps/foo/configmap-values.yaml.template", "customer\n")
+
+	sharedDir := t.TempDir()
+	sharedRepo, err := git.PlainInit(sharedDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, sharedRepo, sharedDir, ProtectedFile, "default/apps/*/configmap-values.yaml.template\n")
+	commitFile(t, sharedRepo, sharedDir, "default/apps/foo/configmap-values.yaml.template", "shared\n")
+
+	// The business unit repository is overlaid after the company wide
+	// shared configs repository and overrides its protected file.
+	businessUnitConfigDir := t.TempDir()
+	businessUnitConfigRepo, err := git.PlainInit(businessUnitConfigDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, businessUnitConfigRepo, businessUnitConfigDir, "installations/puma/config.yaml.patch", "customer\n")
+
+	businessUnitDir := t.TempDir()
+	businessUnitRepo, err := git.PlainInit(businessUnitDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, businessUnitRepo, businessUnitDir, "default/apps/foo/configmap-values.yaml.template", "business unit\n")
+
+	testCases := []struct {
+		name                 string
+		cacheDir             string
+		businessUnit         bool
+		refuseProtected      bool
+		expectedContent      string
+		expectedErrorMessage string
+	}{
+		{
+			name:            "case 0: shallow clone, config repository overrides shared file",
+			expectedContent: "customer\n",
+		},
+		{
+			name:            "case 1: on-disk cache, config repository overrides shared file",
+			cacheDir:        t.TempDir(),
+			expectedContent: "customer\n",
+		},
+		{
+			name:                 "case 2: shallow clone, protected override is refused",
+			refuseProtected:      true,
+			expectedErrorMessage: "are overridden",
+		},
+		{
+			name:                 "case 3: on-disk cache, protected override is refused",
+			cacheDir:             t.TempDir(),
+			refuseProtected:      true,
+			expectedErrorMessage: "are overridden",
+		},
+		{
+			name:            "case 4: shallow clone, protected override of a later shared configs repository is reported",
+			businessUnit:    true,
+			refuseProtected: true,
+			expectedContent: "business unit\n",
+		},
+		{
+			name:            "case 5: on-disk cache, protected override of a later shared configs repository is reported",
+			cacheDir:        t.TempDir(),
+			businessUnit:    true,
+			refuseProtected: true,
+			expectedContent: "business unit\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{
+				CacheDir:      tc.cacheDir,
+				RepositoryURL: configDir,
+				SharedConfigRepositories: []shared.ConfigRepository{
+					{
+						Name: "shared-configs",
+						Ref:  "master",
+						URL:  sharedDir,
+
+						RefuseProtectedOverrides: tc.refuseProtected,
+					},
+				},
+			}
+			if tc.businessUnit {
+				c.RepositoryURL = businessUnitConfigDir
+				c.SharedConfigRepositories = append(c.SharedConfigRepositories, shared.ConfigRepository{
+					Name: "business-unit-configs",
+					Ref:  "master",
+					URL:  businessUnitDir,
+				})
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			revision, err := r.ResolveRevision(ctx, "acme", "acme-config", "master")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			store, err := r.AssembleConfigRepository(ctx, "acme", "acme-config", "master", revision)
+
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			content, err := store.ReadFile("default/apps/foo/configmap-values.yaml.template")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if string(content) != tc.expectedContent {
+				t.Fatalf("content = %q, want %q", content, tc.expectedContent)
+			}
+
+			expectedOverrides := []string{"default/apps/foo/configmap-values.yaml.template"}
+			if !reflect.DeepEqual(store.Overrides(), expectedOverrides) {
+				t.Fatalf("overrides = %v, want %v", store.Overrides(), expectedOverrides)
+			}
+		})
+	}
+}
+
+func TestRepo_AssembleConfigRepository_symlinks(t *testing.T) {
+	ctx := context.Background()
+
+	configDir := t.TempDir()
+	configRepo, err := git.PlainInit(configDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, configRepo, configDir, "installations/puma/config.yaml.patch", "patch\n")
+	commitSymlink(t, configRepo, configDir, "installations/lion/config.yaml.patch", "../puma/config.yaml.patch")
+
+	sharedDir := t.TempDir()
+	sharedRepo, err := git.PlainInit(sharedDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, sharedRepo, sharedDir, "default/config.yaml", "shared\n")
+	commitSymlink(t, sharedRepo, sharedDir, "include/config.yaml", "../default/config.yaml")
+	// Symlinks are followed within the tree.
+	commitSymlink(t, sharedRepo, sharedDir, "include/link.yaml", "config.yaml")
+
+	escapingDir := t.TempDir()
+	escapingRepo, err := git.PlainInit(escapingDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitSymlink(t, escapingRepo, escapingDir, "installations/puma/config.yaml.patch", "../../../etc/passwd")
+
+	testCases := []struct {
+		name                 string
+		cacheDir             string
+		repositoryDir        string
+		expectedErrorMessage string
+	}{
+		{
+			name:          "case 0: shallow clone, symlinks within the tree",
+			repositoryDir: configDir,
+		},
+		{
+			name:          "case 1: on-disk cache, symlinks within the tree",
+			cacheDir:      t.TempDir(),
+			repositoryDir: configDir,
+		},
+		{
+			name:                 "case 2: shallow clone, symlink outside of the tree",
+			repositoryDir:        escapingDir,
+			expectedErrorMessage: "points outside of the repository",
+		},
+		{
+			name:                 "case 3: on-disk cache, symlink outside of the tree",
+			cacheDir:             t.TempDir(),
+			repositoryDir:        escapingDir,
+			expectedErrorMessage: "points outside of the repository",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{
+				CacheDir:      tc.cacheDir,
+				RepositoryURL: tc.repositoryDir,
+				SharedConfigRepositories: []shared.ConfigRepository{
+					{
+						Name: "shared-configs",
+						Ref:  "master",
+						URL:  sharedDir,
+					},
+				},
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			revision, err := r.ResolveRevision(ctx, "acme", "acme-config", "master")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			store, err := r.AssembleConfigRepository(ctx, "acme", "acme-config", "master", revision)
+
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !IsInvalidSymlink(err):
+					t.Fatalf("expected invalid symlink error but got %q", microerror.Pretty(err, true))
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			files := map[string]string{
+				"default/config.yaml":                  "shared\n",
+				"include/config.yaml":                  "shared\n",
+				"include/link.yaml":                    "shared\n",
+				"installations/lion/config.yaml.patch": "patch\n",
+				"installations/puma/config.yaml.patch": "patch\n",
+			}
+			for name, expected := range files {
+				content, err := store.ReadFile(name)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+				if string(content) != expected {
+					t.Fatalf("content of %q = %q, want %q", name, content, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestRepo_AssembleConfigRepository_moved(t *testing.T) {
+	ctx := context.Background()
+
+	configDir := t.TempDir()
+	configRepo, err := git.PlainInit(configDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, configRepo, configDir, "installations/puma/config.yaml.patch", "patch\n")
+
+	sharedDir := t.TempDir()
+	sharedRepo, err := git.PlainInit(sharedDir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	commitFile(t, sharedRepo, sharedDir, "default/config.yaml", "shared\n")
+
+	testCases := []struct {
+		name                 string
+		cacheDir             string
+		moveShared           bool
+		expectedErrorMessage string
+	}{
+		{
+			name:                 "case 0: shallow clone, config repository moved",
+			expectedErrorMessage: "moved from",
+		},
+		{
+			name:                 "case 1: shallow clone, shared configs repository moved",
+			moveShared:           true,
+			expectedErrorMessage: "moved from",
+		},
+		{
+			name:     "case 2: on-disk cache, config repository moved",
+			cacheDir: t.TempDir(),
+		},
+		{
+			name:       "case 3: on-disk cache, shared configs repository moved",
+			cacheDir:   t.TempDir(),
+			moveShared: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{
+				CacheDir:      tc.cacheDir,
+				RepositoryURL: configDir,
+				SharedConfigRepositories: []shared.ConfigRepository{
+					{
+						Name: "shared-configs",
+						Ref:  "master",
+						URL:  sharedDir,
+					},
+				},
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			revision, err := r.ResolveRevision(ctx, "acme", "acme-config", "master")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			// Push after the revision is resolved.
+			if tc.moveShared {
+				commitFile(t, sharedRepo, sharedDir, "default/config.yaml", fmt.Sprintf("moved %d\n", i))
+			} else {
+				commitFile(t, configRepo, configDir, "installations/puma/config.yaml.patch", fmt.Sprintf("moved %d\n", i))
+			}
+
+			store, err := r.AssembleConfigRepository(ctx, "acme", "acme-config", "master", revision)
+
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !IsReferenceMoved(err):
+					t.Fatalf("expected reference moved error but got %q", microerror.Pretty(err, true))
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if !reflect.DeepEqual(store.Revision(), revision) {
+				t.Fatalf("revision = %#v, want %#v", store.Revision(), revision)
+			}
+		})
+	}
+}
+
+// commitSymlink commits a symlink pointing to target to the repository.
+func commitSymlink(t *testing.T, repo *git.Repository, dir, name, target string) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0750)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	err = os.Symlink(target, filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	_, err = wt.Add(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	_, err = wt.Commit("link "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+}
+
+func TestRepo_tokenSource(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git binary is required to serve repositories over HTTP")
+	}
+
+	ctx := context.Background()
+
+	root := t.TempDir()
+	origin := filepath.Join(root, "giantswarm", "config")
+	repo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	sha := commitFile(t, repo, origin, "default/config.yaml", "answer: 42\n")
+
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + root,
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	}
+	// Tokens are only sent over HTTPS.
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "x-access-token" || password != "installation-token" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(nethttp.StatusUnauthorized)
+			return
+		}
+
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	client.InstallProtocol("https", http.NewClient(server.Client()))
+	defer client.InstallProtocol("https", http.DefaultClient)
+
+	c := Config{
+		GitHubToken:   "personal-access-token",
+		RepositoryURL: server.URL + "/giantswarm/config",
+		TokenSource:   staticTokenSource{host: "127.0.0.1", token: "installation-token"},
+	}
+
+	r, err := New(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	revision, err := r.ResolveRevision(ctx, "giantswarm", "config", "master")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	store, err := r.AssembleConfigRepository(ctx, "giantswarm", "config", "master", revision)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	if store.Revision().Config != sha {
+		t.Fatalf("revision = %q, want %q", store.Revision().Config, sha)
+	}
+}
+
+func TestRepo_createUrlAndAuthMethod(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		host                 string
+		url                  string
+		token                string
+		tokenSource          TokenSource
+		key                  string
+		repositoryUsername   string
+		repositoryToken      string
+		expectedURL          string
+		expectedAuthType     transport.AuthMethod
+		expectedUser         string
+		expectedPassword     string
+		expectedErrorMessage string
+	}{
+		{
+			name:             "case 0: GitHub over HTTPS",
+			token:            "token",
+			expectedURL:      "https://github.com/giantswarm/config.git",
+			expectedAuthType: &http.BasicAuth{},
+			expectedUser:     "can-be-anything-but-not-empty",
+			expectedPassword: "token",
+		},
+		{
+			name:             "case 1: GitHub over SSH",
+			key:              testSSHKey(t),
+			expectedURL:      "ssh://git@ssh.github.com:443/giantswarm/config.git",
+			expectedAuthType: &ssh.PublicKeys{},
+			expectedUser:     "git",
+		},
+		{
+			name:             "case 2: other host over SSH",
+			host:             "gitlab.example.com",
+			key:              testSSHKey(t),
+			expectedURL:      "ssh://git@gitlab.example.com/giantswarm/config.git",
+			expectedAuthType: &ssh.PublicKeys{},
+			expectedUser:     "git",
+		},
+		{
+			name:             "case 3: SCP-like URL with user",
+			url:              "gitea@git.example.com:configs/config.git",
+			key:              testSSHKey(t),
+			expectedURL:      "gitea@git.example.com:configs/config.git",
+			expectedAuthType: &ssh.PublicKeys{},
+			expectedUser:     "gitea",
+		},
+		{
+			name:        "case 4: public repository",
+			url:         "https://gitlab.com/acme/config.git",
+			expectedURL: "https://gitlab.com/acme/config.git",
+		},
+		{
+			name:        "case 5: local path",
+			url:         "/srv/git/config.git",
+			token:       "token",
+			expectedURL: "/srv/git/config.git",
+		},
+		{
+			name:        "case 6: token is not sent to other hosts",
+			url:         "https://git.example.com/acme/config.git",
+			token:       "token",
+			tokenSource: staticTokenSource{host: "github.com", token: "installation-token"},
+			expectedURL: "https://git.example.com/acme/config.git",
+		},
+		{
+			name:        "case 7: token is not sent over HTTP",
+			url:         "http://github.com/giantswarm/config.git",
+			token:       "token",
+			tokenSource: staticTokenSource{host: "github.com", token: "installation-token"},
+			expectedURL: "http://github.com/giantswarm/config.git",
+		},
+		{
+			name:             "case 8: installation token is sent to the app host",
+			token:            "token",
+			tokenSource:      staticTokenSource{host: "github.com", token: "installation-token"},
+			expectedURL:      "https://github.com/giantswarm/config.git",
+			expectedAuthType: &http.BasicAuth{},
+			expectedUser:     "x-access-token",
+			expectedPassword: "installation-token",
+		},
+		{
+			name:             "case 9: static token is sent to the git host other than the app host",
+			host:             "git.example.com:8443",
+			token:            "token",
+			tokenSource:      staticTokenSource{host: "github.com", token: "installation-token"},
+			expectedURL:      "https://git.example.com:8443/giantswarm/config.git",
+			expectedAuthType: &http.BasicAuth{},
+			expectedUser:     "can-be-anything-but-not-empty",
+			expectedPassword: "token",
+		},
+		{
+			name:             "case 10: repository token on other host",
+			url:              "https://git.example.com/acme/config.git",
+			token:            "token",
+			repositoryToken:  "repository-token",
+			expectedURL:      "https://git.example.com/acme/config.git",
+			expectedAuthType: &http.BasicAuth{},
+			expectedUser:     "x-access-token",
+			expectedPassword: "repository-token",
+		},
+		{
+			name:               "case 11: repository username",
+			url:                "https://bitbucket.example.com/acme/config.git",
+			repositoryUsername: "acme-bot",
+			repositoryToken:    "repository-token",
+			expectedURL:        "https://bitbucket.example.com/acme/config.git",
+			expectedAuthType:   &http.BasicAuth{},
+			expectedUser:       "acme-bot",
+			expectedPassword:   "repository-token",
+		},
+		{
+			name:                 "case 12: repository token over HTTP",
+			url:                  "http://git.example.com/acme/config.git",
+			repositoryToken:      "repository-token",
+			expectedErrorMessage: "must not be sent over HTTP",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{
+				GitHubToken: tc.token,
+				Host:        tc.host,
+				TokenSource: tc.tokenSource,
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			url, auth, err := r.createUrlAndAuthMethod(context.Background(), tc.url, "giantswarm", "config", tc.key, "", tc.repositoryUsername, tc.repositoryToken)
+
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if url != tc.expectedURL {
+				t.Fatalf("url = %q, want %q", url, tc.expectedURL)
+			}
+
+			switch a := auth.(type) {
+			case nil:
+				if tc.expectedAuthType != nil {
+					t.Fatalf("auth = nil, want %T", tc.expectedAuthType)
+				}
+			case *http.BasicAuth:
+				if _, ok := tc.expectedAuthType.(*http.BasicAuth); !ok {
+					t.Fatalf("auth = %T, want %T", auth, tc.expectedAuthType)
+				}
+				if a.Username != tc.expectedUser {
+					t.Fatalf("user = %q, want %q", a.Username, tc.expectedUser)
+				}
+				if a.Password != tc.expectedPassword {
+					t.Fatalf("password = %q, want %q", a.Password, tc.expectedPassword)
+				}
+			case *ssh.PublicKeys:
+				if _, ok := tc.expectedAuthType.(*ssh.PublicKeys); !ok {
+					t.Fatalf("auth = %T, want %T", auth, tc.expectedAuthType)
+				}
+				if a.User != tc.expectedUser {
+					t.Fatalf("user = %q, want %q", a.User, tc.expectedUser)
+				}
+			default:
+				t.Fatalf("auth = %T, want %T", auth, tc.expectedAuthType)
+			}
+		})
+	}
+}
+
+func testSSHKey(t *testing.T) string {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	block, err := cryptossh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	return string(pem.EncodeToMemory(block))
+}
+
+type staticTokenSource struct {
+	host  string
+	token string
+}
+
+func (s staticTokenSource) Host() string {
+	return s.host
+}
+
+func (s staticTokenSource) Token(ctx context.Context) (string, error) {
+	return s.token, nil
+}
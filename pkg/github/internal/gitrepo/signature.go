@@ -0,0 +1,183 @@
+package gitrepo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// sshSignatureMagic is the preamble of SSH signatures, see
+	// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig.
+	sshSignatureMagic = "SSHSIG"
+	// sshSignatureNamespace is the namespace git signs commits in.
+	sshSignatureNamespace = "git"
+)
+
+// signatureVerifier verifies that commits are signed by one of the trusted
+// GPG or SSH keys.
+type signatureVerifier struct {
+	gpgKeyRing string
+	sshKeys    map[string]bool
+}
+
+// newSignatureVerifier returns a verifier trusting the keys of the armored
+// GPG key ring and the SSH public keys in authorized_keys format.
+func newSignatureVerifier(gpgKeyRing, sshKeys string) (*signatureVerifier, error) {
+	v := &signatureVerifier{
+		gpgKeyRing: gpgKeyRing,
+		sshKeys:    map[string]bool{},
+	}
+
+	rest := []byte(sshKeys)
+	for len(bytes.TrimSpace(rest)) > 0 {
+		var (
+			key ssh.PublicKey
+			err error
+		)
+		key, _, _, rest, err = ssh.ParseAuthorizedKey(rest)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "invalid trusted SSH key: %s", err)
+		}
+
+		v.sshKeys[string(key.Marshal())] = true
+	}
+
+	return v, nil
+}
+
+// verify returns an error matched by IsSignatureVerificationFailed unless
+// the commit carries a valid signature of a trusted key.
+func (v *signatureVerifier) verify(commit *object.Commit) error {
+	signature := commit.PGPSignature
+
+	switch {
+	case signature == "":
+		return microerror.Maskf(signatureVerificationFailedError, "commit %#q is not signed", commit.Hash)
+	case strings.HasPrefix(signature, "-----BEGIN SSH SIGNATURE-----"):
+		err := v.verifySSH(commit)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	default:
+		if v.gpgKeyRing == "" {
+			return microerror.Maskf(signatureVerificationFailedError, "commit %#q is signed with GPG but no GPG keys are trusted", commit.Hash)
+		}
+
+		_, err := commit.Verify(v.gpgKeyRing)
+		if err != nil {
+			return microerror.Maskf(signatureVerificationFailedError, "GPG signature of commit %#q is invalid: %s", commit.Hash, err)
+		}
+	}
+
+	return nil
+}
+
+// verifySSH verifies the SSH signature of the commit. The format is
+// described in
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig.
+func (v *signatureVerifier) verifySSH(commit *object.Commit) error {
+	block, _ := pem.Decode([]byte(commit.PGPSignature))
+	if block == nil || block.Type != "SSH SIGNATURE" || !bytes.HasPrefix(block.Bytes, []byte(sshSignatureMagic)) {
+		return microerror.Maskf(signatureVerificationFailedError, "SSH signature of commit %#q is malformed", commit.Hash)
+	}
+
+	var sig struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	err := ssh.Unmarshal(block.Bytes[len(sshSignatureMagic):], &sig)
+	if err != nil {
+		return microerror.Maskf(signatureVerificationFailedError, "SSH signature of commit %#q is malformed: %s", commit.Hash, err)
+	}
+	if sig.Version != 1 {
+		return microerror.Maskf(signatureVerificationFailedError, "SSH signature of commit %#q has unsupported version %d", commit.Hash, sig.Version)
+	}
+	if sig.Namespace != sshSignatureNamespace {
+		return microerror.Maskf(signatureVerificationFailedError, "SSH signature of commit %#q has namespace %#q, want %#q", commit.Hash, sig.Namespace, sshSignatureNamespace)
+	}
+
+	publicKey, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return microerror.Maskf(signatureVerificationFailedError, "SSH signature of commit %#q has invalid public key: %s", commit.Hash, err)
+	}
+	if !v.sshKeys[string(publicKey.Marshal())] {
+		return microerror.Maskf(signatureVerificationFailedError, "commit %#q is signed with untrusted SSH key %s", commit.Hash, ssh.FingerprintSHA256(publicKey))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return microerror.Maskf(signatureVerificationFailedError, "SSH signature of commit %#q has unsupported hash algorithm %#q", commit.Hash, sig.HashAlgorithm)
+	}
+
+	err = encodeWithoutSignature(commit, h)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	signedData := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+
+	var signature ssh.Signature
+	err = ssh.Unmarshal(sig.Signature, &signature)
+	if err != nil {
+		return microerror.Maskf(signatureVerificationFailedError, "SSH signature of commit %#q is malformed: %s", commit.Hash, err)
+	}
+
+	err = publicKey.Verify(signedData, &signature)
+	if err != nil {
+		return microerror.Maskf(signatureVerificationFailedError, "SSH signature of commit %#q is invalid: %s", commit.Hash, err)
+	}
+
+	return nil
+}
+
+// encodeWithoutSignature writes the commit object without its signature,
+// i.e. the payload git signs.
+func encodeWithoutSignature(commit *object.Commit, w io.Writer) error {
+	encoded := &plumbing.MemoryObject{}
+	err := commit.EncodeWithoutSignature(encoded)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r, err := encoded.Reader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
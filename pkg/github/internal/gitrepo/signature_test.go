@@ -0,0 +1,336 @@
+package gitrepo
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSignatureVerifier_verify(t *testing.T) {
+	trustedSSH := newSSHSigner(t)
+	untrustedSSH := newSSHSigner(t)
+	trustedGPG := newGPGEntity(t)
+	untrustedGPG := newGPGEntity(t)
+
+	testCases := []struct {
+		name                 string
+		options              git.CommitOptions
+		expectedErrorMessage string
+	}{
+		{
+			name:                 "case 0: unsigned commit",
+			expectedErrorMessage: "is not signed",
+		},
+		{
+			name:    "case 1: commit signed with trusted SSH key",
+			options: git.CommitOptions{Signer: trustedSSH},
+		},
+		{
+			name:                 "case 2: commit signed with untrusted SSH key",
+			options:              git.CommitOptions{Signer: untrustedSSH},
+			expectedErrorMessage: "is signed with untrusted SSH key",
+		},
+		{
+			name:    "case 3: commit signed with trusted GPG key",
+			options: git.CommitOptions{SignKey: trustedGPG},
+		},
+		{
+			name:                 "case 4: commit signed with untrusted GPG key",
+			options:              git.CommitOptions{SignKey: untrustedGPG},
+			expectedErrorMessage: "signature made by unknown entity",
+		},
+	}
+
+	v, err := newSignatureVerifier(armoredPublicKey(t, trustedGPG), string(ssh.MarshalAuthorizedKey(trustedSSH.signer.PublicKey())))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			repo, err := git.PlainInit(dir, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			sha := commitSignedFile(t, repo, dir, "default/config.yaml", "answer: 42\n", tc.options)
+
+			commit, err := repo.CommitObject(plumbing.NewHash(sha))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			err = v.verify(commit)
+
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestRepo_AssembleConfigRepository_signature(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name     string
+		cacheDir string
+	}{
+		{
+			name:     "case 0: on-disk cache",
+			cacheDir: t.TempDir(),
+		},
+		{
+			name: "case 1: in-memory clones",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			signer := newSSHSigner(t)
+
+			dir := t.TempDir()
+			repo, err := git.PlainInit(dir, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			c := Config{
+				CacheDir:       tc.cacheDir,
+				RepositoryURL:  dir,
+				TrustedSSHKeys: string(ssh.MarshalAuthorizedKey(signer.signer.PublicKey())),
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			assemble := func() (*Store, error) {
+				revision, err := r.ResolveRevision(ctx, "giantswarm", "config", "master")
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+
+				return r.AssembleConfigRepository(ctx, "giantswarm", "config", "master", revision)
+			}
+
+			key := referenceKey("giantswarm", "config", "master")
+
+			// Without verified commits unsigned commits are rejected.
+			commitFile(t, repo, dir, "default/config.yaml", "unsigned\n")
+			_, err = assemble()
+			if !IsSignatureVerificationFailed(err) {
+				t.Fatalf("expected signature verification failed error but got %v", err)
+			}
+
+			verified := commitSignedFile(t, repo, dir, "default/config.yaml", "signed\n", git.CommitOptions{Signer: signer})
+			store, err := assemble()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if store.Revision().Config != verified {
+				t.Fatalf("revision = %q, want %q", store.Revision().Config, verified)
+			}
+
+			if testutil.ToFloat64(rejectedGauge.WithLabelValues(key)) != 0 {
+				t.Fatalf("rejected = %v, want 0", testutil.ToFloat64(rejectedGauge.WithLabelValues(key)))
+			}
+
+			// The last verified commit is kept when a new commit is rejected.
+			commitFile(t, repo, dir, "default/config.yaml", "unsigned\n")
+			revision, err := r.ResolveRevision(ctx, "giantswarm", "config", "master")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			store, err = r.AssembleConfigRepository(ctx, "giantswarm", "config", "master", revision)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if store.Revision().Config != verified {
+				t.Fatalf("revision = %q, want %q", store.Revision().Config, verified)
+			}
+			if testutil.ToFloat64(rejectedGauge.WithLabelValues(key)) != 1 {
+				t.Fatalf("rejected = %v, want 1", testutil.ToFloat64(rejectedGauge.WithLabelValues(key)))
+			}
+
+			content, err := store.ReadFile("default/config.yaml")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if string(content) != "signed\n" {
+				t.Fatalf("content = %q, want %q", content, "signed\n")
+			}
+
+			// The rejected revision is not checked out again, so the repository
+			// isn't needed anymore.
+			err = os.RemoveAll(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			store, err = r.AssembleConfigRepository(ctx, "giantswarm", "config", "master", revision)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if store.Revision().Config != verified {
+				t.Fatalf("revision = %q, want %q", store.Revision().Config, verified)
+			}
+		})
+	}
+}
+
+func commitSignedFile(t *testing.T, repo *git.Repository, dir, name, content string, options git.CommitOptions) string {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0750)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	_, err = wt.Add(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	options.Author = &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	hash, err := wt.Commit("update "+name, &options)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	return hash.String()
+}
+
+func newGPGEntity(t *testing.T) *openpgp.Entity {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	return entity
+}
+
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	err = entity.Serialize(w)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	return buf.String()
+}
+
+// sshSigner signs commits in the SSH signature format like
+// "git -c gpg.format=ssh commit -S" does.
+type sshSigner struct {
+	signer ssh.Signer
+}
+
+func newSSHSigner(t *testing.T) *sshSigner {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	return &sshSigner{signer: signer}
+}
+
+func (s *sshSigner) Sign(message io.Reader) ([]byte, error) {
+	h := sha512.New()
+	_, err := io.Copy(h, message)
+	if err != nil {
+		return nil, err
+	}
+
+	signedData := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{
+		Namespace:     sshSignatureNamespace,
+		HashAlgorithm: "sha512",
+		Hash:          h.Sum(nil),
+	})...)
+
+	signature, err := s.signer.Sign(rand.Reader, signedData)
+	if err != nil {
+		return nil, err
+	}
+
+	blob := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{
+		Version:       1,
+		PublicKey:     s.signer.PublicKey().Marshal(),
+		Namespace:     sshSignatureNamespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(signature),
+	})...)
+
+	armored := pem.EncodeToMemory(&pem.Block{Type: "SSH SIGNATURE", Bytes: blob})
+
+	return []byte(strings.TrimSuffix(string(armored), "\n")), nil
+}
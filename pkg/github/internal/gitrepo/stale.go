@@ -0,0 +1,128 @@
+package gitrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5/plumbing"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// lastGood is the last successfully assembled Store of a reference.
+type lastGood struct {
+	store *Store
+	// confirmed is the time the Store was last known to be up to date.
+	confirmed time.Time
+}
+
+// LastKnownGood returns the last Store successfully assembled for the
+// reference when resolving or assembling it failed with cause because the
+// repositories are unreachable. The returned Store is marked as stale.
+//
+// The cause is returned when the fallback is disabled, the cause is not a
+// network or server failure, e.g. a missing reference or a refused protected
+// override, or the last Store was not confirmed to be up to date within the
+// maximum staleness.
+func (r *Repo) LastKnownGood(ctx context.Context, owner, name, branch string, cause error) (*Store, error) {
+	if r.maxStaleness <= 0 || !isUnreachable(cause) {
+		return nil, microerror.Mask(cause)
+	}
+
+	key := referenceKey(owner, name, branch)
+
+	r.lastGoodMutex.Lock()
+	defer r.lastGoodMutex.Unlock()
+
+	lg, ok := r.lastGood[key]
+	if !ok {
+		return nil, microerror.Mask(cause)
+	}
+
+	staleness := r.now().Sub(lg.confirmed)
+	if staleness > r.maxStaleness {
+		if r.logger != nil {
+			r.logger.LogCtx(ctx, "level", "warning", "message", fmt.Sprintf("last known good configuration of %#q is older than %s", key, r.maxStaleness), "staleness", staleness.String())
+		}
+
+		return nil, microerror.Mask(cause)
+	}
+
+	if r.logger != nil {
+		r.logger.LogCtx(ctx, "level", "warning", "message", fmt.Sprintf("repositories of %#q are unreachable, using last known good commit %#q", key, lg.store.revision.Config), "staleness", staleness.String(), "reason", cause.Error())
+	}
+
+	staleCounter.WithLabelValues(key).Inc()
+	stalenessGauge.WithLabelValues(key).Set(staleness.Seconds())
+
+	stale := *lg.store
+	stale.stale = true
+
+	return &stale, nil
+}
+
+// rememberLastGood records the successfully assembled Store of the
+// reference.
+func (r *Repo) rememberLastGood(key string, store *Store) {
+	r.lastGoodMutex.Lock()
+	defer r.lastGoodMutex.Unlock()
+
+	r.pinStore(store, r.lastGood[key].store)
+	r.lastGood[key] = lastGood{
+		store:     store,
+		confirmed: r.now(),
+	}
+	stalenessGauge.WithLabelValues(key).Set(0)
+}
+
+// confirmLastGood marks the last Store of the reference as up to date when
+// the references still resolve to its revision. Stores cached by the
+// callers are not assembled again, so this keeps them from getting stale.
+func (r *Repo) confirmLastGood(key string, revision Revision) {
+	r.lastGoodMutex.Lock()
+	defer r.lastGoodMutex.Unlock()
+
+	lg, ok := r.lastGood[key]
+	if !ok || !reflect.DeepEqual(lg.store.revision, revision) {
+		return
+	}
+
+	lg.confirmed = r.now()
+	r.lastGood[key] = lg
+	stalenessGauge.WithLabelValues(key).Set(0)
+}
+
+// isUnreachable returns true when the error is caused by the network or the
+// git server failing: network, DNS, dial and timeout errors and 5xx
+// responses. Other errors, e.g. missing references or commits and refused
+// credentials, are caused by the content of the repositories or the
+// configuration. Serving stale data would hide them.
+func isUnreachable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	// Unexpected errors of go-git don't unwrap the errors they carry.
+	var unexpectedErr *plumbing.UnexpectedError
+	if errors.As(err, &unexpectedErr) {
+		return isUnreachable(unexpectedErr.Err)
+	}
+
+	var httpErr *githttp.Err
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode() >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	// net.Error includes dial, DNS and URL errors of the HTTP client.
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
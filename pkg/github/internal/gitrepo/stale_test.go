@@ -0,0 +1,204 @@
+package gitrepo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+func TestRepo_LastKnownGood(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name                 string
+		maxStaleness         time.Duration
+		cause                error
+		elapsed              time.Duration
+		expectedErrorMessage string
+	}{
+		{
+			name:         "case 0: serve last known good configuration",
+			maxStaleness: time.Hour,
+			elapsed:      30 * time.Minute,
+		},
+		{
+			name:                 "case 1: fallback disabled",
+			expectedErrorMessage: "connection refused",
+		},
+		{
+			name:                 "case 2: last known good configuration is too old",
+			maxStaleness:         time.Hour,
+			elapsed:              2 * time.Hour,
+			expectedErrorMessage: "connection refused",
+		},
+		{
+			name:                 "case 3: content errors are not hidden",
+			maxStaleness:         time.Hour,
+			cause:                microerror.Mask(protectedOverrideError),
+			expectedErrorMessage: "Protected override",
+		},
+		{
+			name:                 "case 4: missing repositories are not hidden",
+			maxStaleness:         time.Hour,
+			cause:                microerror.Mask(transport.ErrRepositoryNotFound),
+			expectedErrorMessage: "Repository not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			repo, err := git.PlainInit(dir, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			sha := commitFile(t, repo, dir, "default/config.yaml", "config\n")
+
+			c := Config{
+				MaxStaleness:  tc.maxStaleness,
+				RepositoryURL: dir,
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			now := time.Now()
+			r.now = func() time.Time { return now }
+
+			revision, err := r.ResolveRevision(ctx, "giantswarm", "config", "master")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			_, err = r.AssembleConfigRepository(ctx, "giantswarm", "config", "master", revision)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			now = now.Add(tc.elapsed)
+
+			cause := tc.cause
+			if cause == nil {
+				cause = microerror.Mask(plumbing.NewUnexpectedError(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}))
+			}
+
+			store, err := r.LastKnownGood(ctx, "giantswarm", "config", "master", cause)
+
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if !store.Stale() {
+				t.Fatalf("stale = false, want true")
+			}
+			if store.Revision().Config != sha {
+				t.Fatalf("revision = %q, want %q", store.Revision().Config, sha)
+			}
+
+			content, err := store.ReadFile("default/config.yaml")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if string(content) != "config\n" {
+				t.Fatalf("content = %q, want %q", content, "config\n")
+			}
+		})
+	}
+}
+
+func Test_isUnreachable(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedResult bool
+	}{
+		{
+			name:           "case 0: dial error",
+			err:            microerror.Mask(plumbing.NewUnexpectedError(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})),
+			expectedResult: true,
+		},
+		{
+			name:           "case 1: DNS error",
+			err:            microerror.Mask(&net.DNSError{Err: "no such host", Name: "github.com"}),
+			expectedResult: true,
+		},
+		{
+			name:           "case 2: timeout",
+			err:            microerror.Mask(context.DeadlineExceeded),
+			expectedResult: true,
+		},
+		{
+			name:           "case 3: server error",
+			err:            microerror.Mask(httpError(http.StatusBadGateway)),
+			expectedResult: true,
+		},
+		{
+			name:           "case 4: unexpected client error",
+			err:            microerror.Mask(httpError(http.StatusTooManyRequests)),
+			expectedResult: false,
+		},
+		{
+			name:           "case 5: authentication failure",
+			err:            microerror.Mask(fmt.Errorf("%w: bad credentials", transport.ErrAuthenticationRequired)),
+			expectedResult: false,
+		},
+		{
+			name:           "case 6: authorization failure",
+			err:            microerror.Mask(fmt.Errorf("%w: forbidden", transport.ErrAuthorizationFailed)),
+			expectedResult: false,
+		},
+		{
+			name:           "case 7: missing reference",
+			err:            microerror.Mask(plumbing.ErrReferenceNotFound),
+			expectedResult: false,
+		},
+		{
+			name:           "case 8: missing commit",
+			err:            microerror.Mask(plumbing.ErrObjectNotFound),
+			expectedResult: false,
+		},
+		{
+			name:           "case 9: cancelled reconciliation",
+			err:            microerror.Mask(&url.Error{Op: "Get", URL: "https://github.com", Err: context.Canceled}),
+			expectedResult: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := isUnreachable(tc.err)
+			if result != tc.expectedResult {
+				t.Fatalf("result = %t, want %t", result, tc.expectedResult)
+			}
+		})
+	}
+}
+
+func httpError(statusCode int) error {
+	request := &http.Request{URL: &url.URL{Scheme: "https", Host: "github.com"}}
+	return plumbing.NewUnexpectedError(&githttp.Err{Response: &http.Response{StatusCode: statusCode, Request: request}})
+}
@@ -3,58 +3,188 @@ package gitrepo
 import (
 	"io"
 	"os"
+	"path"
+	"sort"
+	"strings"
 
 	"github.com/go-git/go-billy/v5"
 
 	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/config-controller/internal/shared"
 )
 
+// sharedDirs are the top-level directories of the shared configs repository
+// merged into the config repository.
+var sharedDirs = []string{"default", "include"}
+
+// layer is a shared configs repository overlaid onto the config repository.
+type layer struct {
+	// name of the repository including its owner.
+	name       string
+	fs         billy.Filesystem
+	repository shared.ConfigRepository
+}
+
 type Store struct {
 	fs billy.Filesystem
+	// name of the config repository including its owner.
+	name string
+	// shared are the layers of the shared configs repositories in overlay
+	// order. Their sharedDirs are merged into fs. Files of later layers
+	// take precedence over the ones of earlier layers with the same path
+	// and files of fs take precedence over all of them.
+	shared []layer
+	// overrides holds the paths of the files defined in more than one
+	// repository.
+	overrides []string
+	// revision holds the commit SHAs the files are checked out from.
+	revision Revision
+	// stale is set when the Store is served by LastKnownGood because the
+	// repositories are unreachable.
+	stale bool
+	// worktrees holds the directories of the on-disk cache worktrees the
+	// files are read from. They are pinned while the Store is kept.
+	worktrees []string
+}
+
+// Revision returns the commit SHAs the configuration is assembled from.
+func (s *Store) Revision() Revision {
+	return s.revision
+}
+
+// Stale returns true when the Store is the last known good configuration
+// served because the repositories are unreachable.
+func (s *Store) Stale() bool {
+	return s.stale
+}
+
+// Overrides returns the sorted paths of the files defined in more than one
+// repository. The files of the repository with the highest precedence are
+// used for them.
+func (s *Store) Overrides() []string {
+	return s.overrides
+}
+
+// Source returns the name of the repository the file is read from, e.g.
+// "giantswarm/shared-configs".
+func (s *Store) Source(path string) (string, error) {
+	layers, p := s.resolve(path)
+
+	for _, l := range layers {
+		_, err := l.fs.Stat(p)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return l.name, nil
+	}
+
+	return "", microerror.Maskf(notFoundError, "file %#q does not exist", path)
+}
+
+// resolve returns the layers holding the path in the order of precedence
+// and the path within them.
+func (s *Store) resolve(p string) ([]layer, string) {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+
+	layers := []layer{{name: s.name, fs: s.fs}}
+
+	dir, _, _ := strings.Cut(p, "/")
+	if isSharedDir(dir) {
+		for i := len(s.shared) - 1; i >= 0; i-- {
+			layers = append(layers, s.shared[i])
+		}
+	}
+
+	return layers, p
 }
 
 func (s *Store) ReadDir(dirpath string) ([]os.FileInfo, error) {
-	stat, err := s.fs.Stat(dirpath)
-	if os.IsNotExist(err) {
-		return nil, microerror.Maskf(notFoundError, "file %#q does not exist", dirpath)
-	} else if err != nil {
-		return nil, microerror.Mask(err)
+	layers, p := s.resolve(dirpath)
+
+	var found bool
+	byName := map[string]os.FileInfo{}
+	for _, l := range layers {
+		fs := l.fs
+
+		stat, err := fs.Stat(p)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if !stat.IsDir() {
+			return nil, microerror.Maskf(executionFailedError, "file %#q is not a directory", dirpath)
+		}
+		found = true
+
+		infos, err := fs.ReadDir(p)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		for _, info := range infos {
+			if _, ok := byName[info.Name()]; !ok {
+				byName[info.Name()] = info
+			}
+		}
 	}
-	if !stat.IsDir() {
-		return nil, microerror.Maskf(executionFailedError, "file %#q is not a directory", dirpath)
+
+	if !found {
+		return nil, microerror.Maskf(notFoundError, "file %#q does not exist", dirpath)
 	}
 
-	fs, err := s.fs.ReadDir(dirpath)
-	if err != nil {
-		return nil, microerror.Mask(err)
+	infos := make([]os.FileInfo, 0, len(byName))
+	for _, info := range byName {
+		infos = append(infos, info)
 	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
 
-	return fs, nil
+	return infos, nil
 }
 
 func (s *Store) ReadFile(path string) ([]byte, error) {
-	stat, err := s.fs.Stat(path)
-	if os.IsNotExist(err) {
-		return nil, microerror.Maskf(notFoundError, "file %#q does not exist", path)
-	} else if err != nil {
-		return nil, microerror.Mask(err)
-	}
-	if stat.IsDir() {
-		return nil, microerror.Maskf(executionFailedError, "file %#q is a directory", path)
-	}
+	layers, p := s.resolve(path)
+
+	for _, l := range layers {
+		fs := l.fs
+
+		stat, err := fs.Stat(p)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if stat.IsDir() {
+			return nil, microerror.Maskf(executionFailedError, "file %#q is a directory", path)
+		}
 
-	f, err := s.fs.Open(path)
-	if os.IsNotExist(err) {
-		return nil, microerror.Maskf(notFoundError, "file %#q does not exist", path)
-	} else if err != nil {
-		return nil, microerror.Mask(err)
+		f, err := fs.Open(p)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		defer func() { _ = f.Close() }()
+
+		bs, err := io.ReadAll(f)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return bs, nil
 	}
-	defer func() { _ = f.Close() }()
 
-	bs, err := io.ReadAll(f)
-	if err != nil {
-		return nil, microerror.Mask(err)
+	return nil, microerror.Maskf(notFoundError, "file %#q does not exist", path)
+}
+
+func isSharedDir(dir string) bool {
+	for _, d := range sharedDirs {
+		if d == dir {
+			return true
+		}
 	}
 
-	return bs, nil
+	return false
 }
@@ -0,0 +1,113 @@
+package gitrepo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestStore_overlay(t *testing.T) {
+	config := memfs.New()
+	writeMemFile(t, config, "default/config.yaml", "config")
+	writeMemFile(t, config, "installations/puma/config.yaml.patch", "patch")
+
+	shared := memfs.New()
+	writeMemFile(t, shared, "default/config.yaml", "shared")
+	writeMemFile(t, shared, "default/apps/foo/configmap-values.yaml.template", "foo")
+	writeMemFile(t, shared, "include/self.yaml", "include")
+	writeMemFile(t, shared, "installations/puma/config.yaml.patch", "ignored")
+
+	store := &Store{
+		fs:     config,
+		name:   "acme/acme-config",
+		shared: []layer{{name: "giantswarm/shared-configs", fs: shared}},
+	}
+
+	testCases := []struct {
+		name                 string
+		path                 string
+		expectedResult       string
+		expectedErrorMessage string
+	}{
+		{
+			name:           "case 0: config repository file takes precedence",
+			path:           "default/config.yaml",
+			expectedResult: "config",
+		},
+		{
+			name:           "case 1: read file from shared configs repository",
+			path:           "default/apps/foo/configmap-values.yaml.template",
+			expectedResult: "foo",
+		},
+		{
+			name:           "case 2: read shared directory missing in config repository",
+			path:           "include/self.yaml",
+			expectedResult: "include",
+		},
+		{
+			name:           "case 3: only default and include are merged",
+			path:           "installations/puma/config.yaml.patch",
+			expectedResult: "patch",
+		},
+		{
+			name:           "case 4: path is cleaned",
+			path:           "./installations/../default/config.yaml",
+			expectedResult: "config",
+		},
+		{
+			name:                 "case 5: missing file",
+			path:                 "default/missing.yaml",
+			expectedErrorMessage: "file `default/missing.yaml` does not exist",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := store.ReadFile(tc.path)
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if string(result) != tc.expectedResult {
+				t.Fatalf("result = %q, want %q", result, tc.expectedResult)
+			}
+		})
+	}
+
+	infos, err := store.ReadDir("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"apps", "config.yaml"}) {
+		t.Fatalf("names = %v, want [apps config.yaml]", names)
+	}
+}
+
+func writeMemFile(t *testing.T, fs billy.Filesystem, name, content string) {
+	t.Helper()
+
+	err := util.WriteFile(fs, name, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+}
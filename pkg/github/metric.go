@@ -0,0 +1,19 @@
+package github
+
+import (
+	"github.com/giantswarm/microerror"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/giantswarm/config-controller/pkg/github/internal/gitrepo"
+)
+
+// RegisterMetrics registers the metrics of the repository cache, e.g. the
+// staleness of the served configuration, with the registerer.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	err := gitrepo.RegisterMetrics(registerer)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
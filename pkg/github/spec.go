@@ -2,8 +2,16 @@ package github
 
 import (
 	"os"
+
+	"github.com/giantswarm/config-controller/pkg/github/internal/gitrepo"
 )
 
+// Revision holds the commit SHAs the configuration is assembled from.
+type Revision = gitrepo.Revision
+
+// Changes holds the paths changed between two revisions.
+type Changes = gitrepo.Changes
+
 type Store interface {
 	// ReadFile is similar to io/ioutil.ReadFile but it returns error
 	// matched by IsNotFound if the file does not exist.
@@ -11,4 +19,17 @@ type Store interface {
 	// ReadDir is similar to io/ioutil.ReadDir but it returns error matched
 	// by IsNotFound if the directory does not exist.
 	ReadDir(dirname string) ([]os.FileInfo, error)
+	// Revision returns the commit SHAs the files are checked out from.
+	Revision() Revision
+	// Overrides returns the paths of the files defined in more than one of
+	// the config repository and the shared configs repositories. The files
+	// of the config repository take precedence, followed by the shared
+	// configs repositories in reverse overlay order.
+	Overrides() []string
+	// Stale returns true when the Store is the last known good
+	// configuration served because the repositories are unreachable.
+	Stale() bool
+	// Source returns the name of the repository the file is read from,
+	// e.g. "giantswarm/shared-configs".
+	Source(path string) (string, error)
 }
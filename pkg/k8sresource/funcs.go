@@ -1,6 +1,11 @@
 package k8sresource
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/giantswarm/microerror"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -34,6 +39,20 @@ func SetAnnotation(o Object, key, val string) {
 	o.SetAnnotations(a)
 }
 
+// SetHash sets the annotation to the hash of the object. It is the same hash
+// EnsureCreated sets on created and updated objects.
+func SetHash(annotation string, o Object) error {
+	bytes, err := json.Marshal(o)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	sum := sha256.Sum256(bytes)
+	SetAnnotation(o, annotation, fmt.Sprintf("%x", sum))
+
+	return nil
+}
+
 func ObjectKey(o Object) client.ObjectKey {
 	return client.ObjectKey{
 		Namespace: o.GetNamespace(),
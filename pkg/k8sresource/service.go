@@ -2,8 +2,6 @@ package k8sresource
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
@@ -53,7 +51,7 @@ func New(config Config) (*Service, error) {
 func (s *Service) EnsureCreated(ctx context.Context, hashAnnotation string, desired client.Object) error {
 	s.logger.Debugf(ctx, "ensuring %#q %#q", s.Kind(desired), ObjectKey(desired))
 
-	err := setHash(hashAnnotation, desired)
+	err := SetHash(hashAnnotation, desired)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -250,15 +248,3 @@ func (s *Service) Kind(o Object) string {
 
 	return gvk.Kind
 }
-
-func setHash(annotation string, o Object) error {
-	bytes, err := json.Marshal(o)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	sum := sha256.Sum256(bytes)
-	SetAnnotation(o, annotation, fmt.Sprintf("%x", sum))
-
-	return nil
-}
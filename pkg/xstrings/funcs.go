@@ -1,5 +1,7 @@
 package xstrings
 
+import "strings"
+
 func CopyMap(m map[string]string) map[string]string {
 	n := make(map[string]string, len(m))
 	for k, v := range m {
@@ -7,3 +9,16 @@ func CopyMap(m map[string]string) map[string]string {
 	}
 	return n
 }
+
+// SplitList splits the comma separated list. Surrounding whitespace and
+// empty elements are dropped.
+func SplitList(s string) []string {
+	var l []string
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e != "" {
+			l = append(l, e)
+		}
+	}
+	return l
+}
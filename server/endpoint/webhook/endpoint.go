@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+
+	"github.com/giantswarm/config-controller/service/webhook"
+)
+
+const (
+	// Method is the HTTP method this endpoint is registered for.
+	Method = "POST"
+	// Name identifies the endpoint. It is aligned to the package path.
+	Name = "webhook"
+	// Path is the HTTP request path this endpoint is registered for.
+	Path = "/webhook"
+)
+
+// maxPayloadSize is the maximum size of webhook payloads GitHub delivers.
+const maxPayloadSize = 25 << 20
+
+// Config represents the configuration used to create a webhook endpoint.
+type Config struct {
+	Logger  micrologger.Logger
+	Service *webhook.Service
+}
+
+// New creates a new configured webhook endpoint. The endpoint receives
+// GitHub and GitLab push webhooks of the config repositories.
+func New(config Config) (*Endpoint, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Service == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Service must not be empty", config)
+	}
+
+	e := &Endpoint{
+		logger:  config.Logger,
+		service: config.Service,
+	}
+
+	return e, nil
+}
+
+type Endpoint struct {
+	logger  micrologger.Logger
+	service *webhook.Service
+}
+
+// Decoder authenticates the webhook and decodes its payload into
+// a *webhook.PushEvent. Events other than pushes decode to nil.
+func (e *Endpoint) Decoder() kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		payload, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		switch {
+		case r.Header.Get("X-GitHub-Event") != "":
+			err = e.service.ValidateGitHubSignature(payload, r.Header.Get("X-Hub-Signature-256"))
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			if r.Header.Get("X-GitHub-Event") != "push" {
+				return nil, nil
+			}
+
+			event, err := decodeGitHubPush(payload)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			return event, nil
+
+		case r.Header.Get("X-Gitlab-Event") != "":
+			err = e.service.ValidateGitLabToken(r.Header.Get("X-Gitlab-Token"))
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			if r.Header.Get("X-Gitlab-Event") != "Push Hook" && r.Header.Get("X-Gitlab-Event") != "Tag Push Hook" {
+				return nil, nil
+			}
+
+			event, err := decodeGitLabPush(payload)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			return event, nil
+		}
+
+		return nil, microerror.Maskf(invalidRequestError, "neither %#q nor %#q header is set", "X-GitHub-Event", "X-Gitlab-Event")
+	}
+}
+
+func (e *Endpoint) Encoder() kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		return json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (e *Endpoint) Endpoint() kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		event, ok := request.(*webhook.PushEvent)
+		if !ok || event == nil {
+			return webhook.PushResult{}, nil
+		}
+
+		result, err := e.service.Push(ctx, *event)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return result, nil
+	}
+}
+
+func (e *Endpoint) Method() string {
+	return Method
+}
+
+func (e *Endpoint) Middlewares() []kitendpoint.Middleware {
+	return []kitendpoint.Middleware{}
+}
+
+func (e *Endpoint) Name() string {
+	return Name
+}
+
+func (e *Endpoint) Path() string {
+	return Path
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/config-controller/service/webhook"
+)
+
+// gitHubMaxCommits is the maximum number of commits GitHub lists in push
+// webhook payloads.
+const gitHubMaxCommits = 2048
+
+type commit struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+type gitHubPush struct {
+	Ref     string   `json:"ref"`
+	Created bool     `json:"created"`
+	Deleted bool     `json:"deleted"`
+	Forced  bool     `json:"forced"`
+	Commits []commit `json:"commits"`
+
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+type gitLabPush struct {
+	Ref               string   `json:"ref"`
+	Before            string   `json:"before"`
+	After             string   `json:"after"`
+	Commits           []commit `json:"commits"`
+	TotalCommitsCount int      `json:"total_commits_count"`
+
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+func decodeGitHubPush(payload []byte) (*webhook.PushEvent, error) {
+	var p gitHubPush
+	err := json.Unmarshal(payload, &p)
+	if err != nil {
+		return nil, microerror.Maskf(invalidRequestError, "decoding GitHub push payload: %s", err)
+	}
+	if p.Repository.FullName == "" || p.Ref == "" {
+		return nil, microerror.Maskf(invalidRequestError, "GitHub push payload misses repository or ref")
+	}
+
+	// Created, deleted and force pushed references don't list all changed
+	// paths.
+	complete := !p.Created && !p.Deleted && !p.Forced &&
+		len(p.Commits) > 0 && len(p.Commits) < gitHubMaxCommits
+
+	event := &webhook.PushEvent{
+		Repository: p.Repository.FullName,
+		Ref:        p.Ref,
+		Paths:      paths(p.Commits),
+		Complete:   complete,
+	}
+
+	return event, nil
+}
+
+func decodeGitLabPush(payload []byte) (*webhook.PushEvent, error) {
+	var p gitLabPush
+	err := json.Unmarshal(payload, &p)
+	if err != nil {
+		return nil, microerror.Maskf(invalidRequestError, "decoding GitLab push payload: %s", err)
+	}
+	if p.Project.PathWithNamespace == "" || p.Ref == "" {
+		return nil, microerror.Maskf(invalidRequestError, "GitLab push payload misses project or ref")
+	}
+
+	// GitLab lists at most 20 commits. Created and deleted references are
+	// pushed with a zero SHA.
+	complete := len(p.Commits) > 0 && len(p.Commits) == p.TotalCommitsCount &&
+		!isZeroSHA(p.Before) && !isZeroSHA(p.After)
+
+	event := &webhook.PushEvent{
+		Repository: p.Project.PathWithNamespace,
+		Ref:        p.Ref,
+		Paths:      paths(p.Commits),
+		Complete:   complete,
+	}
+
+	return event, nil
+}
+
+func paths(commits []commit) []string {
+	var paths []string
+	for _, c := range commits {
+		paths = append(paths, c.Added...)
+		paths = append(paths, c.Modified...)
+		paths = append(paths, c.Removed...)
+	}
+
+	return paths
+}
+
+func isZeroSHA(sha string) bool {
+	return strings.Trim(sha, "0") == ""
+}
@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/config-controller/service/webhook"
+)
+
+func Test_decodePush(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		decode               func([]byte) (*webhook.PushEvent, error)
+		payload              string
+		expectedEvent        *webhook.PushEvent
+		expectedErrorMessage string
+	}{
+		{
+			name:   "case 0: GitHub push",
+			decode: decodeGitHubPush,
+			payload: `{
+				"ref": "refs/heads/main",
+				"repository": {"full_name": "giantswarm/config"},
+				"commits": [
+					{"added": ["default/apps/foo/metadata.yaml"], "modified": [], "removed": []},
+					{"added": [], "modified": ["installations/puma/config.yaml.patch"], "removed": ["include/a.yaml"]}
+				]
+			}`,
+			expectedEvent: &webhook.PushEvent{
+				Repository: "giantswarm/config",
+				Ref:        "refs/heads/main",
+				Paths: []string{
+					"default/apps/foo/metadata.yaml",
+					"installations/puma/config.yaml.patch",
+					"include/a.yaml",
+				},
+				Complete: true,
+			},
+		},
+		{
+			name:   "case 1: GitHub force push is incomplete",
+			decode: decodeGitHubPush,
+			payload: `{
+				"ref": "refs/heads/main",
+				"forced": true,
+				"repository": {"full_name": "giantswarm/config"},
+				"commits": [{"modified": ["default/config.yaml"]}]
+			}`,
+			expectedEvent: &webhook.PushEvent{
+				Repository: "giantswarm/config",
+				Ref:        "refs/heads/main",
+				Paths:      []string{"default/config.yaml"},
+			},
+		},
+		{
+			name:                 "case 2: GitHub payload without repository",
+			decode:               decodeGitHubPush,
+			payload:              `{"ref": "refs/heads/main"}`,
+			expectedErrorMessage: "GitHub push payload misses repository or ref",
+		},
+		{
+			name:   "case 3: GitLab push",
+			decode: decodeGitLabPush,
+			payload: `{
+				"ref": "refs/heads/main",
+				"before": "95790bf891e76fee5e1747ab589903a6a1f80f22",
+				"after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+				"project": {"path_with_namespace": "giantswarm/config"},
+				"total_commits_count": 1,
+				"commits": [{"modified": ["default/apps/foo/configmap-values.yaml.template"]}]
+			}`,
+			expectedEvent: &webhook.PushEvent{
+				Repository: "giantswarm/config",
+				Ref:        "refs/heads/main",
+				Paths:      []string{"default/apps/foo/configmap-values.yaml.template"},
+				Complete:   true,
+			},
+		},
+		{
+			name:   "case 4: GitLab truncated commits are incomplete",
+			decode: decodeGitLabPush,
+			payload: `{
+				"ref": "refs/heads/main",
+				"before": "95790bf891e76fee5e1747ab589903a6a1f80f22",
+				"after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+				"project": {"path_with_namespace": "giantswarm/config"},
+				"total_commits_count": 21,
+				"commits": [{"modified": ["default/apps/foo/configmap-values.yaml.template"]}]
+			}`,
+			expectedEvent: &webhook.PushEvent{
+				Repository: "giantswarm/config",
+				Ref:        "refs/heads/main",
+				Paths:      []string{"default/apps/foo/configmap-values.yaml.template"},
+			},
+		},
+		{
+			name:                 "case 5: GitLab invalid JSON",
+			decode:               decodeGitLabPush,
+			payload:              `{`,
+			expectedErrorMessage: "decoding GitLab push payload",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event, err := tc.decode([]byte(tc.payload))
+
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+
+			if !reflect.DeepEqual(event, tc.expectedEvent) {
+				t.Fatalf("event = %#v, want %#v", event, tc.expectedEvent)
+			}
+		})
+	}
+}
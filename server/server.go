@@ -14,7 +14,9 @@ import (
 
 	"github.com/giantswarm/config-controller/pkg/project"
 	"github.com/giantswarm/config-controller/server/endpoint"
+	endpointwebhook "github.com/giantswarm/config-controller/server/endpoint/webhook"
 	"github.com/giantswarm/config-controller/service"
+	"github.com/giantswarm/config-controller/service/webhook"
 )
 
 type Config struct {
@@ -52,6 +54,7 @@ func New(config Config) (microserver.Server, error) {
 			Endpoints: []microserver.Endpoint{
 				endpointCollection.Healthz,
 				endpointCollection.Version,
+				endpointCollection.Webhook,
 			},
 			ErrorEncoder: encodeError,
 		},
@@ -91,7 +94,18 @@ func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
 	rErr := err.(microserver.ResponseError)
 	uErr := rErr.Underlying()
 
-	rErr.SetCode(microserver.CodeInternalError)
-	rErr.SetMessage(uErr.Error())
-	w.WriteHeader(http.StatusInternalServerError)
+	switch {
+	case webhook.IsInvalidSignature(uErr):
+		rErr.SetCode(microserver.CodePermissionDenied)
+		rErr.SetMessage(uErr.Error())
+		w.WriteHeader(http.StatusUnauthorized)
+	case endpointwebhook.IsInvalidRequest(uErr):
+		rErr.SetCode(microserver.CodeInvalidInput)
+		rErr.SetMessage(uErr.Error())
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		rErr.SetCode(microserver.CodeInternalError)
+		rErr.SetMessage(uErr.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
 }
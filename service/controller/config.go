@@ -1,6 +1,9 @@
 package controller
 
 import (
+	"context"
+	"time"
+
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
@@ -14,34 +17,63 @@ import (
 
 	"github.com/giantswarm/config-controller/api/v1alpha1"
 	"github.com/giantswarm/config-controller/internal/meta"
+	"github.com/giantswarm/config-controller/pkg/generator"
+	"github.com/giantswarm/config-controller/pkg/github"
 	"github.com/giantswarm/config-controller/pkg/project"
 	"github.com/giantswarm/config-controller/service/controller/handler/configuration"
 
+	"github.com/giantswarm/config-controller/internal/githubapp"
 	"github.com/giantswarm/config-controller/internal/ssh"
 )
 
 type ConfigConfig struct {
-	K8sClient   k8sclient.Interface
-	Logger      micrologger.Logger
-	VaultClient *vaultapi.Client
-
-	SharedConfigRepository  shared.ConfigRepository
-	ConfigRepoSSHCredential ssh.Credential
-	GitHubToken             string
-	RepositoryName          string
-	RepositoryRef           string
-	Installation            string
-	UniqueApp               bool
+	// DecryptTraverser decrypts secret files. When it is not set, secrets
+	// are decrypted with Vault using VaultClient.
+	DecryptTraverser generator.DecryptTraverser
+	K8sClient        k8sclient.Interface
+	Logger           micrologger.Logger
+	VaultClient      *vaultapi.Client
+
+	SharedConfigRepositories []shared.ConfigRepository
+	ConfigRepoSSHCredential  ssh.Credential
+	GitCacheDir              string
+	GitHost                  string
+	GitMaxStaleness          time.Duration
+	GitHubApp                githubapp.Credential
+	GitHubToken              string
+	RepositoryOwner          string
+	RepositoryName           string
+	RepositoryRef            string
+	RepositoryURL            string
+	RepositoryUsername       string
+	RepositoryToken          string
+	SSHKnownHosts            string
+	TrustedGPGKeys           string
+	TrustedSSHKeys           string
+	Installation             string
+	PlaintextSecretCheck     string
+	PluginCommand            string
+	PluginPrefix             string
+	UniqueApp                bool
+	VaultTransitKey          string
+	// VaultKVAllowedPathPrefixes are the Vault paths Vault KV references
+	// may read from.
+	VaultKVAllowedPathPrefixes []string
+	// K8sSecretAllowedNamespaces are the namespaces Secret references may
+	// read from in addition to the Config CR namespace.
+	K8sSecretAllowedNamespaces []string
 }
 
 type Config struct {
 	*controller.Controller
+
+	configurationHandler *configuration.Handler
 }
 
 func NewConfig(config ConfigConfig) (*Config, error) {
 	var err error
 
-	resources, err := newConfigHandlers(config)
+	configurationHandler, resources, err := newConfigHandlers(config)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -75,34 +107,94 @@ func NewConfig(config ConfigConfig) (*Config, error) {
 
 	c := &Config{
 		Controller: operatorkitController,
+
+		configurationHandler: configurationHandler,
 	}
 
 	return c, nil
 }
 
-func newConfigHandlers(config ConfigConfig) ([]resource.Interface, error) {
+// InvalidateCache drops the cached revisions of the configuration
+// repositories, so the next reconciliations use the latest commits.
+func (c *Config) InvalidateCache() {
+	c.configurationHandler.InvalidateCache()
+}
+
+// ResolveRevision resolves the references of the configuration
+// repositories to commit SHAs.
+func (c *Config) ResolveRevision(ctx context.Context) (github.Revision, error) {
+	revision, err := c.configurationHandler.ResolveRevision(ctx)
+	if err != nil {
+		return github.Revision{}, microerror.Mask(err)
+	}
+
+	return revision, nil
+}
+
+// PinnedSharedConfigRepositories returns the shared configs repositories with the
+// refs pinned at the current commit of the config repository.
+func (c *Config) PinnedSharedConfigRepositories(ctx context.Context) ([]shared.ConfigRepository, error) {
+	repositories, err := c.configurationHandler.PinnedSharedConfigRepositories(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return repositories, nil
+}
+
+// ChangedPaths returns the paths changed in the configuration repositories
+// between the revisions.
+func (c *Config) ChangedPaths(ctx context.Context, from, to github.Revision) (github.Changes, error) {
+	changes, err := c.configurationHandler.ChangedPaths(ctx, from, to)
+	if err != nil {
+		return github.Changes{}, microerror.Mask(err)
+	}
+
+	return changes, nil
+}
+
+func newConfigHandlers(config ConfigConfig) (*configuration.Handler, []resource.Interface, error) {
 	var err error
 
-	var configurationHandler resource.Interface
+	var configurationHandler *configuration.Handler
 	{
 		c := configuration.Config{
 			Logger: config.Logger,
 
-			K8sClient:   config.K8sClient,
-			VaultClient: config.VaultClient,
-
-			SharedConfigRepository:  config.SharedConfigRepository,
-			ConfigRepoSSHCredential: config.ConfigRepoSSHCredential,
-			GitHubToken:             config.GitHubToken,
-			RepositoryName:          config.RepositoryName,
-			RepositoryRef:           config.RepositoryRef,
-			Installation:            config.Installation,
-			UniqueApp:               config.UniqueApp,
+			DecryptTraverser: config.DecryptTraverser,
+			K8sClient:        config.K8sClient,
+			VaultClient:      config.VaultClient,
+
+			SharedConfigRepositories: config.SharedConfigRepositories,
+			ConfigRepoSSHCredential:  config.ConfigRepoSSHCredential,
+			GitCacheDir:              config.GitCacheDir,
+			GitHost:                  config.GitHost,
+			GitMaxStaleness:          config.GitMaxStaleness,
+			GitHubApp:                config.GitHubApp,
+			GitHubToken:              config.GitHubToken,
+			RepositoryOwner:          config.RepositoryOwner,
+			RepositoryName:           config.RepositoryName,
+			RepositoryRef:            config.RepositoryRef,
+			RepositoryURL:            config.RepositoryURL,
+			RepositoryUsername:       config.RepositoryUsername,
+			RepositoryToken:          config.RepositoryToken,
+			SSHKnownHosts:            config.SSHKnownHosts,
+			TrustedGPGKeys:           config.TrustedGPGKeys,
+			TrustedSSHKeys:           config.TrustedSSHKeys,
+			Installation:             config.Installation,
+			PlaintextSecretCheck:     config.PlaintextSecretCheck,
+			PluginCommand:            config.PluginCommand,
+			PluginPrefix:             config.PluginPrefix,
+			UniqueApp:                config.UniqueApp,
+			VaultTransitKey:          config.VaultTransitKey,
+
+			K8sSecretAllowedNamespaces: config.K8sSecretAllowedNamespaces,
+			VaultKVAllowedPathPrefixes: config.VaultKVAllowedPathPrefixes,
 		}
 
 		configurationHandler, err = configuration.New(c)
 		if err != nil {
-			return nil, microerror.Mask(err)
+			return nil, nil, microerror.Mask(err)
 		}
 	}
 
@@ -115,9 +207,9 @@ func newConfigHandlers(config ConfigConfig) ([]resource.Interface, error) {
 
 		handlers, err = metricsresource.Wrap(handlers, c)
 		if err != nil {
-			return nil, microerror.Mask(err)
+			return nil, nil, microerror.Mask(err)
 		}
 	}
 
-	return handlers, nil
+	return configurationHandler, handlers, nil
 }
@@ -2,8 +2,6 @@ package configuration
 
 import (
 	"context"
-	"crypto/sha1" // nolint:gosec
-	"encoding/json"
 	"fmt"
 	"reflect"
 
@@ -25,41 +23,28 @@ func (h *Handler) EnsureCreated(ctx context.Context, obj interface{}) error {
 
 	var configmap *corev1.ConfigMap
 	var secret *corev1.Secret
+	var stale bool
 	{
-		name, err := genStableObjectName(config)
+		generateIn, err := generator.NewGenerateInputFromConfig(config, h.installation, h.uniqueApp)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
-		namespace := config.Namespace
-
-		generateIn := generator.GenerateInput{
-			App: config.Spec.App.Name,
-
-			Name:      name,
-			Namespace: namespace,
-
-			ExtraAnnotations: map[string]string{
-				meta.Annotation.XAppInfo.Key():        meta.Annotation.ValFromConfig(config),
-				meta.Annotation.XInstallation.Key():   h.installation,
-				meta.Annotation.XProjectVersion.Key(): meta.Annotation.XProjectVersion.Val(h.uniqueApp),
-			},
-			ExtraLabels: map[string]string{
-				meta.Label.ManagedBy.Key(): meta.Label.Default(),
-			},
-		}
-
-		nn := namespace + "/" + name
+		nn := generateIn.Namespace + "/" + generateIn.Name
 		rr := h.repositoryName + "@" + h.repositoryRef
 
 		h.logger.Debugf(ctx, "generating %#q ConfigMap and Secret from the %#q configuration", nn, rr)
 
-		configmap, secret, err = h.generator.Generate(ctx, generateIn)
+		configmap, secret, stale, err = h.generator.Generate(ctx, generateIn)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
-		h.logger.Debugf(ctx, "generated %#q ConfigMap and Secret from the %#q configuration", nn, rr)
+		h.logger.Debugf(ctx, "generated %#q ConfigMap and Secret from the %#q configuration at commit %#q", nn, rr, configmap.Annotations[meta.Annotation.XConfigCommit.Key()])
+
+		if stale {
+			h.logger.LogCtx(ctx, "level", "warning", "message", fmt.Sprintf("generated %#q ConfigMap and Secret from the last known good %#q configuration because the repositories are unreachable", nn, rr))
+		}
 	}
 
 	// Ensure ConfigMap and Secret.
@@ -84,9 +69,9 @@ func (h *Handler) EnsureCreated(ctx context.Context, obj interface{}) error {
 	}
 
 	// Update Config CR status.
-	// Setting the version to the branch ref is purely informational, unless someone
-	// reconfigures the Config Controller to use a different branch than one configured
-	// so far.
+	// The version is set to the configured branch ref, while the revision
+	// records the exact commits of the config and shared configs
+	// repositories the ConfigMap and Secret were generated from.
 	{
 		h.logger.Debugf(ctx, "updating Config status")
 
@@ -96,6 +81,9 @@ func (h *Handler) EnsureCreated(ctx context.Context, obj interface{}) error {
 		desiredStatus.Config.ConfigMapRef.Namespace = configmap.Namespace
 		desiredStatus.Config.SecretRef.Name = secret.Name
 		desiredStatus.Config.SecretRef.Namespace = secret.Namespace
+		desiredStatus.Revision.Config = configmap.Annotations[meta.Annotation.XConfigCommit.Key()]
+		desiredStatus.Revision.SharedConfig = configmap.Annotations[meta.Annotation.XSharedConfigCommit.Key()]
+		desiredStatus.Revision.Stale = stale
 		desiredStatus.Version = h.repositoryRef
 
 		if reflect.DeepEqual(config.Status, desiredStatus) {
@@ -187,32 +175,3 @@ func (h *Handler) cleanupOrphanedConfig(ctx context.Context, config *v1alpha1.Co
 
 	return c, nil
 }
-
-func genStableObjectName(config *v1alpha1.Config) (string, error) {
-	h, err := hash(config.Spec.App)
-	if err != nil {
-		return "", microerror.Mask(err)
-	}
-
-	return setSuffixMax63(config.Name, h), nil
-}
-
-func hash(v interface{}) (string, error) {
-	bs, err := json.Marshal(v)
-	if err != nil {
-		return "", microerror.Mask(err)
-	}
-
-	sum := sha1.Sum(bs) // nolint:gosec
-	return fmt.Sprintf("%x", sum)[:10], nil
-}
-
-func setSuffixMax63(s string, suffix string) string {
-	maxLen := 63
-
-	if len(s)+len(suffix)+1 <= maxLen {
-		return s + "-" + suffix
-	}
-
-	return s[:maxLen-len(suffix)-1] + "-" + suffix
-}
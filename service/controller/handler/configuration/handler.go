@@ -1,7 +1,9 @@
 package configuration
 
 import (
+	"context"
 	"reflect"
+	"time"
 
 	"github.com/giantswarm/config-controller/internal/shared"
 
@@ -15,8 +17,11 @@ import (
 
 	"github.com/giantswarm/config-controller/api/v1alpha1"
 	"github.com/giantswarm/config-controller/internal/generator"
+	"github.com/giantswarm/config-controller/internal/githubapp"
 	"github.com/giantswarm/config-controller/internal/meta"
 	"github.com/giantswarm/config-controller/internal/ssh"
+	pkggenerator "github.com/giantswarm/config-controller/pkg/generator"
+	"github.com/giantswarm/config-controller/pkg/github"
 	"github.com/giantswarm/config-controller/pkg/k8sresource"
 )
 
@@ -27,16 +32,38 @@ const (
 type Config struct {
 	Logger micrologger.Logger
 
-	K8sClient   k8sclient.Interface
-	VaultClient *vaultapi.Client
-
-	SharedConfigRepository  shared.ConfigRepository
-	ConfigRepoSSHCredential ssh.Credential
-	GitHubToken             string
-	RepositoryName          string
-	RepositoryRef           string
-	Installation            string
-	UniqueApp               bool
+	DecryptTraverser pkggenerator.DecryptTraverser
+	K8sClient        k8sclient.Interface
+	VaultClient      *vaultapi.Client
+
+	SharedConfigRepositories []shared.ConfigRepository
+	ConfigRepoSSHCredential  ssh.Credential
+	GitCacheDir              string
+	GitHost                  string
+	GitMaxStaleness          time.Duration
+	GitHubApp                githubapp.Credential
+	GitHubToken              string
+	RepositoryOwner          string
+	RepositoryName           string
+	RepositoryRef            string
+	RepositoryURL            string
+	RepositoryUsername       string
+	RepositoryToken          string
+	SSHKnownHosts            string
+	TrustedGPGKeys           string
+	TrustedSSHKeys           string
+	Installation             string
+	PlaintextSecretCheck     string
+	PluginCommand            string
+	PluginPrefix             string
+	UniqueApp                bool
+	VaultTransitKey          string
+	// VaultKVAllowedPathPrefixes are the Vault paths Vault KV references
+	// may read from.
+	VaultKVAllowedPathPrefixes []string
+	// K8sSecretAllowedNamespaces are the namespaces Secret references may
+	// read from in addition to the Config CR namespace.
+	K8sSecretAllowedNamespaces []string
 }
 
 type Handler struct {
@@ -61,12 +88,12 @@ func New(config Config) (*Handler, error) {
 	if config.K8sClient == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
 	}
-	if config.VaultClient == nil {
-		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	if config.DecryptTraverser == nil && config.VaultClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.DecryptTraverser or %T.VaultClient must not be empty", config, config)
 	}
 
-	if config.GitHubToken == "" && config.ConfigRepoSSHCredential.IsEmpty() {
-		return nil, microerror.Maskf(invalidConfigError, "%T.GitHubToken or %T.ConfigRepoSSHCredential must not be empty", config, config)
+	if config.GitHubToken == "" && config.GitHubApp.IsEmpty() && config.ConfigRepoSSHCredential.IsEmpty() && config.RepositoryURL == "" && config.RepositoryToken == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.GitHubToken, %T.GitHubApp, %T.ConfigRepoSSHCredential, %T.RepositoryURL or %T.RepositoryToken must not be empty", config, config, config, config, config)
 	}
 	if config.Installation == "" {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Installation must not be empty", config)
@@ -80,14 +107,35 @@ func New(config Config) (*Handler, error) {
 	var gen *generator.Service
 	{
 		c := generator.Config{
-			VaultClient: config.VaultClient,
-
-			SharedConfigRepository:  config.SharedConfigRepository,
-			ConfigRepoSSHCredential: config.ConfigRepoSSHCredential,
-			GitHubToken:             config.GitHubToken,
-			RepositoryName:          config.RepositoryName,
-			RepositoryRef:           config.RepositoryRef,
-			Installation:            config.Installation,
+			Log:              config.Logger,
+			DecryptTraverser: config.DecryptTraverser,
+			K8sClient:        config.K8sClient.CtrlClient(),
+			VaultClient:      config.VaultClient,
+
+			SharedConfigRepositories: config.SharedConfigRepositories,
+			ConfigRepoSSHCredential:  config.ConfigRepoSSHCredential,
+			GitCacheDir:              config.GitCacheDir,
+			GitHost:                  config.GitHost,
+			GitMaxStaleness:          config.GitMaxStaleness,
+			GitHubApp:                config.GitHubApp,
+			GitHubToken:              config.GitHubToken,
+			RepositoryOwner:          config.RepositoryOwner,
+			RepositoryName:           config.RepositoryName,
+			RepositoryRef:            config.RepositoryRef,
+			RepositoryURL:            config.RepositoryURL,
+			RepositoryUsername:       config.RepositoryUsername,
+			RepositoryToken:          config.RepositoryToken,
+			SSHKnownHosts:            config.SSHKnownHosts,
+			TrustedGPGKeys:           config.TrustedGPGKeys,
+			TrustedSSHKeys:           config.TrustedSSHKeys,
+			Installation:             config.Installation,
+			PlaintextSecretCheck:     config.PlaintextSecretCheck,
+			PluginCommand:            config.PluginCommand,
+			PluginPrefix:             config.PluginPrefix,
+			VaultTransitKey:          config.VaultTransitKey,
+
+			K8sSecretAllowedNamespaces: config.K8sSecretAllowedNamespaces,
+			VaultKVAllowedPathPrefixes: config.VaultKVAllowedPathPrefixes,
 		}
 
 		gen, err = generator.New(c)
@@ -127,6 +175,45 @@ func New(config Config) (*Handler, error) {
 	return h, nil
 }
 
+// InvalidateCache drops the cached revisions of the configuration
+// repositories of the generator.
+func (h *Handler) InvalidateCache() {
+	h.generator.InvalidateCache()
+}
+
+// ResolveRevision resolves the references of the configuration
+// repositories to commit SHAs.
+func (h *Handler) ResolveRevision(ctx context.Context) (github.Revision, error) {
+	revision, err := h.generator.ResolveRevision(ctx)
+	if err != nil {
+		return github.Revision{}, microerror.Mask(err)
+	}
+
+	return revision, nil
+}
+
+// PinnedSharedConfigRepositories returns the shared configs repositories with the
+// refs pinned at the current commit of the config repository.
+func (h *Handler) PinnedSharedConfigRepositories(ctx context.Context) ([]shared.ConfigRepository, error) {
+	repositories, err := h.generator.PinnedSharedConfigRepositories(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return repositories, nil
+}
+
+// ChangedPaths returns the paths changed in the configuration repositories
+// between the revisions.
+func (h *Handler) ChangedPaths(ctx context.Context, from, to github.Revision) (github.Changes, error) {
+	changes, err := h.generator.ChangedPaths(ctx, from, to)
+	if err != nil {
+		return github.Changes{}, microerror.Mask(err)
+	}
+
+	return changes, nil
+}
+
 func (h *Handler) Name() string {
 	return Name
 }
@@ -0,0 +1,167 @@
+// Package poller polls the config repositories for new commits and requeues
+// the Config CRs whose configuration they change. It serves setups without
+// push webhooks.
+package poller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/config-controller/internal/affected"
+	"github.com/giantswarm/config-controller/pkg/github"
+)
+
+// RevisionSource resolves the references of the config repositories and
+// compares the resolved revisions.
+type RevisionSource interface {
+	ResolveRevision(ctx context.Context) (github.Revision, error)
+	ChangedPaths(ctx context.Context, from, to github.Revision) (github.Changes, error)
+}
+
+// Requeuer triggers the reconciliation of the Config CRs of the apps or of
+// all Config CRs.
+type Requeuer interface {
+	Requeue(ctx context.Context, apps []string, all bool) (int, error)
+}
+
+type Config struct {
+	Logger   micrologger.Logger
+	Requeuer Requeuer
+	Source   RevisionSource
+
+	Installation string
+	// Interval is the time between two polls.
+	Interval time.Duration
+}
+
+type Poller struct {
+	logger   micrologger.Logger
+	requeuer Requeuer
+	source   RevisionSource
+
+	installation string
+	interval     time.Duration
+
+	// revision is the last revision the affected Config CRs were requeued
+	// for. It is nil until the first poll.
+	revision *github.Revision
+}
+
+func New(config Config) (*Poller, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Requeuer == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Requeuer must not be empty", config)
+	}
+	if config.Source == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Source must not be empty", config)
+	}
+	if config.Interval <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Interval must be positive", config)
+	}
+
+	p := &Poller{
+		logger:   config.Logger,
+		requeuer: config.Requeuer,
+		source:   config.Source,
+
+		installation: config.Installation,
+		interval:     config.Interval,
+	}
+
+	return p, nil
+}
+
+// Run polls the config repositories every interval until the context is
+// cancelled.
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		err := p.Poll(ctx)
+		if err != nil {
+			p.logger.Errorf(ctx, err, "failed to poll config repositories")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll resolves the references of the config repositories and requeues the
+// Config CRs affected by the paths changed since the last poll. All Config
+// CRs are requeued when the changed paths can't be determined, e.g. without
+// the on-disk repository cache or after a force push. The first poll only
+// records the revision, as all Config CRs are reconciled on start anyway.
+func (p *Poller) Poll(ctx context.Context) error {
+	revision, err := p.source.ResolveRevision(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if p.revision == nil {
+		p.revision = &revision
+		return nil
+	}
+	if reflect.DeepEqual(*p.revision, revision) {
+		return nil
+	}
+
+	p.logger.Debugf(ctx, "config repositories moved from %#q to %#q", revisionString(*p.revision), revisionString(revision))
+
+	var apps []string
+	var all bool
+	changes, err := p.source.ChangedPaths(ctx, *p.revision, revision)
+	if err != nil {
+		p.logger.Debugf(ctx, "requeuing all Config CRs, changed paths unknown: %s", err)
+		all = true
+	} else {
+		configApps, configAll := affected.Apps(p.installation, changes.Config)
+		sharedApps, sharedAll := affected.SharedApps(p.installation, changes.Shared)
+
+		apps = union(configApps, sharedApps)
+		all = configAll || sharedAll
+	}
+
+	_, err = p.requeuer.Requeue(ctx, apps, all)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	p.revision = &revision
+
+	return nil
+}
+
+func revisionString(revision github.Revision) string {
+	s := revision.Config
+	for _, sha := range revision.Shared {
+		s += "," + sha
+	}
+
+	return s
+}
+
+func union(a, b []string) []string {
+	seen := map[string]bool{}
+	var apps []string
+	for _, app := range append(append([]string{}, a...), b...) {
+		if !seen[app] {
+			seen[app] = true
+			apps = append(apps, app)
+		}
+	}
+	sort.Strings(apps)
+
+	return apps
+}
@@ -0,0 +1,137 @@
+package poller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/config-controller/pkg/github"
+)
+
+type source struct {
+	revision github.Revision
+	changes  github.Changes
+	err      error
+}
+
+func (s *source) ResolveRevision(ctx context.Context) (github.Revision, error) {
+	return s.revision, nil
+}
+
+func (s *source) ChangedPaths(ctx context.Context, from, to github.Revision) (github.Changes, error) {
+	return s.changes, s.err
+}
+
+type requeuer struct {
+	calls int
+	apps  []string
+	all   bool
+}
+
+func (r *requeuer) Requeue(ctx context.Context, apps []string, all bool) (int, error) {
+	r.calls++
+	r.apps = apps
+	r.all = all
+	return len(apps), nil
+}
+
+func TestPoller_Poll(t *testing.T) {
+	testCases := []struct {
+		name          string
+		revision      github.Revision
+		changes       github.Changes
+		err           error
+		expectedCalls int
+		expectedApps  []string
+		expectedAll   bool
+	}{
+		{
+			name:     "case 0: unchanged revision",
+			revision: github.Revision{Config: "a", Shared: []string{"b"}},
+		},
+		{
+			name:     "case 1: apps changed in config and shared configs repositories",
+			revision: github.Revision{Config: "c", Shared: []string{"d"}},
+			changes: github.Changes{
+				Config: []string{
+					"installations/puma/apps/foo/configmap-values.yaml.patch",
+					"installations/lion/config.yaml.patch",
+				},
+				Shared: []string{
+					"default/apps/bar/configmap-values.yaml.template",
+					"default/apps/foo/metadata.yaml",
+				},
+			},
+			expectedCalls: 1,
+			expectedApps:  []string{"bar", "foo"},
+		},
+		{
+			name:     "case 2: include changed",
+			revision: github.Revision{Config: "a", Shared: []string{"d"}},
+			changes: github.Changes{
+				Shared: []string{"include/common.yaml.template"},
+			},
+			expectedCalls: 1,
+			expectedAll:   true,
+		},
+		{
+			name:          "case 3: changed paths unknown",
+			revision:      github.Revision{Config: "c", Shared: []string{"b"}},
+			err:           microerror.Mask(invalidConfigError),
+			expectedCalls: 1,
+			expectedAll:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			s := &source{
+				revision: github.Revision{Config: "a", Shared: []string{"b"}},
+			}
+			r := &requeuer{}
+
+			p, err := New(Config{
+				Logger:   microloggertest.New(),
+				Requeuer: r,
+				Source:   s,
+
+				Installation: "puma",
+				Interval:     time.Minute,
+			})
+			if err != nil {
+				t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+			}
+
+			// The first poll records the revision.
+			err = p.Poll(ctx)
+			if err != nil {
+				t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+			}
+
+			s.revision = tc.revision
+			s.changes = tc.changes
+			s.err = tc.err
+
+			err = p.Poll(ctx)
+			if err != nil {
+				t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+			}
+
+			if r.calls != tc.expectedCalls {
+				t.Fatalf("requeue calls = %d, want %d", r.calls, tc.expectedCalls)
+			}
+			if !reflect.DeepEqual(r.apps, tc.expectedApps) {
+				t.Fatalf("apps = %v, want %v", r.apps, tc.expectedApps)
+			}
+			if r.all != tc.expectedAll {
+				t.Fatalf("all = %t, want %t", r.all, tc.expectedAll)
+			}
+		})
+	}
+}
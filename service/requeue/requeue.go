@@ -0,0 +1,109 @@
+// Package requeue triggers the reconciliation of Config CRs whose
+// configuration changed in the config repositories.
+package requeue
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/config-controller/api/v1alpha1"
+	"github.com/giantswarm/config-controller/internal/meta"
+)
+
+type Config struct {
+	K8sClient k8sclient.Interface
+	Logger    micrologger.Logger
+
+	UniqueApp bool
+}
+
+type Requeuer struct {
+	k8sClient k8sclient.Interface
+	logger    micrologger.Logger
+
+	uniqueApp bool
+}
+
+func New(config Config) (*Requeuer, error) {
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	r := &Requeuer{
+		k8sClient: config.K8sClient,
+		logger:    config.Logger,
+
+		uniqueApp: config.UniqueApp,
+	}
+
+	return r, nil
+}
+
+// Requeue triggers the reconciliation of the Config CRs of the apps, or of
+// all Config CRs reconciled by this controller when all is true. Config
+// CRs are requeued by setting the XRequeuedAt annotation. It returns the
+// number of requeued Config CRs.
+func (r *Requeuer) Requeue(ctx context.Context, apps []string, all bool) (int, error) {
+	if !all && len(apps) == 0 {
+		return 0, nil
+	}
+
+	selector, err := meta.Label.Selector(r.uniqueApp)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	var list v1alpha1.ConfigList
+	err = r.k8sClient.CtrlClient().List(ctx, &list, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	byName := map[string]bool{}
+	for _, app := range apps {
+		byName[app] = true
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	var requeued int
+	for i := range list.Items {
+		config := &list.Items[i]
+		if !all && !byName[config.Spec.App.Name] {
+			continue
+		}
+
+		patch := client.MergeFrom(config.DeepCopy())
+
+		annotations := config.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[meta.Annotation.XRequeuedAt.Key()] = now
+		config.SetAnnotations(annotations)
+
+		err = r.k8sClient.CtrlClient().Patch(ctx, config, patch)
+		if err != nil {
+			return requeued, microerror.Mask(err)
+		}
+
+		requeued++
+	}
+
+	if all {
+		r.logger.Debugf(ctx, "requeued %d Config CRs", requeued)
+	} else {
+		r.logger.Debugf(ctx, "requeued %d Config CRs of apps %s", requeued, strings.Join(apps, ", "))
+	}
+
+	return requeued, nil
+}
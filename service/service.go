@@ -4,6 +4,8 @@ package service
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/giantswarm/config-controller/internal/shared"
@@ -15,16 +17,31 @@ import (
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
 	"k8s.io/client-go/rest"
 
 	corev1alpha1 "github.com/giantswarm/config-controller/api/v1alpha1"
 	"github.com/giantswarm/config-controller/flag"
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+	"github.com/giantswarm/config-controller/pkg/generator"
+	"github.com/giantswarm/config-controller/pkg/github"
 	"github.com/giantswarm/config-controller/pkg/project"
+	"github.com/giantswarm/config-controller/pkg/xstrings"
 	"github.com/giantswarm/config-controller/service/collector"
 	"github.com/giantswarm/config-controller/service/controller"
+	"github.com/giantswarm/config-controller/service/poller"
+	"github.com/giantswarm/config-controller/service/requeue"
+	"github.com/giantswarm/config-controller/service/webhook"
 
+	"github.com/giantswarm/config-controller/internal/githubapp"
 	"github.com/giantswarm/config-controller/internal/ssh"
+	"github.com/giantswarm/config-controller/internal/vault"
+)
+
+const (
+	decryptBackendSOPS  = "sops"
+	decryptBackendVault = "vault"
 )
 
 // Config represents the configuration used to create a new service.
@@ -37,10 +54,18 @@ type Config struct {
 
 type Service struct {
 	Version *version.Service
+	Webhook *webhook.Service
 
 	bootOnce          sync.Once
 	configController  *controller.Config
 	operatorCollector *collector.Set
+	// poller is nil unless the git repositories are polled.
+	poller *poller.Poller
+
+	// vaultAuthenticator is nil unless the Vault client logs in with the
+	// Kubernetes or AppRole auth method.
+	vaultAuthenticator *vault.Authenticator
+	vaultLoginSecret   *vaultapi.Secret
 }
 
 // New creates a new configured service object.
@@ -66,6 +91,15 @@ func New(config Config) (*Service, error) {
 
 	var err error
 
+	err = decrypt.RegisterMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	err = github.RegisterMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
 	var restConfig *rest.Config
 	{
 		c := k8srestconfig.Config{
@@ -105,14 +139,40 @@ func New(config Config) (*Service, error) {
 	}
 
 	var vaultClient *vaultapi.Client
-	{
+	var vaultAuthenticator *vault.Authenticator
+	var vaultLoginSecret *vaultapi.Secret
+	var decryptTraverser generator.DecryptTraverser
+	switch backend := config.Viper.GetString(config.Flag.Service.Decrypt.Backend); backend {
+	case decryptBackendVault, "":
 		c := vaultapi.DefaultConfig()
 		c.Address = config.Viper.GetString(config.Flag.Service.Vault.Address)
 		vaultClient, err = vaultapi.NewClient(c)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
-		vaultClient.SetToken(config.Viper.GetString(config.Flag.Service.Vault.Token))
+
+		switch method := config.Viper.GetString(config.Flag.Service.Vault.Auth.Method); method {
+		case vault.AuthMethodToken, "":
+			vaultClient.SetToken(config.Viper.GetString(config.Flag.Service.Vault.Token))
+		default:
+			vaultAuthenticator, err = newVaultAuthenticator(config, vaultClient)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			// Log in right away so misconfiguration fails the start.
+			vaultLoginSecret, err = vaultAuthenticator.Login(context.Background())
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+	case decryptBackendSOPS:
+		decryptTraverser, err = newSOPSTraverser(config)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "%s must be one of %q or %q, got %q", config.Flag.Service.Decrypt.Backend, decryptBackendVault, decryptBackendSOPS, backend)
 	}
 
 	repositoryName := config.Viper.GetString(config.Flag.Service.GitHub.RepositoryName)
@@ -125,28 +185,97 @@ func New(config Config) (*Service, error) {
 		repositoryRef = "main"
 	}
 
+	var sshKnownHosts []byte
+	if path := config.Viper.GetString(config.Flag.Service.GitHub.SSH.KnownHostsFile); path != "" {
+		sshKnownHosts, err = os.ReadFile(path)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var trustedGPGKeys []byte
+	if path := config.Viper.GetString(config.Flag.Service.GitHub.Signature.TrustedGPGKeysFile); path != "" {
+		trustedGPGKeys, err = os.ReadFile(path)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var trustedSSHKeys []byte
+	if path := config.Viper.GetString(config.Flag.Service.GitHub.Signature.TrustedSSHKeysFile); path != "" {
+		trustedSSHKeys, err = os.ReadFile(path)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	gitHubApp := githubapp.Credential{
+		APIURL:         config.Viper.GetString(config.Flag.Service.GitHub.App.APIURL),
+		AppID:          config.Viper.GetInt64(config.Flag.Service.GitHub.App.ID),
+		InstallationID: config.Viper.GetInt64(config.Flag.Service.GitHub.App.InstallationID),
+		PrivateKey:     config.Viper.GetString(config.Flag.Service.GitHub.App.PrivateKey),
+	}
+
+	sharedConfigRepositories := []shared.ConfigRepository{
+		{
+			Name:     config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepository.Name),
+			Owner:    config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepository.Owner),
+			Ref:      config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepository.Ref),
+			URL:      config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepository.URL),
+			Key:      config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepository.Key),
+			Password: config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepository.Password),
+
+			RefuseProtectedOverrides: config.Viper.GetBool(config.Flag.Service.GitHub.SharedConfigRepository.RefuseProtectedOverrides),
+		},
+	}
+	if path := config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepositoriesFile); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		sharedConfigRepositories, err = shared.ParseConfigRepositories(data)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var configController *controller.Config
 	{
 		c := controller.ConfigConfig{
-			K8sClient:   k8sClient,
-			Logger:      config.Logger,
-			VaultClient: vaultClient,
-
-			SharedConfigRepository: shared.ConfigRepository{
-				Name:     config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepository.Name),
-				Ref:      config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepository.Ref),
-				Key:      config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepository.Key),
-				Password: config.Viper.GetString(config.Flag.Service.GitHub.SharedConfigRepository.Password),
-			},
+			DecryptTraverser: decryptTraverser,
+			K8sClient:        k8sClient,
+			Logger:           config.Logger,
+			VaultClient:      vaultClient,
+
+			SharedConfigRepositories: sharedConfigRepositories,
 			ConfigRepoSSHCredential: ssh.Credential{
 				Key:      config.Viper.GetString(config.Flag.Service.GitHub.SSH.Key),
 				Password: config.Viper.GetString(config.Flag.Service.GitHub.SSH.Password),
 			},
-			GitHubToken:    config.Viper.GetString(config.Flag.Service.GitHub.Token),
-			RepositoryName: repositoryName,
-			RepositoryRef:  repositoryRef,
-			Installation:   config.Viper.GetString(config.Flag.Service.Installation.Name),
-			UniqueApp:      config.Viper.GetBool(config.Flag.Service.App.Unique),
+			GitCacheDir:          config.Viper.GetString(config.Flag.Service.GitHub.CacheDir),
+			GitHost:              config.Viper.GetString(config.Flag.Service.GitHub.Host),
+			GitMaxStaleness:      config.Viper.GetDuration(config.Flag.Service.GitHub.MaxStaleness),
+			GitHubApp:            gitHubApp,
+			GitHubToken:          config.Viper.GetString(config.Flag.Service.GitHub.Token),
+			RepositoryOwner:      config.Viper.GetString(config.Flag.Service.GitHub.RepositoryOwner),
+			RepositoryName:       repositoryName,
+			RepositoryRef:        repositoryRef,
+			RepositoryURL:        config.Viper.GetString(config.Flag.Service.GitHub.RepositoryURL),
+			RepositoryUsername:   config.Viper.GetString(config.Flag.Service.GitHub.RepositoryUsername),
+			RepositoryToken:      config.Viper.GetString(config.Flag.Service.GitHub.RepositoryToken),
+			SSHKnownHosts:        string(sshKnownHosts),
+			TrustedGPGKeys:       string(trustedGPGKeys),
+			TrustedSSHKeys:       string(trustedSSHKeys),
+			Installation:         config.Viper.GetString(config.Flag.Service.Installation.Name),
+			PlaintextSecretCheck: config.Viper.GetString(config.Flag.Service.Generator.PlaintextSecretCheck),
+			PluginCommand:        config.Viper.GetString(config.Flag.Service.Decrypt.Plugin.Command),
+			PluginPrefix:         config.Viper.GetString(config.Flag.Service.Decrypt.Plugin.Prefix),
+			UniqueApp:            config.Viper.GetBool(config.Flag.Service.App.Unique),
+			VaultTransitKey:      config.Viper.GetString(config.Flag.Service.Vault.TransitKey),
+
+			K8sSecretAllowedNamespaces: xstrings.SplitList(config.Viper.GetString(config.Flag.Service.Decrypt.K8sSecret.AllowedNamespaces)),
+			VaultKVAllowedPathPrefixes: xstrings.SplitList(config.Viper.GetString(config.Flag.Service.Vault.KV.AllowedPathPrefixes)),
 		}
 
 		configController, err = controller.NewConfig(c)
@@ -155,6 +284,75 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
+	var requeuer *requeue.Requeuer
+	{
+		c := requeue.Config{
+			K8sClient: k8sClient,
+			Logger:    config.Logger,
+
+			UniqueApp: config.Viper.GetBool(config.Flag.Service.App.Unique),
+		}
+
+		requeuer, err = requeue.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var webhookService *webhook.Service
+	{
+		repositoryOwner := config.Viper.GetString(config.Flag.Service.GitHub.RepositoryOwner)
+		if repositoryOwner == "" {
+			repositoryOwner = "giantswarm"
+		}
+
+		repositories := []webhook.Repository{
+			{Owner: repositoryOwner, Name: repositoryName, Ref: repositoryRef},
+		}
+		for _, r := range sharedConfigRepositories {
+			owner := r.Owner
+			if owner == "" {
+				owner = repositoryOwner
+			}
+
+			repositories = append(repositories, webhook.Repository{Owner: owner, Name: r.Name, Ref: r.Ref, Shared: true})
+		}
+
+		c := webhook.Config{
+			Cache:    configController,
+			Logger:   config.Logger,
+			Requeuer: requeuer,
+			Shared:   configController,
+
+			GitHubSecret: config.Viper.GetString(config.Flag.Service.Webhook.GitHub.Secret),
+			GitLabToken:  config.Viper.GetString(config.Flag.Service.Webhook.GitLab.Token),
+			Installation: config.Viper.GetString(config.Flag.Service.Installation.Name),
+			Repositories: repositories,
+		}
+
+		webhookService, err = webhook.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var repositoryPoller *poller.Poller
+	if interval := config.Viper.GetDuration(config.Flag.Service.GitHub.PollInterval); interval > 0 {
+		c := poller.Config{
+			Logger:   config.Logger,
+			Requeuer: requeuer,
+			Source:   configController,
+
+			Installation: config.Viper.GetString(config.Flag.Service.Installation.Name),
+			Interval:     interval,
+		}
+
+		repositoryPoller, err = poller.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var operatorCollector *collector.Set
 	{
 		c := collector.SetConfig{
@@ -186,10 +384,15 @@ func New(config Config) (*Service, error) {
 
 	s := &Service{
 		Version: versionService,
+		Webhook: webhookService,
 
 		bootOnce:          sync.Once{},
 		configController:  configController,
 		operatorCollector: operatorCollector,
+		poller:            repositoryPoller,
+
+		vaultAuthenticator: vaultAuthenticator,
+		vaultLoginSecret:   vaultLoginSecret,
 	}
 
 	return s, nil
@@ -199,6 +402,65 @@ func (s *Service) Boot(ctx context.Context) {
 	s.bootOnce.Do(func() {
 		go s.operatorCollector.Boot(ctx) // nolint:errcheck
 
+		if s.vaultAuthenticator != nil {
+			go s.vaultAuthenticator.Run(ctx, s.vaultLoginSecret)
+		}
+
 		go s.configController.Boot(ctx)
+
+		if s.poller != nil {
+			go s.poller.Run(ctx)
+		}
 	})
 }
+
+func newSOPSTraverser(config Config) (*decrypt.SOPSTraverser, error) {
+	var err error
+
+	var c decrypt.SOPSTraverserConfig
+	if p := config.Viper.GetString(config.Flag.Service.Decrypt.SOPS.AgeKeyFile); p != "" {
+		c.AgeKeys, err = os.ReadFile(filepath.Clean(p))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+	if p := config.Viper.GetString(config.Flag.Service.Decrypt.SOPS.PGPKeyFile); p != "" {
+		c.PGPKeys, err = os.ReadFile(filepath.Clean(p))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	t, err := decrypt.NewSOPSTraverser(c)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return t, nil
+}
+
+func newVaultAuthenticator(config Config, vaultClient *vaultapi.Client) (*vault.Authenticator, error) {
+	c := vault.AuthenticatorConfig{
+		Logger:      config.Logger,
+		VaultClient: vaultClient,
+
+		Method: config.Viper.GetString(config.Flag.Service.Vault.Auth.Method),
+		AppRole: vault.AppRoleAuth{
+			MountPath:    config.Viper.GetString(config.Flag.Service.Vault.Auth.AppRole.MountPath),
+			RoleID:       config.Viper.GetString(config.Flag.Service.Vault.Auth.AppRole.RoleID),
+			SecretIDFile: config.Viper.GetString(config.Flag.Service.Vault.Auth.AppRole.SecretIDFile),
+		},
+		Kubernetes: vault.KubernetesAuth{
+			MountPath: config.Viper.GetString(config.Flag.Service.Vault.Auth.Kubernetes.MountPath),
+			Role:      config.Viper.GetString(config.Flag.Service.Vault.Auth.Kubernetes.Role),
+			TokenPath: config.Viper.GetString(config.Flag.Service.Vault.Auth.Kubernetes.TokenPath),
+		},
+	}
+
+	a, err := vault.NewAuthenticator(c)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return a, nil
+}
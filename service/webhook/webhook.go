@@ -0,0 +1,254 @@
+// Package webhook implements the handling of push webhooks of the config
+// repositories.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/config-controller/internal/affected"
+	"github.com/giantswarm/config-controller/internal/shared"
+)
+
+// CacheInvalidator drops the cached revisions of the configuration
+// repositories.
+type CacheInvalidator interface {
+	InvalidateCache()
+}
+
+// Requeuer triggers the reconciliation of the Config CRs of the apps or of
+// all Config CRs.
+type Requeuer interface {
+	Requeue(ctx context.Context, apps []string, all bool) (int, error)
+}
+
+// SharedConfigsSource returns the shared configs repositories with the refs
+// pinned for the installation at the current commit of the config
+// repository.
+type SharedConfigsSource interface {
+	PinnedSharedConfigRepositories(ctx context.Context) ([]shared.ConfigRepository, error)
+}
+
+// Repository is a repository the configuration is assembled from.
+type Repository struct {
+	// Owner of the repository, e.g. the GitHub organization or the GitLab
+	// group.
+	Owner string
+	Name  string
+	// Ref is the branch or tag the configuration is assembled from. Refs
+	// of shared configs repositories are pinned per installation, so they
+	// are resolved through the SharedConfigsSource instead.
+	Ref string
+	// Shared is true for shared configs repositories. Only their default
+	// and include directories are merged into the config repository.
+	Shared bool
+}
+
+type Config struct {
+	Cache    CacheInvalidator
+	Logger   micrologger.Logger
+	Requeuer Requeuer
+	Shared   SharedConfigsSource
+
+	// GitHubSecret is the secret GitHub signs webhook payloads with.
+	GitHubSecret string
+	// GitLabToken is the secret token GitLab sends with webhooks.
+	GitLabToken  string
+	Installation string
+	Repositories []Repository
+}
+
+type Service struct {
+	cache    CacheInvalidator
+	logger   micrologger.Logger
+	requeuer Requeuer
+	shared   SharedConfigsSource
+
+	gitHubSecret string
+	gitLabToken  string
+	installation string
+	repositories []Repository
+}
+
+// PushEvent is a push to a repository.
+type PushEvent struct {
+	// Repository is the full name of the repository, e.g.
+	// "giantswarm/config".
+	Repository string
+	// Ref is the full name of the pushed reference, e.g. "refs/heads/main".
+	Ref string
+	// Paths are the paths added, modified or removed by the pushed commits.
+	Paths []string
+	// Complete is false when Paths may miss changes, e.g. when the list of
+	// pushed commits is truncated or the push was forced.
+	Complete bool
+}
+
+// PushResult describes how a PushEvent was handled.
+type PushResult struct {
+	// Matched is true when the push touched a repository and reference
+	// the configuration is assembled from.
+	Matched bool `json:"matched"`
+	// Requeued is the number of requeued Config CRs.
+	Requeued int `json:"requeued"`
+}
+
+func New(config Config) (*Service, error) {
+	if config.Cache == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Cache must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Requeuer == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Requeuer must not be empty", config)
+	}
+	if config.Shared == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Shared must not be empty", config)
+	}
+
+	s := &Service{
+		cache:    config.Cache,
+		logger:   config.Logger,
+		requeuer: config.Requeuer,
+		shared:   config.Shared,
+
+		gitHubSecret: config.GitHubSecret,
+		gitLabToken:  config.GitLabToken,
+		installation: config.Installation,
+		repositories: config.Repositories,
+	}
+
+	return s, nil
+}
+
+// ValidateGitHubSignature validates the X-Hub-Signature-256 header of a
+// GitHub webhook, the HMAC-SHA256 of the payload keyed with GitHubSecret.
+// It returns an error matched by IsInvalidSignature when the signature is
+// invalid or GitHubSecret is not configured.
+func (s *Service) ValidateGitHubSignature(payload []byte, signature string) error {
+	if s.gitHubSecret == "" {
+		return microerror.Maskf(invalidSignatureError, "GitHub webhook secret is not configured")
+	}
+
+	hexSum, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return microerror.Maskf(invalidSignatureError, "signature must start with %#q", "sha256=")
+	}
+	sum, err := hex.DecodeString(hexSum)
+	if err != nil {
+		return microerror.Maskf(invalidSignatureError, "signature is not hex encoded")
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.gitHubSecret))
+	_, _ = mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return microerror.Maskf(invalidSignatureError, "signature does not match payload")
+	}
+
+	return nil
+}
+
+// ValidateGitLabToken validates the X-Gitlab-Token header of a GitLab
+// webhook. It returns an error matched by IsInvalidSignature when the token
+// is invalid or GitLabToken is not configured.
+func (s *Service) ValidateGitLabToken(token string) error {
+	if s.gitLabToken == "" {
+		return microerror.Maskf(invalidSignatureError, "GitLab webhook token is not configured")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.gitLabToken)) != 1 {
+		return microerror.Maskf(invalidSignatureError, "token does not match")
+	}
+
+	return nil
+}
+
+// Push drops the cached revisions of the configuration repositories and
+// requeues the Config CRs affected by the push when it touched a repository
+// and reference the configuration is assembled from. Assembled repositories
+// are cached by commit SHAs, so the requeued reconciliations resolve the
+// references again and check out the new commits. All Config CRs are
+// requeued when the changed paths are incomplete.
+func (s *Service) Push(ctx context.Context, e PushEvent) (PushResult, error) {
+	repository, ok := s.match(ctx, e)
+	if !ok {
+		s.logger.Debugf(ctx, "ignoring push to %#q of %#q", e.Ref, e.Repository)
+		return PushResult{}, nil
+	}
+
+	s.logger.Debugf(ctx, "handling push to %#q of %#q", e.Ref, e.Repository)
+
+	s.cache.InvalidateCache()
+
+	var apps []string
+	all := !e.Complete
+	if !all && repository.Shared {
+		apps, all = affected.SharedApps(s.installation, e.Paths)
+	} else if !all {
+		apps, all = affected.Apps(s.installation, e.Paths)
+	}
+
+	requeued, err := s.requeuer.Requeue(ctx, apps, all)
+	if err != nil {
+		return PushResult{}, microerror.Mask(err)
+	}
+
+	return PushResult{Matched: true, Requeued: requeued}, nil
+}
+
+// match returns the configured repository and reference the push touched.
+// Pushes to shared configs repositories are matched against the refs pinned
+// at the current commit of the config repository. When they can't be
+// resolved, the configured refs are used.
+func (s *Service) match(ctx context.Context, e PushEvent) (Repository, bool) {
+	var pinned map[string]string
+	for _, r := range s.repositories {
+		if !strings.EqualFold(e.Repository, r.Owner+"/"+r.Name) {
+			continue
+		}
+
+		ref := r.Ref
+		if r.Shared {
+			if pinned == nil {
+				pinned = s.pinnedRefs(ctx)
+			}
+
+			pinnedRef, ok := pinned[r.Name]
+			if ok {
+				ref = pinnedRef
+			}
+		}
+
+		if e.Ref == ref || e.Ref == "refs/heads/"+ref || e.Ref == "refs/tags/"+ref {
+			return r, true
+		}
+	}
+
+	return Repository{}, false
+}
+
+// pinnedRefs returns the pinned refs of the shared configs repositories by
+// name. It is empty when they can't be resolved.
+func (s *Service) pinnedRefs(ctx context.Context) map[string]string {
+	refs := map[string]string{}
+
+	repositories, err := s.shared.PinnedSharedConfigRepositories(ctx)
+	if err != nil {
+		s.logger.Errorf(ctx, err, "failed to resolve pinned refs of shared configs repositories, matching pushes to the configured refs")
+		return refs
+	}
+
+	for _, r := range repositories {
+		refs[r.Name] = r.Ref
+	}
+
+	return refs
+}
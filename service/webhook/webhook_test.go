@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/config-controller/internal/shared"
+)
+
+type cache struct {
+	invalidated bool
+}
+
+func (c *cache) InvalidateCache() {
+	c.invalidated = true
+}
+
+type requeuer struct {
+	apps []string
+	all  bool
+}
+
+func (r *requeuer) Requeue(ctx context.Context, apps []string, all bool) (int, error) {
+	r.apps = apps
+	r.all = all
+	return len(apps), nil
+}
+
+type sharedConfigs struct {
+	repositories []shared.ConfigRepository
+	err          error
+}
+
+func (s *sharedConfigs) PinnedSharedConfigRepositories(ctx context.Context) ([]shared.ConfigRepository, error) {
+	return s.repositories, s.err
+}
+
+func TestService_ValidateGitHubSignature(t *testing.T) {
+	// Example from the GitHub documentation on validating webhook
+	// deliveries.
+	payload := []byte("Hello, World!")
+	signature := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+
+	testCases := []struct {
+		name                 string
+		secret               string
+		signature            string
+		expectedErrorMessage string
+	}{
+		{
+			name:      "case 0: valid signature",
+			secret:    "It's a Secret to Everybody",
+			signature: signature,
+		},
+		{
+			name:                 "case 1: wrong secret",
+			secret:               "secret",
+			signature:            signature,
+			expectedErrorMessage: "signature does not match payload",
+		},
+		{
+			name:                 "case 2: secret not configured",
+			signature:            signature,
+			expectedErrorMessage: "GitHub webhook secret is not configured",
+		},
+		{
+			name:                 "case 3: missing signature",
+			secret:               "It's a Secret to Everybody",
+			expectedErrorMessage: "signature must start with",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := New(Config{
+				Cache:        &cache{},
+				Logger:       microloggertest.New(),
+				Requeuer:     &requeuer{},
+				Shared:       &sharedConfigs{},
+				GitHubSecret: tc.secret,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			err = s.ValidateGitHubSignature(payload, tc.signature)
+
+			if tc.expectedErrorMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+				}
+			} else {
+				switch {
+				case err == nil:
+					t.Fatalf("expected error %q but got nil", tc.expectedErrorMessage)
+				case !strings.Contains(microerror.Pretty(err, true), tc.expectedErrorMessage):
+					t.Fatalf("expected error %q but got %q", tc.expectedErrorMessage, microerror.Pretty(err, true))
+				default:
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestService_Push(t *testing.T) {
+	testCases := []struct {
+		name  string
+		event PushEvent
+		// sharedErr fails resolving the pinned refs of the shared configs
+		// repositories.
+		sharedErr           error
+		expectedInvalidated bool
+		expectedApps        []string
+		expectedAll         bool
+	}{
+		{
+			name: "case 0: push to config repository",
+			event: PushEvent{
+				Repository: "GiantSwarm/config",
+				Ref:        "refs/heads/main",
+				Paths:      []string{"installations/puma/apps/foo/configmap-values.yaml.patch"},
+				Complete:   true,
+			},
+			expectedInvalidated: true,
+			expectedApps:        []string{"foo"},
+		},
+		{
+			name: "case 1: push to other branch",
+			event: PushEvent{
+				Repository: "giantswarm/config",
+				Ref:        "refs/heads/feature",
+				Paths:      []string{"default/config.yaml"},
+				Complete:   true,
+			},
+		},
+		{
+			name: "case 2: push of shared configs tag",
+			event: PushEvent{
+				Repository: "giantswarm/shared-configs",
+				Ref:        "refs/tags/v1.0.0",
+				Paths: []string{
+					"default/apps/bar/metadata.yaml",
+					"installations/puma/config.yaml.patch",
+				},
+				Complete: true,
+			},
+			expectedInvalidated: true,
+			expectedApps:        []string{"bar"},
+		},
+		{
+			name: "case 3: incomplete push requeues all",
+			event: PushEvent{
+				Repository: "giantswarm/config",
+				Ref:        "refs/heads/main",
+			},
+			expectedInvalidated: true,
+			expectedAll:         true,
+		},
+		{
+			name: "case 4: push to shared configs ref not pinned by the installation",
+			event: PushEvent{
+				Repository: "giantswarm/shared-configs",
+				Ref:        "refs/heads/main",
+				Paths:      []string{"default/apps/bar/metadata.yaml"},
+				Complete:   true,
+			},
+		},
+		{
+			name: "case 5: pinned refs unknown, push to configured ref",
+			event: PushEvent{
+				Repository: "giantswarm/shared-configs",
+				Ref:        "refs/heads/main",
+				Paths:      []string{"default/apps/bar/metadata.yaml"},
+				Complete:   true,
+			},
+			sharedErr:           microerror.Mask(invalidConfigError),
+			expectedInvalidated: true,
+			expectedApps:        []string{"bar"},
+		},
+		{
+			name: "case 6: pinned refs unknown, push to other branch",
+			event: PushEvent{
+				Repository: "giantswarm/shared-configs",
+				Ref:        "refs/heads/feature",
+				Paths:      []string{"default/apps/bar/metadata.yaml"},
+				Complete:   true,
+			},
+			sharedErr: microerror.Mask(invalidConfigError),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &cache{}
+			r := &requeuer{}
+
+			s, err := New(Config{
+				Cache:    c,
+				Logger:   microloggertest.New(),
+				Requeuer: r,
+				// The installation pins the shared configs repository
+				// to another ref than the default one.
+				Shared: &sharedConfigs{
+					repositories: []shared.ConfigRepository{
+						{Name: "shared-configs", Ref: "v1.0.0"},
+					},
+					err: tc.sharedErr,
+				},
+				Installation: "puma",
+				Repositories: []Repository{
+					{Owner: "giantswarm", Name: "config", Ref: "main"},
+					{Owner: "giantswarm", Name: "shared-configs", Ref: "main", Shared: true},
+				},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			result, err := s.Push(context.Background(), tc.event)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			if result.Matched != tc.expectedInvalidated {
+				t.Fatalf("matched = %t, want %t", result.Matched, tc.expectedInvalidated)
+			}
+			if c.invalidated != tc.expectedInvalidated {
+				t.Fatalf("invalidated = %t, want %t", c.invalidated, tc.expectedInvalidated)
+			}
+			if !reflect.DeepEqual(r.apps, tc.expectedApps) {
+				t.Fatalf("apps = %v, want %v", r.apps, tc.expectedApps)
+			}
+			if r.all != tc.expectedAll {
+				t.Fatalf("all = %t, want %t", r.all, tc.expectedAll)
+			}
+		})
+	}
+}